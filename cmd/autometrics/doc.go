@@ -7,16 +7,35 @@
 // [Prometheus client library]. If you want to use [OpenTelemetry metrics]
 // instead (with a prometheus exporter for the metrics), pass the `-otel` flag
 // to the invocation.
+// If you want to send the metrics to a [StatsD] agent, with DogStatsD tags,
+// pass the `-statsd` flag to the invocation.
+// To use another implementation of [autometrics.Backend], like an in-house
+// metrics library, pass the `-backend` flag followed by its import path, like
+// `-backend github.com/acme/ourmetrics`. The package must export the functions
+// and options described in [autometrics.Backend].
 //
 // By default, when activating Service Level Objectives (SLOs) `autometrics`
 // does not allow to use latency targets that are outside the default latencies
 // defined in [autometrics.DefBuckets]. If you want to use custom latencies for
 // your latency SLOs, pass the `-custom-latency` flag to the invocation.
 //
+// By default, the generated links query the metric and label names of this
+// library, like `function_calls_count`. If your program calls Init with the
+// `WithNamingScheme(autometrics.SpecNaming)` option to use the names of the
+// Autometrics specification, pass the `-spec-names` flag to the invocation.
+//
+// By default, the caller of an instrumented function is read from the stack
+// frames. If you pass the `-context-caller` flag to the invocation, the
+// instrumented functions that have a `context.Context` or a `*http.Request`
+// parameter track their caller through this context instead, so that the
+// caller is the nearest instrumented ancestor even across goroutines and
+// adapters.
+//
 // By default, the generated links in the documentation point to a Prometheus
 // instance at http://localhost:9090. You can use the environment variable
 // `AM_PROMETHEUS_URL` to change the base URL in the documentation links.
 //
 // [Prometheus client library]: https://github.com/prometheus/client_golang
 // [OpenTelemetry metrics]: https://opentelemetry.io/docs/instrumentation/go/
+// [StatsD]: https://github.com/statsd/statsd
 package main
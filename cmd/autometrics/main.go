@@ -1,8 +1,11 @@
 package main
 
 import (
+	"errors"
+	"fmt"
 	"log"
 	"os"
+	"strings"
 
 	internal "github.com/autometrics-dev/autometrics-go/internal/autometrics"
 	"github.com/autometrics-dev/autometrics-go/internal/generate"
@@ -12,7 +15,11 @@ import (
 const (
 	prometheusAddressEnvironmentVariable = "AM_PROMETHEUS_URL"
 	useOtelFlag                          = "-otel"
+	useStatsdFlag                        = "-statsd"
+	backendFlag                          = "-backend"
 	allowCustomLatencies                 = "-custom-latency"
+	useSpecNamesFlag                     = "-spec-names"
+	useContextCallerFlag                 = "-context-caller"
 	DefaultPrometheusInstanceUrl         = "http://localhost:9090/"
 )
 
@@ -29,6 +36,8 @@ func main() {
 	implementation := autometrics.PROMETHEUS
 	if contains(args, useOtelFlag) {
 		implementation = autometrics.OTEL
+	} else if contains(args, useStatsdFlag) {
+		implementation = autometrics.STATSD
 	}
 
 	ctx, err := internal.NewGeneratorContext(implementation, prometheusUrl, contains(args, allowCustomLatencies))
@@ -36,7 +45,26 @@ func main() {
 		log.Fatalf("error initialising autometrics context: %s", err)
 	}
 
+	if contains(args, useSpecNamesFlag) {
+		ctx.NamingScheme = autometrics.SpecNaming
+	}
+
+	ctx.ContextCaller = contains(args, useContextCallerFlag)
+
+	if backend, ok := flagValue(args, backendFlag); ok {
+		if backend == "" {
+			log.Fatalf("the %s flag needs the import path of the implementation", backendFlag)
+		}
+		ctx.BackendImportPath = backend
+	}
+
 	if err := generate.TransformFile(ctx, fileName, moduleName); err != nil {
+		var positionErr *generate.PositionError
+		if errors.As(err, &positionErr) {
+			// Print the error alone, in the file:line:col format the editors understand.
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
 		log.Fatalf("error transforming %s: %s", fileName, err)
 	}
 }
@@ -49,3 +77,30 @@ func contains[T comparable](s []T, e T) bool {
 	}
 	return false
 }
+
+// flagValue returns the value of a flag given as "-flag value" or "-flag=value".
+func flagValue(args []string, flag string) (string, bool) {
+	for i, arg := range args {
+		if arg == flag {
+			if i+1 < len(args) {
+				return args[i+1], true
+			}
+			return "", true
+		}
+
+		if value, found := cutPrefix(arg, flag+"="); found {
+			return value, true
+		}
+	}
+
+	return "", false
+}
+
+// Backport of strings.CutPrefix for pre-1.20
+func cutPrefix(s, prefix string) (after string, found bool) {
+	if !strings.HasPrefix(s, prefix) {
+		return s, false
+	}
+
+	return s[len(prefix):], true
+}
@@ -8,11 +8,21 @@ import (
 )
 
 type GeneratorContext struct {
-	RuntimeCtx             autometrics.Context
-	FuncCtx                GeneratorFunctionContext
-	Implementation         autometrics.Implementation
+	RuntimeCtx     autometrics.Context
+	FuncCtx        GeneratorFunctionContext
+	Implementation autometrics.Implementation
+	// BackendImportPath is the import path of a custom implementation of autometrics.Backend,
+	// used by the generated code instead of the package of Implementation when it is set.
+	BackendImportPath      string
 	DocumentationGenerator AutometricsLinkCommentGenerator
 	AllowCustomLatencies   bool
+	// NamingScheme is the set of metric and label names used in the generated documentation links.
+	NamingScheme autometrics.NamingScheme
+	// ContextCaller makes the instrumentation track the caller through the context.Context
+	// or *http.Request parameter of the functions, when they have one.
+	ContextCaller bool
+	// FileName is the name of the transformed file, used in the position of the errors.
+	FileName string
 }
 
 type GeneratorFunctionContext struct {
@@ -20,12 +30,37 @@ type GeneratorFunctionContext struct {
 	FunctionName   string
 	ModuleName     string
 	ImplImportName string
+	// GoFunctionName and GoModuleName are the names of the function in the source code, before
+	// the --name and --module arguments. The callees that read their caller from the stack
+	// frames report these names.
+	GoFunctionName string
+	GoModuleName   string
+	// ContextParameter is the name of the context.Context parameter of the function
+	// used to track the caller, if any.
+	ContextParameter string
+	// RequestParameter is the name of the *http.Request parameter of the function
+	// used to track the caller, if any.
+	RequestParameter string
+	// Labels are the user-defined labels of the function, given with the --label arguments.
+	Labels []LabelExpression
+}
+
+// LabelExpression is a user-defined label whose value is a Go expression using the
+// parameters of the function.
+type LabelExpression struct {
+	Name       string
+	Expression string
 }
 
 func (c *GeneratorContext) ResetFuncCtx() {
 	c.FuncCtx.CommentIndex = -1
 	c.FuncCtx.FunctionName = ""
 	c.FuncCtx.ModuleName = ""
+	c.FuncCtx.GoFunctionName = ""
+	c.FuncCtx.GoModuleName = ""
+	c.FuncCtx.ContextParameter = ""
+	c.FuncCtx.RequestParameter = ""
+	c.FuncCtx.Labels = nil
 }
 
 func (c *GeneratorContext) SetCommentIdx(i int) {
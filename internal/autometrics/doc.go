@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"net/url"
 
+	"github.com/autometrics-dev/autometrics-go/pkg/autometrics"
 	"github.com/autometrics-dev/autometrics-go/pkg/autometrics/prometheus"
 )
 
@@ -46,37 +47,76 @@ func (p Prometheus) makePrometheusUrl(query, comment string) url.URL {
 	return ret
 }
 
-func requestRateQuery(counterName, labelKey, labelValue string) string {
-	return fmt.Sprintf("sum by (%s, %s) (rate(%s{%s=\"%s\"}[5m]))", prometheus.FunctionLabel, prometheus.ModuleLabel, counterName, labelKey, labelValue)
+// metricNames returns the names of the counter, duration histogram, concurrent calls gauge
+// and build information metrics for the naming scheme.
+func metricNames(scheme autometrics.NamingScheme) (counterName, durationName, concurrentName, buildInfoName string) {
+	if scheme == autometrics.SpecNaming {
+		return prometheus.FunctionCallsTotalName, prometheus.FunctionCallsDurationSecondsName, prometheus.FunctionCallsConcurrentName, prometheus.BuildInfoSpecName
+	}
+
+	return prometheus.FunctionCallsCountName, prometheus.FunctionCallsDurationName, prometheus.FunctionCallsConcurrentName, prometheus.BuildInfoName
+}
+
+// callerSelector returns the label matchers that select the calls made by the function.
+func callerSelector(scheme autometrics.NamingScheme, funcName, moduleName string) string {
+	if scheme == autometrics.SpecNaming {
+		return fmt.Sprintf("%s=\"%s\",%s=\"%s\"", prometheus.CallerFunctionLabel, funcName, prometheus.CallerModuleLabel, moduleName)
+	}
+
+	return fmt.Sprintf("%s=\"%s.%s\"", prometheus.CallerLabel, moduleName, funcName)
 }
 
-func errorRatioQuery(counterName, labelKey, labelValue string) string {
-	return fmt.Sprintf("sum by (%s, %s) (rate(%s{%s=\"%s\",%s=\"error\"}[5m]))", prometheus.FunctionLabel, prometheus.ModuleLabel, counterName, labelKey, labelValue, prometheus.ResultLabel)
+// buildInfoJoin adds the version and commit labels of the build information metric
+// to the series of the instant vector.
+func buildInfoJoin(vector, buildInfoName string) string {
+	return fmt.Sprintf("%s * on (instance, job) group_left(%s, %s) %s", vector, prometheus.VersionLabel, prometheus.CommitLabel, buildInfoName)
 }
 
-func latencyQuery(bucketName, labelKey, labelValue string) string {
-	latency := fmt.Sprintf("sum by (le, %s, %s) (rate(%s_bucket{%s=\"%s\"}[5m]))", prometheus.FunctionLabel, prometheus.ModuleLabel, bucketName, labelKey, labelValue)
+func requestRateQuery(counterName, selector, buildInfoName string) string {
+	return fmt.Sprintf("sum by (%s, %s, %s, %s, %s) (%s)", prometheus.FunctionLabel, prometheus.ModuleLabel, prometheus.ServiceNameLabel, prometheus.VersionLabel, prometheus.CommitLabel, buildInfoJoin(fmt.Sprintf("rate(%s{%s}[5m])", counterName, selector), buildInfoName))
+}
+
+func errorRatioQuery(counterName, selector, buildInfoName string) string {
+	return fmt.Sprintf("sum by (%s, %s, %s, %s, %s) (%s)", prometheus.FunctionLabel, prometheus.ModuleLabel, prometheus.ServiceNameLabel, prometheus.VersionLabel, prometheus.CommitLabel, buildInfoJoin(fmt.Sprintf("rate(%s{%s,%s=\"error\"}[5m])", counterName, selector, prometheus.ResultLabel), buildInfoName))
+}
+
+func latencyQuery(bucketName, selector, buildInfoName string) string {
+	latency := fmt.Sprintf("sum by (le, %s, %s, %s, %s, %s) (%s)", prometheus.FunctionLabel, prometheus.ModuleLabel, prometheus.ServiceNameLabel, prometheus.VersionLabel, prometheus.CommitLabel, buildInfoJoin(fmt.Sprintf("rate(%s_bucket{%s}[5m])", bucketName, selector), buildInfoName))
 
 	return fmt.Sprintf("histogram_quantile(0.99, %s) or histogram_quantile(0.95, %s)", latency, latency)
 }
 
-func concurrentCallsQuery(gaugeName, labelKey, labelValue string) string {
-	return fmt.Sprintf("sum by (%s, %s) %s{%s=\"%s\"}", prometheus.FunctionLabel, prometheus.ModuleLabel, gaugeName, labelKey, labelValue)
+func concurrentCallsQuery(gaugeName, selector, buildInfoName string) string {
+	return fmt.Sprintf("sum by (%s, %s, %s, %s, %s) (%s)", prometheus.FunctionLabel, prometheus.ModuleLabel, prometheus.ServiceNameLabel, prometheus.VersionLabel, prometheus.CommitLabel, buildInfoJoin(fmt.Sprintf("%s{%s}", gaugeName, selector), buildInfoName))
 }
 
 func (p Prometheus) GenerateAutometricsComment(ctx GeneratorContext, funcName, moduleName string) []string {
+	counterName, durationName, concurrentName, buildInfoName := metricNames(ctx.NamingScheme)
+	functionSelector := fmt.Sprintf("%s=\"%s\"", prometheus.FunctionLabel, funcName)
+	// The callees only report the --name and --module arguments as their caller when they
+	// read it from the context, otherwise the stack frames give the names of the source code.
+	callerFuncName, callerModuleName := funcName, moduleName
+	if !ctx.ContextCaller && ctx.FuncCtx.GoFunctionName != "" {
+		callerFuncName, callerModuleName = ctx.FuncCtx.GoFunctionName, ctx.FuncCtx.GoModuleName
+	}
+	calleeSelector := callerSelector(ctx.NamingScheme, callerFuncName, callerModuleName)
+	// The copies recorded for the functions that have several objectives are left out
+	// of the counter and histogram queries, see prometheus.ObjectiveCopyLabel.
+	callsFunctionSelector := fmt.Sprintf("%s,%s=\"\"", functionSelector, prometheus.ObjectiveCopyLabel)
+	callsCalleeSelector := fmt.Sprintf("%s,%s=\"\"", calleeSelector, prometheus.ObjectiveCopyLabel)
+
 	requestRateUrl := p.makePrometheusUrl(
-		requestRateQuery(prometheus.FunctionCallsCountName, prometheus.FunctionLabel, funcName), fmt.Sprintf("Rate of calls to the `%s` function per second, averaged over 5 minute windows", funcName))
+		requestRateQuery(counterName, callsFunctionSelector, buildInfoName), fmt.Sprintf("Rate of calls to the `%s` function per second, averaged over 5 minute windows", funcName))
 	calleeRequestRateUrl := p.makePrometheusUrl(
-		requestRateQuery(prometheus.FunctionCallsCountName, prometheus.CallerLabel, fmt.Sprintf("%s.%s", moduleName, funcName)), fmt.Sprintf("Rate of function calls emanating from `%s` function per second, averaged over 5 minute windows", funcName))
+		requestRateQuery(counterName, callsCalleeSelector, buildInfoName), fmt.Sprintf("Rate of function calls emanating from `%s` function per second, averaged over 5 minute windows", funcName))
 	errorRatioUrl := p.makePrometheusUrl(
-		errorRatioQuery(prometheus.FunctionCallsCountName, prometheus.FunctionLabel, funcName), fmt.Sprintf("Percentage of calls to the `%s` function that return errors, averaged over 5 minute windows", funcName))
+		errorRatioQuery(counterName, callsFunctionSelector, buildInfoName), fmt.Sprintf("Percentage of calls to the `%s` function that return errors, averaged over 5 minute windows", funcName))
 	calleeErrorRatioUrl := p.makePrometheusUrl(
-		errorRatioQuery(prometheus.FunctionCallsCountName, prometheus.CallerLabel, fmt.Sprintf("%s.%s", moduleName, funcName)), fmt.Sprintf("Percentage of function emanating from `%s` function that return errors, averaged over 5 minute windows", funcName))
+		errorRatioQuery(counterName, callsCalleeSelector, buildInfoName), fmt.Sprintf("Percentage of function emanating from `%s` function that return errors, averaged over 5 minute windows", funcName))
 	latencyUrl := p.makePrometheusUrl(
-		latencyQuery(prometheus.FunctionCallsDurationName, prometheus.FunctionLabel, funcName), fmt.Sprintf("95th and 99th percentile latencies (in seconds) for the `%s` function", funcName))
+		latencyQuery(durationName, callsFunctionSelector, buildInfoName), fmt.Sprintf("95th and 99th percentile latencies (in seconds) for the `%s` function", funcName))
 	concurrentCallsUrl := p.makePrometheusUrl(
-		concurrentCallsQuery(prometheus.FunctionCallsConcurrentName, prometheus.FunctionLabel, funcName), fmt.Sprintf("Concurrent calls to the `%s` function", funcName))
+		concurrentCallsQuery(concurrentName, functionSelector, buildInfoName), fmt.Sprintf("Concurrent calls to the `%s` function", funcName))
 
 	// Not using raw `` strings because it's impossible to escape ` within those
 	retval := []string{
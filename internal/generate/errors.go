@@ -0,0 +1,74 @@
+package generate
+
+import (
+	"fmt"
+	"go/token"
+)
+
+// PositionError is an error of the generator about a position of the source file, like
+// the directive comment of a function.
+//
+// Its message starts with the position in the "file:line:col" format, so that editors
+// can jump to it.
+type PositionError struct {
+	Pos token.Position
+	Err error
+}
+
+func (e *PositionError) Error() string {
+	return fmt.Sprintf("%v: %v", e.Pos, e.Err)
+}
+
+func (e *PositionError) Unwrap() error {
+	return e.Err
+}
+
+// maxSuggestionDistance is the largest edit distance between an unknown argument
+// and a known one for the known one to be suggested.
+const maxSuggestionDistance = 3
+
+// suggestion returns " (did you mean ...?)" with the candidate closest to input,
+// or an empty string if none of them is close enough.
+func suggestion(input string, candidates []string) string {
+	best, bestDistance := "", maxSuggestionDistance+1
+	for _, candidate := range candidates {
+		if distance := levenshtein(input, candidate); distance < bestDistance {
+			best, bestDistance = candidate, distance
+		}
+	}
+
+	if best == "" {
+		return ""
+	}
+
+	return fmt.Sprintf(" (did you mean %q?)", best)
+}
+
+// levenshtein returns the edit distance between a and b.
+func levenshtein(a, b string) int {
+	previous := make([]int, len(b)+1)
+	current := make([]int, len(b)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		current[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			current[j] = previous[j-1] + cost
+			if previous[j]+1 < current[j] {
+				current[j] = previous[j] + 1
+			}
+			if current[j-1]+1 < current[j] {
+				current[j] = current[j-1] + 1
+			}
+		}
+		previous, current = current, previous
+	}
+
+	return previous[len(b)]
+}
@@ -1,7 +1,12 @@
 package generate
 
 import (
+	"errors"
 	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/scanner"
+	"go/token"
 	"os"
 	"strconv"
 	"strings"
@@ -19,15 +24,50 @@ import (
 )
 
 const (
-	SloNameArgument    = "--slo"
-	SuccessObjArgument = "--success-target"
-	LatencyMsArgument  = "--latency-ms"
-	LatencyObjArgument = "--latency-target"
-
-	AmPromPackage = "\"github.com/autometrics-dev/autometrics-go/pkg/autometrics/prometheus\""
-	AmOtelPackage = "\"github.com/autometrics-dev/autometrics-go/pkg/autometrics/otel\""
+	SloNameArgument       = "--slo"
+	SuccessObjArgument    = "--success-target"
+	SuccessRateArgument   = "--success-rate"
+	LatencyArgument       = "--latency"
+	LatencyMsArgument     = "--latency-ms"
+	LatencyObjArgument    = "--latency-target"
+	TraceArgument         = "--trace"
+	ProfileLabelsArgument = "--profile-labels"
+	RuntimeTraceArgument  = "--runtime-trace"
+	LabelArgument         = "--label"
+	NoConcurrencyArgument = "--no-concurrency"
+	NoCallerArgument      = "--no-caller"
+	NameArgument          = "--name"
+	ModuleArgument        = "--module"
+
+	AmPromPackage   = "\"github.com/autometrics-dev/autometrics-go/pkg/autometrics/prometheus\""
+	AmOtelPackage   = "\"github.com/autometrics-dev/autometrics-go/pkg/autometrics/otel\""
+	AmStatsdPackage = "\"github.com/autometrics-dev/autometrics-go/pkg/autometrics/statsd\""
+
+	// InitDirective is the comment that marks the init function registering the
+	// instrumented functions of a file.
+	InitDirective = "//autometrics:init"
+	// DocDirective is the comment that marks the functions to instrument, followed by their arguments.
+	DocDirective = "//autometrics:doc"
 )
 
+// directiveArguments are the arguments of the DocDirective, suggested for the unknown arguments.
+var directiveArguments = []string{
+	SloNameArgument,
+	SuccessObjArgument,
+	SuccessRateArgument,
+	LatencyArgument,
+	LatencyMsArgument,
+	LatencyObjArgument,
+	TraceArgument,
+	ProfileLabelsArgument,
+	RuntimeTraceArgument,
+	LabelArgument,
+	NoConcurrencyArgument,
+	NoCallerArgument,
+	NameArgument,
+	ModuleArgument,
+}
+
 // TransformFile takes a file path and generates the documentation
 // for the `//autometrics:doc` functions.
 //
@@ -51,8 +91,16 @@ func TransformFile(ctx internal.GeneratorContext, path, moduleName string) error
 	}
 
 	sourceCode := string(sourceBytes)
+	if ctx.FileName == "" {
+		ctx.FileName = path
+	}
 	transformedSource, err := GenerateDocumentationAndInstrumentation(ctx, sourceCode, moduleName)
 	if err != nil {
+		var positionErr *PositionError
+		if errors.As(err, &positionErr) {
+			// The position already names the file, and must start the message for the editors.
+			return err
+		}
 		return fmt.Errorf("error generating documentation: %w", err)
 	}
 
@@ -69,32 +117,47 @@ func TransformFile(ctx internal.GeneratorContext, path, moduleName string) error
 //
 // It returns the new source code with augmented documentation.
 func GenerateDocumentationAndInstrumentation(ctx internal.GeneratorContext, sourceCode, moduleName string) (string, error) {
-	fileTree, err := decorator.Parse(sourceCode)
+	fileSet := token.NewFileSet()
+	dec := decorator.NewDecorator(fileSet)
+	fileTree, err := dec.ParseFile(ctx.FileName, sourceCode, parser.ParseComments)
 	if err != nil {
+		var parseErrors scanner.ErrorList
+		if errors.As(err, &parseErrors) && len(parseErrors) > 0 {
+			return "", &PositionError{Pos: parseErrors[0].Pos, Err: fmt.Errorf("error parsing source code: %v", parseErrors[0].Msg)}
+		}
 		return "", fmt.Errorf("error parsing source code: %w", err)
 	}
+	astFile, ok := dec.Map.Ast.Nodes[fileTree].(*ast.File)
+	if !ok {
+		return "", fmt.Errorf("error parsing source code: no syntax tree for the file")
+	}
+
+	// The registration function is regenerated from scratch with the
+	// instrumented functions found in this pass.
+	fileTree.Decls = filterDecls(fileTree.Decls, func(decl dst.Decl) bool {
+		return !isGeneratedInitFunction(decl)
+	})
 
 	var inspectErr error
+	var registrations []dst.Stmt
+	contextImportName, httpImportName := "context", "http"
+	implementationImport := implementationImportPath(ctx)
 
 	fileWalk := func(n dst.Node) bool {
 		if importSpec, ok := n.(*dst.ImportSpec); ok {
-			if ctx.Implementation == autometrics.PROMETHEUS {
-				if importSpec.Path.Value == AmPromPackage {
-					if importSpec.Name != nil {
-						ctx.FuncCtx.ImplImportName = importSpec.Name.Name
-					} else {
-						ctx.FuncCtx.ImplImportName = "prometheus"
-					}
-				}
+			if importSpec.Name != nil && importSpec.Path.Value == "\"context\"" {
+				contextImportName = importSpec.Name.Name
 			}
 
-			if ctx.Implementation == autometrics.OTEL {
-				if importSpec.Path.Value == AmOtelPackage {
-					if importSpec.Name != nil {
-						ctx.FuncCtx.ImplImportName = importSpec.Name.Name
-					} else {
-						ctx.FuncCtx.ImplImportName = "otel"
-					}
+			if importSpec.Name != nil && importSpec.Path.Value == "\"net/http\"" {
+				httpImportName = importSpec.Name.Name
+			}
+
+			if importSpec.Path.Value == implementationImport {
+				if importSpec.Name != nil {
+					ctx.FuncCtx.ImplImportName = importSpec.Name.Name
+				} else {
+					ctx.FuncCtx.ImplImportName = defaultImportName(implementationImport)
 				}
 			}
 
@@ -102,19 +165,28 @@ func GenerateDocumentationAndInstrumentation(ctx internal.GeneratorContext, sour
 		}
 
 		if funcDeclaration, ok := n.(*dst.FuncDecl); ok {
+			// errorAt positions err at the comment of the function with the given text,
+			// or at the function itself.
+			errorAt := func(comment string, err error) error {
+				return &PositionError{
+					Pos: commentPosition(fileSet, astFile, dec.Map.Ast.Nodes[funcDeclaration], comment),
+					Err: err,
+				}
+			}
+
 			if ctx.FuncCtx.ImplImportName == "" {
-				if ctx.Implementation == autometrics.PROMETHEUS {
-					inspectErr = fmt.Errorf("the source file is missing a %v import", AmPromPackage)
-				} else if ctx.Implementation == autometrics.OTEL {
-					inspectErr = fmt.Errorf("the source file is missing a %v import", AmOtelPackage)
+				if implementationImport == "" {
+					inspectErr = errorAt("", fmt.Errorf("unknown implementation of metrics has been queried."))
 				} else {
-					inspectErr = fmt.Errorf("unknown implementation of metrics has been queried.")
+					inspectErr = errorAt("", fmt.Errorf("the source file is missing a %v import", implementationImport))
 				}
 				return false
 			}
 
-			ctx.FuncCtx.FunctionName = funcDeclaration.Name.Name
+			ctx.FuncCtx.FunctionName = functionName(funcDeclaration)
 			ctx.FuncCtx.ModuleName = moduleName
+			ctx.FuncCtx.GoFunctionName = ctx.FuncCtx.FunctionName
+			ctx.FuncCtx.GoModuleName = moduleName
 			defer ctx.ResetFuncCtx()
 
 			// this block gets run for every function in the file
@@ -125,22 +197,22 @@ func GenerateDocumentationAndInstrumentation(ctx internal.GeneratorContext, sour
 			oldEndCommentIndices := autometricsDocEndDirectives(docComments)
 
 			if len(oldStartCommentIndices) > 0 && len(oldEndCommentIndices) == 0 {
-				inspectErr = fmt.Errorf("Found an autometrics:doc-start cookie for function %s, but no matching :doc-end cookie", funcDeclaration.Name.Name)
+				inspectErr = errorAt(docComments[oldStartCommentIndices[0]], fmt.Errorf("Found an autometrics:doc-start cookie for function %s, but no matching :doc-end cookie", funcDeclaration.Name.Name))
 				return false
 			}
 
 			if len(oldStartCommentIndices) == 0 && len(oldEndCommentIndices) > 0 {
-				inspectErr = fmt.Errorf("Found an autometrics:doc-end cookie for function %s, but no matching :doc-start cookie", funcDeclaration.Name.Name)
+				inspectErr = errorAt(docComments[oldEndCommentIndices[0]], fmt.Errorf("Found an autometrics:doc-end cookie for function %s, but no matching :doc-start cookie", funcDeclaration.Name.Name))
 				return false
 			}
 
 			if len(oldStartCommentIndices) > 1 {
-				inspectErr = fmt.Errorf("Found more than 1 autometrics:doc-start cookie for function %s", funcDeclaration.Name.Name)
+				inspectErr = errorAt(docComments[oldStartCommentIndices[1]], fmt.Errorf("Found more than 1 autometrics:doc-start cookie for function %s", funcDeclaration.Name.Name))
 				return false
 			}
 
 			if len(oldEndCommentIndices) > 1 {
-				inspectErr = fmt.Errorf("Found more than 1 autometrics:doc-end cookie for function %s", funcDeclaration.Name.Name)
+				inspectErr = errorAt(docComments[oldEndCommentIndices[1]], fmt.Errorf("Found more than 1 autometrics:doc-end cookie for function %s", funcDeclaration.Name.Name))
 				return false
 			}
 
@@ -149,7 +221,7 @@ func GenerateDocumentationAndInstrumentation(ctx internal.GeneratorContext, sour
 				oldEndCommentIndex := oldEndCommentIndices[0]
 
 				if oldStartCommentIndex >= 0 && oldEndCommentIndex <= oldStartCommentIndex {
-					inspectErr = fmt.Errorf("Found an autometrics cookies for function %s, but the end one is after the start one", funcDeclaration.Name.Name)
+					inspectErr = errorAt(docComments[oldEndCommentIndex], fmt.Errorf("Found an autometrics cookies for function %s, but the end one is after the start one", funcDeclaration.Name.Name))
 					return false
 				}
 
@@ -176,23 +248,45 @@ func GenerateDocumentationAndInstrumentation(ctx internal.GeneratorContext, sour
 			// Detect autometrics directive
 			err := parseAutometricsFnContext(&ctx, docComments)
 			if err != nil {
-				inspectErr = fmt.Errorf(
+				inspectErr = errorAt(docComments[ctx.FuncCtx.CommentIndex], fmt.Errorf(
 					"failed to parse //autometrics directive for %v: %w",
 					funcDeclaration.Name.Name,
-					err)
+					err))
 				return false
 			}
 			listIndex := ctx.FuncCtx.CommentIndex
 			if listIndex >= 0 {
+				// The --name and --module arguments replace the names in the links and the registration.
+				if ctx.RuntimeCtx.CallInfo.FuncName != "" {
+					ctx.FuncCtx.FunctionName = ctx.RuntimeCtx.CallInfo.FuncName
+				}
+				if ctx.RuntimeCtx.CallInfo.ModuleName != "" {
+					ctx.FuncCtx.ModuleName = ctx.RuntimeCtx.CallInfo.ModuleName
+				}
+
 				// Insert comments
 				autometricsComment := generateAutometricsComment(ctx)
 				funcDeclaration.Decorations().Start.Replace(insertComments(docComments, listIndex, autometricsComment)...)
 
+				for _, label := range ctx.FuncCtx.Labels {
+					if err := checkLabelExpression(funcDeclaration, label.Expression); err != nil {
+						inspectErr = errorAt(docComments[listIndex], fmt.Errorf("invalid %v argument for %v in %v: %w", LabelArgument, label.Name, funcDeclaration.Name.Name, err))
+						return false
+					}
+				}
+
+				// The pprof labels of the caller can only be restored when they come through the context,
+				// and the runtime/trace tasks need the context to hold the nested calls.
+				ctx.FuncCtx.ContextParameter, ctx.FuncCtx.RequestParameter = "", ""
+				if ctx.ContextCaller || ctx.RuntimeCtx.TrackProfileLabels || ctx.RuntimeCtx.TrackRuntimeTrace {
+					ctx.FuncCtx.ContextParameter, ctx.FuncCtx.RequestParameter = callerContextParameters(funcDeclaration, contextImportName, httpImportName)
+				}
+
 				// defer statement
 				firstStatement := funcDeclaration.Body.List[0]
 				variable, err := errorReturnValueName(funcDeclaration)
 				if err != nil {
-					inspectErr = fmt.Errorf("failed to get error return value name: %w", err)
+					inspectErr = errorAt(docComments[listIndex], fmt.Errorf("failed to get error return value name: %w", err))
 					return false
 				}
 
@@ -204,7 +298,7 @@ func GenerateDocumentationAndInstrumentation(ctx internal.GeneratorContext, sour
 
 				autometricsDeferStatement, err := buildAutometricsDeferStatement(ctx, variable)
 				if err != nil {
-					inspectErr = fmt.Errorf("failed to build the defer statement for instrumentation: %w", err)
+					inspectErr = errorAt(docComments[listIndex], fmt.Errorf("failed to build the defer statement for instrumentation: %w", err))
 					return false
 				}
 
@@ -219,6 +313,13 @@ func GenerateDocumentationAndInstrumentation(ctx internal.GeneratorContext, sour
 				} else {
 					funcDeclaration.Body.List = append([]dst.Stmt{&autometricsDeferStatement}, funcDeclaration.Body.List...)
 				}
+
+				registration, err := buildAutometricsRegistrationStatement(ctx)
+				if err != nil {
+					inspectErr = errorAt(docComments[listIndex], fmt.Errorf("failed to build the registration statement for instrumentation: %w", err))
+					return false
+				}
+				registrations = append(registrations, &registration)
 			}
 		}
 
@@ -232,7 +333,11 @@ func GenerateDocumentationAndInstrumentation(ctx internal.GeneratorContext, sour
 	dst.Inspect(fileTree, fileWalk)
 
 	if inspectErr != nil {
-		return "", fmt.Errorf("error while transforming file in %v: %w", moduleName, inspectErr)
+		return "", inspectErr
+	}
+
+	if len(registrations) > 0 {
+		fileTree.Decls = append(fileTree.Decls, buildAutometricsInitFunction(registrations))
 	}
 
 	var buf strings.Builder
@@ -245,6 +350,55 @@ func GenerateDocumentationAndInstrumentation(ctx internal.GeneratorContext, sour
 	return buf.String(), nil
 }
 
+// implementationImportPath returns the quoted import path of the implementation
+// the generated code calls, or an empty string for an unknown implementation.
+//
+// The BackendImportPath of the context takes precedence over its Implementation.
+func implementationImportPath(ctx internal.GeneratorContext) string {
+	if ctx.BackendImportPath != "" {
+		return strconv.Quote(ctx.BackendImportPath)
+	}
+
+	switch ctx.Implementation {
+	case autometrics.PROMETHEUS:
+		return AmPromPackage
+	case autometrics.OTEL:
+		return AmOtelPackage
+	case autometrics.STATSD:
+		return AmStatsdPackage
+	default:
+		return ""
+	}
+}
+
+// defaultImportName returns the name of a package imported without an alias,
+// assuming that the package is named after the last element of its import path,
+// ignoring a major version suffix like "v2".
+func defaultImportName(quotedImportPath string) string {
+	importPath, err := strconv.Unquote(quotedImportPath)
+	if err != nil {
+		importPath = quotedImportPath
+	}
+
+	elements := strings.Split(importPath, "/")
+	name := elements[len(elements)-1]
+	if len(elements) > 1 && isMajorVersion(name) {
+		name = elements[len(elements)-2]
+	}
+
+	return name
+}
+
+// isMajorVersion reports whether the import path element is a major version suffix, like "v2".
+func isMajorVersion(element string) bool {
+	if len(element) < 2 || element[0] != 'v' {
+		return false
+	}
+
+	_, err := strconv.Atoi(element[1:])
+	return err == nil
+}
+
 func buildAutometricsContextNode(agc internal.GeneratorContext) (*dst.CallExpr, error) {
 	// Using https://github.com/dave/dst/issues/73 workaround
 
@@ -255,6 +409,26 @@ func buildAutometricsContextNode(agc internal.GeneratorContext) (*dst.CallExpr,
 		fmt.Sprintf("%v.WithCallerName(%#v)", agc.FuncCtx.ImplImportName, agc.RuntimeCtx.TrackCallerName),
 	)
 
+	if agc.RuntimeCtx.CallInfo.FuncName != "" {
+		options = append(options, fmt.Sprintf("%v.WithFunctionName(%q)", agc.FuncCtx.ImplImportName, agc.RuntimeCtx.CallInfo.FuncName))
+	}
+
+	if agc.RuntimeCtx.CallInfo.ModuleName != "" {
+		options = append(options, fmt.Sprintf("%v.WithModuleName(%q)", agc.FuncCtx.ImplImportName, agc.RuntimeCtx.CallInfo.ModuleName))
+	}
+
+	if agc.RuntimeCtx.TrackSpan {
+		options = append(options, fmt.Sprintf("%v.WithTracing(true)", agc.FuncCtx.ImplImportName))
+	}
+
+	if agc.RuntimeCtx.TrackProfileLabels {
+		options = append(options, fmt.Sprintf("%v.WithProfileLabels(true)", agc.FuncCtx.ImplImportName))
+	}
+
+	if agc.RuntimeCtx.TrackRuntimeTrace {
+		options = append(options, fmt.Sprintf("%v.WithRuntimeTrace(true)", agc.FuncCtx.ImplImportName))
+	}
+
 	if agc.RuntimeCtx.AlertConf != nil {
 		options = append(options, fmt.Sprintf("%v.WithSloName(%#v)",
 			agc.FuncCtx.ImplImportName,
@@ -274,6 +448,38 @@ func buildAutometricsContextNode(agc internal.GeneratorContext) (*dst.CallExpr,
 		}
 	}
 
+	for _, alertConf := range agc.RuntimeCtx.MoreAlertConfs {
+		objectiveOptions := []string{fmt.Sprintf("%#v", alertConf.ServiceName)}
+		if alertConf.Latency != nil {
+			objectiveOptions = append(objectiveOptions, fmt.Sprintf("%v.WithAlertLatency(%#v * time.Nanosecond, %#v)",
+				agc.FuncCtx.ImplImportName,
+				alertConf.Latency.Target,
+				alertConf.Latency.Objective,
+			))
+		}
+		if alertConf.Success != nil {
+			objectiveOptions = append(objectiveOptions, fmt.Sprintf("%v.WithAlertSuccess(%#v)",
+				agc.FuncCtx.ImplImportName,
+				alertConf.Success.Objective))
+		}
+		options = append(options, fmt.Sprintf("%v.WithObjective(%v)", agc.FuncCtx.ImplImportName, strings.Join(objectiveOptions, ", ")))
+	}
+
+	for _, label := range agc.FuncCtx.Labels {
+		options = append(options, fmt.Sprintf("%v.WithLabel(%q, %v)", agc.FuncCtx.ImplImportName, label.Name, label.Expression))
+	}
+
+	if agc.FuncCtx.ContextParameter != "" {
+		options = append(options, fmt.Sprintf("%v.WithContext(&%v)", agc.FuncCtx.ImplImportName, agc.FuncCtx.ContextParameter))
+	} else if agc.FuncCtx.RequestParameter != "" {
+		options = append(options, fmt.Sprintf("%v.WithRequest(&%v)", agc.FuncCtx.ImplImportName, agc.FuncCtx.RequestParameter))
+	}
+	// Without -context-caller, the context is only given for the pprof labels and the caller
+	// is still read from the stack frames.
+	if (agc.FuncCtx.ContextParameter != "" || agc.FuncCtx.RequestParameter != "") && !agc.ContextCaller {
+		options = append(options, fmt.Sprintf("%v.WithContextCaller(false)", agc.FuncCtx.ImplImportName))
+	}
+
 	var buf strings.Builder
 	_, err := fmt.Fprintf(&buf, `
 package main
@@ -348,17 +554,86 @@ func buildAutometricsDeferStatement(ctx internal.GeneratorContext, secondVar str
 	return statement, nil
 }
 
+// buildAutometricsRegistrationStatement builds the AST for the statement registering
+// the current function at init time.
+func buildAutometricsRegistrationStatement(ctx internal.GeneratorContext) (dst.ExprStmt, error) {
+	// The parameters of the function are not in scope in the init function.
+	ctx.FuncCtx.ContextParameter = ""
+	ctx.FuncCtx.RequestParameter = ""
+	ctx.FuncCtx.Labels = nil
+
+	contextArg, err := buildAutometricsContextNode(ctx)
+	if err != nil {
+		return dst.ExprStmt{}, fmt.Errorf("could not generate the runtime context value: %w", err)
+	}
+
+	statement := dst.ExprStmt{
+		X: &dst.CallExpr{
+			Fun: dst.NewIdent(fmt.Sprintf("%v.RegisterFunction", ctx.FuncCtx.ImplImportName)),
+			Args: []dst.Expr{
+				&dst.BasicLit{Kind: token.STRING, Value: strconv.Quote(ctx.FuncCtx.FunctionName)},
+				&dst.BasicLit{Kind: token.STRING, Value: strconv.Quote(ctx.FuncCtx.ModuleName)},
+				contextArg,
+			},
+		},
+	}
+
+	statement.Decs.Before = dst.NewLine
+	statement.Decs.After = dst.NewLine
+	return statement, nil
+}
+
+// buildAutometricsInitFunction builds the AST for the init function that registers all
+// the instrumented functions of the file.
+func buildAutometricsInitFunction(registrations []dst.Stmt) *dst.FuncDecl {
+	initFunction := &dst.FuncDecl{
+		Name: dst.NewIdent("init"),
+		Type: &dst.FuncType{},
+		Body: &dst.BlockStmt{
+			List: registrations,
+		},
+	}
+
+	initFunction.Decs.Before = dst.EmptyLine
+	initFunction.Decs.Start = []string{fmt.Sprintf("%s Generated registration of instrumented functions by Autometrics. DO NOT EDIT.", InitDirective)}
+	return initFunction
+}
+
+// isGeneratedInitFunction returns true if the declaration is an init function built by buildAutometricsInitFunction.
+func isGeneratedInitFunction(decl dst.Decl) bool {
+	funcDeclaration, ok := decl.(*dst.FuncDecl)
+	if !ok || funcDeclaration.Name.Name != "init" || funcDeclaration.Recv != nil {
+		return false
+	}
+
+	for _, comment := range funcDeclaration.Decorations().Start.All() {
+		if strings.HasPrefix(comment, InitDirective) {
+			return true
+		}
+	}
+
+	return false
+}
+
 func parseAutometricsFnContext(ctx *internal.GeneratorContext, commentGroup []string) error {
 	for i, comment := range commentGroup {
 		if args, found := cutPrefix(comment, "//autometrics:"); found {
 			ctx.FuncCtx.CommentIndex = i
 			ctx.RuntimeCtx = autometrics.NewContext()
+			ctx.FuncCtx.Labels = nil
 
 			tokens, err := shlex.Split(args)
 			if err != nil {
 				return fmt.Errorf("could not parse the directive arguments: %w", err)
 			}
-			tokenIndex := 0
+			directive := "//autometrics:"
+			if len(tokens) > 0 {
+				directive = directive + tokens[0]
+			}
+			if directive != DocDirective {
+				return fmt.Errorf("unknown directive %q%v", directive, suggestion(directive, []string{DocDirective}))
+			}
+			tokenIndex := 1
 			for tokenIndex < len(tokens) {
 				token := tokens[tokenIndex]
 				switch {
@@ -373,74 +648,56 @@ func parseAutometricsFnContext(ctx *internal.GeneratorContext, commentGroup []st
 						return fmt.Errorf("%v argument isn't allowed to start with '--'", SloNameArgument)
 					}
 
-					if ctx.RuntimeCtx.AlertConf != nil {
-						ctx.RuntimeCtx.AlertConf.ServiceName = value
-					} else {
-						ctx.RuntimeCtx.AlertConf = &autometrics.AlertConfiguration{
-							ServiceName: value,
-							Latency:     nil,
-							Success:     nil,
-						}
+					// Each --slo argument after the first one starts another objective.
+					if ctx.RuntimeCtx.AlertConf != nil && ctx.RuntimeCtx.AlertConf.ServiceName != "" {
+						ctx.RuntimeCtx.MoreAlertConfs = append(ctx.RuntimeCtx.MoreAlertConfs, autometrics.AlertConfiguration{})
 					}
+					alertConfiguration(&ctx.RuntimeCtx).ServiceName = value
 					// Advance past the "value"
 					tokenIndex = tokenIndex + 1
-				case token == SuccessObjArgument:
+				case token == SuccessObjArgument, token == SuccessRateArgument:
 					if tokenIndex >= len(tokens)-1 {
-						return fmt.Errorf("%v argument needs a value", SuccessObjArgument)
+						return fmt.Errorf("%v argument needs a value", token)
 					}
 					// Read the "value"
 					tokenIndex = tokenIndex + 1
-					value, err := strconv.ParseFloat(tokens[tokenIndex], 64)
+					value, err := parsePercentage(tokens[tokenIndex])
 					if err != nil {
-						return fmt.Errorf("%v argument must be a float between 0 and 100: %w", SuccessObjArgument, err)
+						return fmt.Errorf("%v argument is invalid: %w", token, err)
 					}
 
-					if ctx.RuntimeCtx.AlertConf != nil {
-						if ctx.RuntimeCtx.AlertConf.Success != nil {
-							ctx.RuntimeCtx.AlertConf.Success.Objective = value
-						} else {
-							ctx.RuntimeCtx.AlertConf.Success = &autometrics.SuccessSlo{Objective: value}
-						}
-					} else {
-						ctx.RuntimeCtx.AlertConf = &autometrics.AlertConfiguration{
-							ServiceName: "",
-							Latency:     nil,
-							Success:     &autometrics.SuccessSlo{Objective: value},
-						}
+					alertConf := alertConfiguration(&ctx.RuntimeCtx)
+					if alertConf.Success != nil {
+						return repeatedSloArgumentError(token)
 					}
+					alertConf.Success = &autometrics.SuccessSlo{Objective: value}
 					// Advance past the "value"
 					tokenIndex = tokenIndex + 1
-				case token == LatencyMsArgument:
+				case token == LatencyArgument, token == LatencyMsArgument:
 					if tokenIndex >= len(tokens)-1 {
-						return fmt.Errorf("%v argument needs a value", LatencyMsArgument)
+						return fmt.Errorf("%v argument needs a value", token)
 					}
 					// Read the "value"
 					tokenIndex = tokenIndex + 1
-					value, err := strconv.ParseFloat(tokens[tokenIndex], 64)
-					if err != nil {
-						return fmt.Errorf("%v argument must be a positive float", LatencyMsArgument)
-					}
-					timeValue := time.Duration(value * float64(time.Millisecond))
-
-					if ctx.RuntimeCtx.AlertConf != nil {
-						if ctx.RuntimeCtx.AlertConf.Latency != nil {
-							ctx.RuntimeCtx.AlertConf.Latency.Target = timeValue
-						} else {
-							ctx.RuntimeCtx.AlertConf.Latency = &autometrics.LatencySlo{
-								Target:    timeValue,
-								Objective: 0,
-							}
+					var timeValue time.Duration
+					if token == LatencyMsArgument {
+						value, err := strconv.ParseFloat(tokens[tokenIndex], 64)
+						if err != nil || value <= 0 {
+							return fmt.Errorf("%v argument must be a positive float", LatencyMsArgument)
 						}
+						timeValue = time.Duration(value * float64(time.Millisecond))
 					} else {
-						ctx.RuntimeCtx.AlertConf = &autometrics.AlertConfiguration{
-							ServiceName: "",
-							Latency: &autometrics.LatencySlo{
-								Target:    timeValue,
-								Objective: 0,
-							},
-							Success: nil,
+						timeValue, err = time.ParseDuration(tokens[tokenIndex])
+						if err != nil || timeValue <= 0 {
+							return fmt.Errorf("%v argument must be a positive duration, like 250ms or 1.5s, got %q", LatencyArgument, tokens[tokenIndex])
 						}
 					}
+
+					latency := latencySlo(&ctx.RuntimeCtx)
+					if latency.Target != 0 {
+						return repeatedSloArgumentError(token)
+					}
+					latency.Target = timeValue
 					// Advance past the "value"
 					tokenIndex = tokenIndex + 1
 				case token == LatencyObjArgument:
@@ -449,35 +706,78 @@ func parseAutometricsFnContext(ctx *internal.GeneratorContext, commentGroup []st
 					}
 					// Read the "value"
 					tokenIndex = tokenIndex + 1
-					value, err := strconv.ParseFloat(tokens[tokenIndex], 64)
+					value, err := parsePercentage(tokens[tokenIndex])
 					if err != nil {
-						return fmt.Errorf("%v argument must be a float between 0 and 1", LatencyObjArgument)
+						return fmt.Errorf("%v argument is invalid: %w", LatencyObjArgument, err)
 					}
 
-					if ctx.RuntimeCtx.AlertConf != nil {
-						if ctx.RuntimeCtx.AlertConf.Latency != nil {
-							ctx.RuntimeCtx.AlertConf.Latency.Objective = value
-						} else {
-							ctx.RuntimeCtx.AlertConf.Latency = &autometrics.LatencySlo{
-								Target:    0,
-								Objective: value,
-							}
-						}
-					} else {
-						ctx.RuntimeCtx.AlertConf = &autometrics.AlertConfiguration{
-							ServiceName: "",
-							Latency: &autometrics.LatencySlo{
-								Target:    0,
-								Objective: value,
-							},
-							Success: nil,
+					latency := latencySlo(&ctx.RuntimeCtx)
+					if latency.Objective != 0 {
+						return repeatedSloArgumentError(LatencyObjArgument)
+					}
+					latency.Objective = value
+					// Advance past the "value"
+					tokenIndex = tokenIndex + 1
+				case token == TraceArgument:
+					if ctx.Implementation != autometrics.OTEL && ctx.BackendImportPath == "" {
+						return fmt.Errorf("%v argument needs the OpenTelemetry implementation (the -otel flag)", TraceArgument)
+					}
+					ctx.RuntimeCtx.TrackSpan = true
+					tokenIndex = tokenIndex + 1
+				case token == ProfileLabelsArgument:
+					ctx.RuntimeCtx.TrackProfileLabels = true
+					tokenIndex = tokenIndex + 1
+				case token == RuntimeTraceArgument:
+					ctx.RuntimeCtx.TrackRuntimeTrace = true
+					tokenIndex = tokenIndex + 1
+				case token == LabelArgument:
+					if tokenIndex >= len(tokens)-1 {
+						return fmt.Errorf("%v argument needs a value", LabelArgument)
+					}
+					// Read the "value"
+					tokenIndex = tokenIndex + 1
+					name, expression, found := strings.Cut(tokens[tokenIndex], "=")
+					if !found || expression == "" {
+						return fmt.Errorf("%v argument must be of the form name=expression, got %q", LabelArgument, tokens[tokenIndex])
+					}
+					if err := autometrics.ValidateLabelName(name); err != nil {
+						return fmt.Errorf("%v argument is invalid: %w", LabelArgument, err)
+					}
+					for _, label := range ctx.FuncCtx.Labels {
+						if label.Name == name {
+							return fmt.Errorf("%v argument is invalid: the label %q is given more than once", LabelArgument, name)
 						}
 					}
+
+					ctx.FuncCtx.Labels = append(ctx.FuncCtx.Labels, internal.LabelExpression{Name: name, Expression: expression})
 					// Advance past the "value"
 					tokenIndex = tokenIndex + 1
-				default:
+				case token == NoConcurrencyArgument:
+					ctx.RuntimeCtx.TrackConcurrentCalls = false
+					tokenIndex = tokenIndex + 1
+				case token == NoCallerArgument:
+					ctx.RuntimeCtx.TrackCallerName = false
+					tokenIndex = tokenIndex + 1
+				case token == NameArgument, token == ModuleArgument:
+					if tokenIndex >= len(tokens)-1 {
+						return fmt.Errorf("%v argument needs a value", token)
+					}
+					// Read the "value"
+					tokenIndex = tokenIndex + 1
+					value := tokens[tokenIndex]
+					if value == "" || strings.HasPrefix(value, "--") {
+						return fmt.Errorf("%v argument must be a non-empty name that doesn't start with '--'", token)
+					}
+
+					if token == NameArgument {
+						ctx.RuntimeCtx.CallInfo.FuncName = value
+					} else {
+						ctx.RuntimeCtx.CallInfo.ModuleName = value
+					}
 					// Advance past the "value"
 					tokenIndex = tokenIndex + 1
+				default:
+					return fmt.Errorf("unknown argument %q%v", token, suggestion(token, directiveArguments))
 				}
 			}
 			err = ctx.RuntimeCtx.Validate(ctx.AllowCustomLatencies)
@@ -494,6 +794,47 @@ func parseAutometricsFnContext(ctx *internal.GeneratorContext, commentGroup []st
 	return nil
 }
 
+// alertConfiguration returns the objective the SLO arguments apply to, the one started by
+// the last --slo argument, creating it if needed.
+func alertConfiguration(ctx *autometrics.Context) *autometrics.AlertConfiguration {
+	if len(ctx.MoreAlertConfs) > 0 {
+		return &ctx.MoreAlertConfs[len(ctx.MoreAlertConfs)-1]
+	}
+
+	if ctx.AlertConf == nil {
+		ctx.AlertConf = &autometrics.AlertConfiguration{}
+	}
+
+	return ctx.AlertConf
+}
+
+// latencySlo returns the latency objective of the context, creating it if needed.
+func latencySlo(ctx *autometrics.Context) *autometrics.LatencySlo {
+	alertConf := alertConfiguration(ctx)
+	if alertConf.Latency == nil {
+		alertConf.Latency = &autometrics.LatencySlo{}
+	}
+
+	return alertConf.Latency
+}
+
+// repeatedSloArgumentError returns the error of an objective argument given twice
+// for the same SLO, which must rather start another objective.
+func repeatedSloArgumentError(argument string) error {
+	return fmt.Errorf("%v argument is given twice for the same SLO, start another objective with %v", argument, SloNameArgument)
+}
+
+// parsePercentage parses an objective given as a percentage, with or without the
+// percent sign: "99.9%" and "99.9" are both 99.9%.
+func parsePercentage(value string) (float64, error) {
+	percentage, err := strconv.ParseFloat(strings.TrimSuffix(value, "%"), 64)
+	if err != nil {
+		return 0, fmt.Errorf("it must be a percentage between 0 and 100, like 99.9%%, got %q", value)
+	}
+
+	return percentage, nil
+}
+
 // autometricsDocStartDirectives return the list of indices in the array where line is a comment start directive.
 func autometricsDocStartDirectives(commentGroup []string) []int {
 	var lines []int
@@ -558,6 +899,134 @@ func insertComments(inputArray []string, index int, values []string) []string {
 	return inputArray
 }
 
+// functionName returns the name of the function as reported by autometrics.CallerInfo at runtime,
+// which is "Type.Method" for methods.
+func functionName(funcNode *dst.FuncDecl) string {
+	if funcNode.Recv == nil || len(funcNode.Recv.List) == 0 {
+		return funcNode.Name.Name
+	}
+
+	receiverType := funcNode.Recv.List[0].Type
+	if star, ok := receiverType.(*dst.StarExpr); ok {
+		receiverType = star.X
+	}
+
+	// Generic receivers, like Cache[K] or Cache[K, V]
+	switch generic := receiverType.(type) {
+	case *dst.IndexExpr:
+		receiverType = generic.X
+	case *dst.IndexListExpr:
+		receiverType = generic.X
+	}
+
+	if ident, ok := receiverType.(*dst.Ident); ok {
+		return fmt.Sprintf("%s.%s", ident.Name, funcNode.Name.Name)
+	}
+
+	return funcNode.Name.Name
+}
+
+// callerContextParameters returns the names of the first context.Context and the first *http.Request
+// parameters of the function, or empty strings if there are none. Blank parameters are ignored.
+func callerContextParameters(funcNode *dst.FuncDecl, contextImportName, httpImportName string) (contextParam, requestParam string) {
+	for _, field := range funcNode.Type.Params.List {
+		name := ""
+		for _, ident := range field.Names {
+			if ident.Name != "_" {
+				name = ident.Name
+				break
+			}
+		}
+		if name == "" {
+			continue
+		}
+
+		if contextParam == "" && isQualifiedType(field.Type, contextImportName, "Context") {
+			contextParam = name
+		}
+
+		if star, ok := field.Type.(*dst.StarExpr); ok && requestParam == "" && isQualifiedType(star.X, httpImportName, "Request") {
+			requestParam = name
+		}
+	}
+
+	return
+}
+
+// checkLabelExpression returns an error if the expression is not valid Go, or does not use
+// any parameter or the receiver of the function, like "req.Tier" or "strings.ToLower(req.Tier)" do.
+//
+// The types are not checked: the compiler reports them in the generated code.
+func checkLabelExpression(funcNode *dst.FuncDecl, expression string) error {
+	expr, err := parser.ParseExpr(expression)
+	if err != nil {
+		return fmt.Errorf("%q is not a Go expression: %w", expression, err)
+	}
+
+	var fields []*dst.Field
+	if funcNode.Recv != nil {
+		fields = append(fields, funcNode.Recv.List...)
+	}
+	fields = append(fields, funcNode.Type.Params.List...)
+
+	var parameters []string
+	for _, field := range fields {
+		for _, name := range field.Names {
+			if name.Name != "_" {
+				parameters = append(parameters, name.Name)
+			}
+		}
+	}
+
+	// The selected names, like Tier in req.Tier, are fields or methods, not parameters.
+	selected := make(map[*ast.Ident]bool)
+	usesParameter := false
+	ast.Inspect(expr, func(n ast.Node) bool {
+		switch node := n.(type) {
+		case *ast.SelectorExpr:
+			selected[node.Sel] = true
+		case *ast.Ident:
+			if !selected[node] && slices.Contains(parameters, node.Name) {
+				usesParameter = true
+			}
+		}
+		return !usesParameter
+	})
+
+	if !usesParameter {
+		return fmt.Errorf("%q must use a parameter of the function (%v)", expression, strings.Join(parameters, ", "))
+	}
+
+	return nil
+}
+
+// commentPosition returns the position of the last comment with the given text before
+// the function, or the position of the function if there is none.
+func commentPosition(fileSet *token.FileSet, file *ast.File, function ast.Node, text string) token.Position {
+	pos := function.Pos()
+	for _, group := range file.Comments {
+		for _, comment := range group.List {
+			if comment.End() <= function.Pos() && comment.Text == text {
+				pos = comment.Pos()
+			}
+		}
+	}
+
+	return fileSet.Position(pos)
+}
+
+// isQualifiedType returns true if the expression is the packageName.typeName type.
+func isQualifiedType(expr dst.Expr, packageName, typeName string) bool {
+	selector, ok := expr.(*dst.SelectorExpr)
+	if !ok {
+		return false
+	}
+
+	packageIdent, ok := selector.X.(*dst.Ident)
+
+	return ok && packageIdent.Name == packageName && selector.Sel.Name == typeName
+}
+
 // errorReturnValueName returns the name of the error return value if it exists.
 func errorReturnValueName(funcNode *dst.FuncDecl) (string, error) {
 	returnValues := funcNode.Type.Results
@@ -583,6 +1052,15 @@ func errorReturnValueName(funcNode *dst.FuncDecl) (string, error) {
 	return "", nil
 }
 
+func filterDecls(decls []dst.Decl, test func(dst.Decl) bool) (ret []dst.Decl) {
+	for _, decl := range decls {
+		if test(decl) {
+			ret = append(ret, decl)
+		}
+	}
+	return
+}
+
 func filter(ss []string, test func(string) bool) (ret []string) {
 	for _, s := range ss {
 		if test(s) {
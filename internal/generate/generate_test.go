@@ -3,6 +3,7 @@ package generate
 import (
 	"fmt"
 	"go/token"
+	"net/url"
 	"strings"
 	"testing"
 	"time"
@@ -62,12 +63,12 @@ func main() {
 		"//\n" +
 		"//\tautometrics:doc-end Generated documentation by Autometrics.\n" +
 		"//\n" +
-		"// [Request Rate]: http://localhost:9090/graph?g0.expr=%23+Rate+of+calls+to+the+%60main%60+function+per+second%2C+averaged+over+5+minute+windows%0A%0Asum+by+%28function%2C+module%29+%28rate%28function_calls_count%7Bfunction%3D%22main%22%7D%5B5m%5D%29%29&g0.tab=0\n" +
-		"// [Error Ratio]: http://localhost:9090/graph?g0.expr=%23+Percentage+of+calls+to+the+%60main%60+function+that+return+errors%2C+averaged+over+5+minute+windows%0A%0Asum+by+%28function%2C+module%29+%28rate%28function_calls_count%7Bfunction%3D%22main%22%2Cresult%3D%22error%22%7D%5B5m%5D%29%29&g0.tab=0\n" +
-		"// [Latency (95th and 99th percentiles)]: http://localhost:9090/graph?g0.expr=%23+95th+and+99th+percentile+latencies+%28in+seconds%29+for+the+%60main%60+function%0A%0Ahistogram_quantile%280.99%2C+sum+by+%28le%2C+function%2C+module%29+%28rate%28function_calls_duration_bucket%7Bfunction%3D%22main%22%7D%5B5m%5D%29%29%29+or+histogram_quantile%280.95%2C+sum+by+%28le%2C+function%2C+module%29+%28rate%28function_calls_duration_bucket%7Bfunction%3D%22main%22%7D%5B5m%5D%29%29%29&g0.tab=0\n" +
-		"// [Concurrent Calls]: http://localhost:9090/graph?g0.expr=%23+Concurrent+calls+to+the+%60main%60+function%0A%0Asum+by+%28function%2C+module%29+function_calls_concurrent%7Bfunction%3D%22main%22%7D&g0.tab=0\n" +
-		"// [Request Rate Callee]: http://localhost:9090/graph?g0.expr=%23+Rate+of+function+calls+emanating+from+%60main%60+function+per+second%2C+averaged+over+5+minute+windows%0A%0Asum+by+%28function%2C+module%29+%28rate%28function_calls_count%7Bcaller%3D%22main.main%22%7D%5B5m%5D%29%29&g0.tab=0\n" +
-		"// [Error Ratio Callee]: http://localhost:9090/graph?g0.expr=%23+Percentage+of+function+emanating+from+%60main%60+function+that+return+errors%2C+averaged+over+5+minute+windows%0A%0Asum+by+%28function%2C+module%29+%28rate%28function_calls_count%7Bcaller%3D%22main.main%22%2Cresult%3D%22error%22%7D%5B5m%5D%29%29&g0.tab=0\n" +
+		"// [Request Rate]: http://localhost:9090/graph?g0.expr=%23+Rate+of+calls+to+the+%60main%60+function+per+second%2C+averaged+over+5+minute+windows%0A%0Asum+by+%28function%2C+module%2C+service_name%2C+version%2C+commit%29+%28rate%28function_calls_count%7Bfunction%3D%22main%22%2Cobjective_copy%3D%22%22%7D%5B5m%5D%29+%2A+on+%28instance%2C+job%29+group_left%28version%2C+commit%29+autometrics_build_info%29&g0.tab=0\n" +
+		"// [Error Ratio]: http://localhost:9090/graph?g0.expr=%23+Percentage+of+calls+to+the+%60main%60+function+that+return+errors%2C+averaged+over+5+minute+windows%0A%0Asum+by+%28function%2C+module%2C+service_name%2C+version%2C+commit%29+%28rate%28function_calls_count%7Bfunction%3D%22main%22%2Cobjective_copy%3D%22%22%2Cresult%3D%22error%22%7D%5B5m%5D%29+%2A+on+%28instance%2C+job%29+group_left%28version%2C+commit%29+autometrics_build_info%29&g0.tab=0\n" +
+		"// [Latency (95th and 99th percentiles)]: http://localhost:9090/graph?g0.expr=%23+95th+and+99th+percentile+latencies+%28in+seconds%29+for+the+%60main%60+function%0A%0Ahistogram_quantile%280.99%2C+sum+by+%28le%2C+function%2C+module%2C+service_name%2C+version%2C+commit%29+%28rate%28function_calls_duration_bucket%7Bfunction%3D%22main%22%2Cobjective_copy%3D%22%22%7D%5B5m%5D%29+%2A+on+%28instance%2C+job%29+group_left%28version%2C+commit%29+autometrics_build_info%29%29+or+histogram_quantile%280.95%2C+sum+by+%28le%2C+function%2C+module%2C+service_name%2C+version%2C+commit%29+%28rate%28function_calls_duration_bucket%7Bfunction%3D%22main%22%2Cobjective_copy%3D%22%22%7D%5B5m%5D%29+%2A+on+%28instance%2C+job%29+group_left%28version%2C+commit%29+autometrics_build_info%29%29&g0.tab=0\n" +
+		"// [Concurrent Calls]: http://localhost:9090/graph?g0.expr=%23+Concurrent+calls+to+the+%60main%60+function%0A%0Asum+by+%28function%2C+module%2C+service_name%2C+version%2C+commit%29+%28function_calls_concurrent%7Bfunction%3D%22main%22%7D+%2A+on+%28instance%2C+job%29+group_left%28version%2C+commit%29+autometrics_build_info%29&g0.tab=0\n" +
+		"// [Request Rate Callee]: http://localhost:9090/graph?g0.expr=%23+Rate+of+function+calls+emanating+from+%60main%60+function+per+second%2C+averaged+over+5+minute+windows%0A%0Asum+by+%28function%2C+module%2C+service_name%2C+version%2C+commit%29+%28rate%28function_calls_count%7Bcaller%3D%22main.main%22%2Cobjective_copy%3D%22%22%7D%5B5m%5D%29+%2A+on+%28instance%2C+job%29+group_left%28version%2C+commit%29+autometrics_build_info%29&g0.tab=0\n" +
+		"// [Error Ratio Callee]: http://localhost:9090/graph?g0.expr=%23+Percentage+of+function+emanating+from+%60main%60+function+that+return+errors%2C+averaged+over+5+minute+windows%0A%0Asum+by+%28function%2C+module%2C+service_name%2C+version%2C+commit%29+%28rate%28function_calls_count%7Bcaller%3D%22main.main%22%2Cobjective_copy%3D%22%22%2Cresult%3D%22error%22%7D%5B5m%5D%29+%2A+on+%28instance%2C+job%29+group_left%28version%2C+commit%29+autometrics_build_info%29&g0.tab=0\n" +
 		"//\n" +
 		"//autometrics:doc --slo \"Service Test\" --success-target 99\n" +
 		"func main() {\n" +
@@ -79,6 +80,16 @@ func main() {
 		"\t)), nil) //autometrics:defer\n" +
 		"\n" +
 		"	fmt.Println(hello) // line comment 3\n" +
+		"}\n" +
+		"\n" +
+		"//autometrics:init Generated registration of instrumented functions by Autometrics. DO NOT EDIT.\n" +
+		"func init() {\n" +
+		"\tprom.RegisterFunction(\"main\", \"main\", prom.NewContext(\n" +
+		"\t\tprom.WithConcurrentCalls(true),\n" +
+		"\t\tprom.WithCallerName(true),\n" +
+		"\t\tprom.WithSloName(\"Service Test\"),\n" +
+		"\t\tprom.WithAlertSuccess(99),\n" +
+		"\t))\n" +
 		"}\n"
 
 	ctx, err := internal.NewGeneratorContext(autometrics.PROMETHEUS, DefaultPrometheusInstanceUrl, false)
@@ -148,12 +159,12 @@ func main() {
 		"//\n" +
 		"//\tautometrics:doc-end Generated documentation by Autometrics.\n" +
 		"//\n" +
-		"// [Request Rate]: http://localhost:9090/graph?g0.expr=%23+Rate+of+calls+to+the+%60main%60+function+per+second%2C+averaged+over+5+minute+windows%0A%0Asum+by+%28function%2C+module%29+%28rate%28function_calls_count%7Bfunction%3D%22main%22%7D%5B5m%5D%29%29&g0.tab=0\n" +
-		"// [Error Ratio]: http://localhost:9090/graph?g0.expr=%23+Percentage+of+calls+to+the+%60main%60+function+that+return+errors%2C+averaged+over+5+minute+windows%0A%0Asum+by+%28function%2C+module%29+%28rate%28function_calls_count%7Bfunction%3D%22main%22%2Cresult%3D%22error%22%7D%5B5m%5D%29%29&g0.tab=0\n" +
-		"// [Latency (95th and 99th percentiles)]: http://localhost:9090/graph?g0.expr=%23+95th+and+99th+percentile+latencies+%28in+seconds%29+for+the+%60main%60+function%0A%0Ahistogram_quantile%280.99%2C+sum+by+%28le%2C+function%2C+module%29+%28rate%28function_calls_duration_bucket%7Bfunction%3D%22main%22%7D%5B5m%5D%29%29%29+or+histogram_quantile%280.95%2C+sum+by+%28le%2C+function%2C+module%29+%28rate%28function_calls_duration_bucket%7Bfunction%3D%22main%22%7D%5B5m%5D%29%29%29&g0.tab=0\n" +
-		"// [Concurrent Calls]: http://localhost:9090/graph?g0.expr=%23+Concurrent+calls+to+the+%60main%60+function%0A%0Asum+by+%28function%2C+module%29+function_calls_concurrent%7Bfunction%3D%22main%22%7D&g0.tab=0\n" +
-		"// [Request Rate Callee]: http://localhost:9090/graph?g0.expr=%23+Rate+of+function+calls+emanating+from+%60main%60+function+per+second%2C+averaged+over+5+minute+windows%0A%0Asum+by+%28function%2C+module%29+%28rate%28function_calls_count%7Bcaller%3D%22main.main%22%7D%5B5m%5D%29%29&g0.tab=0\n" +
-		"// [Error Ratio Callee]: http://localhost:9090/graph?g0.expr=%23+Percentage+of+function+emanating+from+%60main%60+function+that+return+errors%2C+averaged+over+5+minute+windows%0A%0Asum+by+%28function%2C+module%29+%28rate%28function_calls_count%7Bcaller%3D%22main.main%22%2Cresult%3D%22error%22%7D%5B5m%5D%29%29&g0.tab=0\n" +
+		"// [Request Rate]: http://localhost:9090/graph?g0.expr=%23+Rate+of+calls+to+the+%60main%60+function+per+second%2C+averaged+over+5+minute+windows%0A%0Asum+by+%28function%2C+module%2C+service_name%2C+version%2C+commit%29+%28rate%28function_calls_count%7Bfunction%3D%22main%22%2Cobjective_copy%3D%22%22%7D%5B5m%5D%29+%2A+on+%28instance%2C+job%29+group_left%28version%2C+commit%29+autometrics_build_info%29&g0.tab=0\n" +
+		"// [Error Ratio]: http://localhost:9090/graph?g0.expr=%23+Percentage+of+calls+to+the+%60main%60+function+that+return+errors%2C+averaged+over+5+minute+windows%0A%0Asum+by+%28function%2C+module%2C+service_name%2C+version%2C+commit%29+%28rate%28function_calls_count%7Bfunction%3D%22main%22%2Cobjective_copy%3D%22%22%2Cresult%3D%22error%22%7D%5B5m%5D%29+%2A+on+%28instance%2C+job%29+group_left%28version%2C+commit%29+autometrics_build_info%29&g0.tab=0\n" +
+		"// [Latency (95th and 99th percentiles)]: http://localhost:9090/graph?g0.expr=%23+95th+and+99th+percentile+latencies+%28in+seconds%29+for+the+%60main%60+function%0A%0Ahistogram_quantile%280.99%2C+sum+by+%28le%2C+function%2C+module%2C+service_name%2C+version%2C+commit%29+%28rate%28function_calls_duration_bucket%7Bfunction%3D%22main%22%2Cobjective_copy%3D%22%22%7D%5B5m%5D%29+%2A+on+%28instance%2C+job%29+group_left%28version%2C+commit%29+autometrics_build_info%29%29+or+histogram_quantile%280.95%2C+sum+by+%28le%2C+function%2C+module%2C+service_name%2C+version%2C+commit%29+%28rate%28function_calls_duration_bucket%7Bfunction%3D%22main%22%2Cobjective_copy%3D%22%22%7D%5B5m%5D%29+%2A+on+%28instance%2C+job%29+group_left%28version%2C+commit%29+autometrics_build_info%29%29&g0.tab=0\n" +
+		"// [Concurrent Calls]: http://localhost:9090/graph?g0.expr=%23+Concurrent+calls+to+the+%60main%60+function%0A%0Asum+by+%28function%2C+module%2C+service_name%2C+version%2C+commit%29+%28function_calls_concurrent%7Bfunction%3D%22main%22%7D+%2A+on+%28instance%2C+job%29+group_left%28version%2C+commit%29+autometrics_build_info%29&g0.tab=0\n" +
+		"// [Request Rate Callee]: http://localhost:9090/graph?g0.expr=%23+Rate+of+function+calls+emanating+from+%60main%60+function+per+second%2C+averaged+over+5+minute+windows%0A%0Asum+by+%28function%2C+module%2C+service_name%2C+version%2C+commit%29+%28rate%28function_calls_count%7Bcaller%3D%22main.main%22%2Cobjective_copy%3D%22%22%7D%5B5m%5D%29+%2A+on+%28instance%2C+job%29+group_left%28version%2C+commit%29+autometrics_build_info%29&g0.tab=0\n" +
+		"// [Error Ratio Callee]: http://localhost:9090/graph?g0.expr=%23+Percentage+of+function+emanating+from+%60main%60+function+that+return+errors%2C+averaged+over+5+minute+windows%0A%0Asum+by+%28function%2C+module%2C+service_name%2C+version%2C+commit%29+%28rate%28function_calls_count%7Bcaller%3D%22main.main%22%2Cobjective_copy%3D%22%22%2Cresult%3D%22error%22%7D%5B5m%5D%29+%2A+on+%28instance%2C+job%29+group_left%28version%2C+commit%29+autometrics_build_info%29&g0.tab=0\n" +
 		"//\n" +
 		"//autometrics:doc --slo \"API\" --latency-target 99.9 --latency-ms 500\n" +
 		"func main() {\n" +
@@ -165,6 +176,16 @@ func main() {
 		"\t)), nil) //autometrics:defer\n" +
 		"\n" +
 		"	fmt.Println(hello) // line comment 3\n" +
+		"}\n" +
+		"\n" +
+		"//autometrics:init Generated registration of instrumented functions by Autometrics. DO NOT EDIT.\n" +
+		"func init() {\n" +
+		"\tprom.RegisterFunction(\"main\", \"main\", prom.NewContext(\n" +
+		"\t\tprom.WithConcurrentCalls(true),\n" +
+		"\t\tprom.WithCallerName(true),\n" +
+		"\t\tprom.WithSloName(\"API\"),\n" +
+		"\t\tprom.WithAlertLatency(500000000*time.Nanosecond, 99.9),\n" +
+		"\t))\n" +
 		"}\n"
 
 	ctx, err := internal.NewGeneratorContext(autometrics.PROMETHEUS, DefaultPrometheusInstanceUrl, false)
@@ -180,6 +201,773 @@ func main() {
 	assert.Equal(t, want, actual, "The generated source code is not as expected.")
 }
 
+// TestInitRegistrationRefresh calls GenerateDocumentationAndInstrumentation on
+// already generated code, making sure that the init function registering the
+// instrumented functions is replaced and not duplicated.
+func TestInitRegistrationRefresh(t *testing.T) {
+	sourceCode := `// This is the package comment.
+package main
+
+import (
+	prom "github.com/autometrics-dev/autometrics-go/pkg/autometrics/prometheus"
+)
+
+//autometrics:doc
+func main() {
+	fmt.Println(hello)
+}
+
+//autometrics:doc --slo "API" --success-target 99
+func other() {
+	fmt.Println(hello)
+}
+
+//autometrics:init Generated registration of instrumented functions by Autometrics. DO NOT EDIT.
+func init() {
+	prom.RegisterFunction("removed", "main", prom.NewContext())
+}
+`
+
+	want := "// This is the package comment.\n" +
+		"package main\n" +
+		"\n" +
+		"import (\n" +
+		"\tprom \"github.com/autometrics-dev/autometrics-go/pkg/autometrics/prometheus\"\n" +
+		")\n" +
+		"\n" +
+		"//autometrics:doc\n" +
+		"func main() {\n" +
+		"\tdefer prom.Instrument(prom.PreInstrument(prom.NewContext(\n" +
+		"\t\tprom.WithConcurrentCalls(true),\n" +
+		"\t\tprom.WithCallerName(true),\n" +
+		"\t)), nil) //autometrics:defer\n" +
+		"\n" +
+		"\tfmt.Println(hello)\n" +
+		"}\n" +
+		"\n" +
+		"//autometrics:doc --slo \"API\" --success-target 99\n" +
+		"func other() {\n" +
+		"\tdefer prom.Instrument(prom.PreInstrument(prom.NewContext(\n" +
+		"\t\tprom.WithConcurrentCalls(true),\n" +
+		"\t\tprom.WithCallerName(true),\n" +
+		"\t\tprom.WithSloName(\"API\"),\n" +
+		"\t\tprom.WithAlertSuccess(99),\n" +
+		"\t)), nil) //autometrics:defer\n" +
+		"\n" +
+		"\tfmt.Println(hello)\n" +
+		"}\n" +
+		"\n" +
+		"//autometrics:init Generated registration of instrumented functions by Autometrics. DO NOT EDIT.\n" +
+		"func init() {\n" +
+		"\tprom.RegisterFunction(\"main\", \"main\", prom.NewContext(\n" +
+		"\t\tprom.WithConcurrentCalls(true),\n" +
+		"\t\tprom.WithCallerName(true),\n" +
+		"\t))\n" +
+		"\tprom.RegisterFunction(\"other\", \"main\", prom.NewContext(\n" +
+		"\t\tprom.WithConcurrentCalls(true),\n" +
+		"\t\tprom.WithCallerName(true),\n" +
+		"\t\tprom.WithSloName(\"API\"),\n" +
+		"\t\tprom.WithAlertSuccess(99),\n" +
+		"\t))\n" +
+		"}\n"
+
+	ctx, err := internal.NewGeneratorContext(autometrics.PROMETHEUS, "", false)
+	if err != nil {
+		t.Fatalf("error creating the generation context: %s", err)
+	}
+
+	actual, err := GenerateDocumentationAndInstrumentation(ctx, sourceCode, "main")
+	if err != nil {
+		t.Fatalf("error generating the documentation: %s", err)
+	}
+
+	assert.Equal(t, want, actual, "The generated source code is not as expected.")
+
+	actual, err = GenerateDocumentationAndInstrumentation(ctx, actual, "main")
+	if err != nil {
+		t.Fatalf("error generating the documentation a second time: %s", err)
+	}
+
+	assert.Equal(t, want, actual, "Generating the code a second time must not change the source code.")
+}
+
+// TestSpecNamingLinks calls GenerateDocumentationAndInstrumentation with the
+// specification naming scheme, and checks that the documentation links query
+// the metrics and labels of the Autometrics specification.
+func TestSpecNamingLinks(t *testing.T) {
+	sourceCode := `// This is the package comment.
+package main
+
+import (
+	prom "github.com/autometrics-dev/autometrics-go/pkg/autometrics/prometheus"
+)
+
+//autometrics:doc
+func main() {
+	fmt.Println(hello)
+}
+`
+
+	ctx, err := internal.NewGeneratorContext(autometrics.PROMETHEUS, DefaultPrometheusInstanceUrl, false)
+	if err != nil {
+		t.Fatalf("error creating the generation context: %s", err)
+	}
+	ctx.NamingScheme = autometrics.SpecNaming
+
+	actual, err := GenerateDocumentationAndInstrumentation(ctx, sourceCode, "main")
+	if err != nil {
+		t.Fatalf("error generating the documentation: %s", err)
+	}
+
+	decoded, err := url.QueryUnescape(actual)
+	if err != nil {
+		t.Fatalf("error decoding the generated links: %s", err)
+	}
+
+	assert.Contains(t, decoded, `rate(function_calls_total{function="main",objective_copy=""}[5m])`, "The request rate link must use the specification counter name.")
+	assert.Contains(t, decoded, `rate(function_calls_duration_seconds_bucket{function="main",objective_copy=""}[5m])`, "The latency link must use the specification histogram name.")
+	assert.Contains(t, decoded, `rate(function_calls_total{caller_function="main",caller_module="main",objective_copy=""}[5m])`, "The callee links must use the specification caller labels.")
+	assert.Contains(t, decoded, "group_left(version, commit) build_info", "The links must use the specification build information metric.")
+	assert.NotContains(t, decoded, "function_calls_count", "The links must not use the legacy counter name.")
+}
+
+// TestContextCallerOptions calls GenerateDocumentationAndInstrumentation with
+// the context caller mode, and checks that the context.Context and *http.Request
+// parameters are given to the instrumentation, but not to the registration.
+func TestContextCallerOptions(t *testing.T) {
+	sourceCode := `// This is the package comment.
+package main
+
+import (
+	stdctx "context"
+	"net/http"
+
+	prom "github.com/autometrics-dev/autometrics-go/pkg/autometrics/prometheus"
+)
+
+//autometrics:doc
+func withContext(_ int, ctx stdctx.Context, r *http.Request) {
+	fmt.Println(hello)
+}
+
+//autometrics:doc
+func withRequest(w http.ResponseWriter, r *http.Request) {
+	fmt.Println(hello)
+}
+
+//autometrics:doc
+func withBlankRequest(w http.ResponseWriter, _ *http.Request) {
+	fmt.Println(hello)
+}
+`
+
+	want := "// This is the package comment.\n" +
+		"package main\n" +
+		"\n" +
+		"import (\n" +
+		"\tstdctx \"context\"\n" +
+		"\t\"net/http\"\n" +
+		"\n" +
+		"\tprom \"github.com/autometrics-dev/autometrics-go/pkg/autometrics/prometheus\"\n" +
+		")\n" +
+		"\n" +
+		"//autometrics:doc\n" +
+		"func withContext(_ int, ctx stdctx.Context, r *http.Request) {\n" +
+		"\tdefer prom.Instrument(prom.PreInstrument(prom.NewContext(\n" +
+		"\t\tprom.WithConcurrentCalls(true),\n" +
+		"\t\tprom.WithCallerName(true),\n" +
+		"\t\tprom.WithContext(&ctx),\n" +
+		"\t)), nil) //autometrics:defer\n" +
+		"\n" +
+		"\tfmt.Println(hello)\n" +
+		"}\n" +
+		"\n" +
+		"//autometrics:doc\n" +
+		"func withRequest(w http.ResponseWriter, r *http.Request) {\n" +
+		"\tdefer prom.Instrument(prom.PreInstrument(prom.NewContext(\n" +
+		"\t\tprom.WithConcurrentCalls(true),\n" +
+		"\t\tprom.WithCallerName(true),\n" +
+		"\t\tprom.WithRequest(&r),\n" +
+		"\t)), nil) //autometrics:defer\n" +
+		"\n" +
+		"\tfmt.Println(hello)\n" +
+		"}\n" +
+		"\n" +
+		"//autometrics:doc\n" +
+		"func withBlankRequest(w http.ResponseWriter, _ *http.Request) {\n" +
+		"\tdefer prom.Instrument(prom.PreInstrument(prom.NewContext(\n" +
+		"\t\tprom.WithConcurrentCalls(true),\n" +
+		"\t\tprom.WithCallerName(true),\n" +
+		"\t)), nil) //autometrics:defer\n" +
+		"\n" +
+		"\tfmt.Println(hello)\n" +
+		"}\n" +
+		"\n" +
+		"//autometrics:init Generated registration of instrumented functions by Autometrics. DO NOT EDIT.\n" +
+		"func init() {\n" +
+		"\tprom.RegisterFunction(\"withContext\", \"main\", prom.NewContext(\n" +
+		"\t\tprom.WithConcurrentCalls(true),\n" +
+		"\t\tprom.WithCallerName(true),\n" +
+		"\t))\n" +
+		"\tprom.RegisterFunction(\"withRequest\", \"main\", prom.NewContext(\n" +
+		"\t\tprom.WithConcurrentCalls(true),\n" +
+		"\t\tprom.WithCallerName(true),\n" +
+		"\t))\n" +
+		"\tprom.RegisterFunction(\"withBlankRequest\", \"main\", prom.NewContext(\n" +
+		"\t\tprom.WithConcurrentCalls(true),\n" +
+		"\t\tprom.WithCallerName(true),\n" +
+		"\t))\n" +
+		"}\n"
+
+	ctx, err := internal.NewGeneratorContext(autometrics.PROMETHEUS, "", false)
+	if err != nil {
+		t.Fatalf("error creating the generation context: %s", err)
+	}
+	ctx.ContextCaller = true
+
+	actual, err := GenerateDocumentationAndInstrumentation(ctx, sourceCode, "main")
+	if err != nil {
+		t.Fatalf("error generating the documentation: %s", err)
+	}
+
+	assert.Equal(t, want, actual, "The generated source code is not as expected.")
+}
+
+// TestMethodFunctionName calls GenerateDocumentationAndInstrumentation on
+// methods, and checks that they are named "Type.Method" like autometrics.CallerInfo
+// names them at runtime.
+func TestMethodFunctionName(t *testing.T) {
+	sourceCode := `// This is the package comment.
+package main
+
+import (
+	prom "github.com/autometrics-dev/autometrics-go/pkg/autometrics/prometheus"
+)
+
+//autometrics:doc
+func (s *Server) Serve() {
+	fmt.Println(hello)
+}
+
+//autometrics:doc
+func (c Cache[K, V]) Get() {
+	fmt.Println(hello)
+}
+`
+
+	ctx, err := internal.NewGeneratorContext(autometrics.PROMETHEUS, DefaultPrometheusInstanceUrl, false)
+	if err != nil {
+		t.Fatalf("error creating the generation context: %s", err)
+	}
+
+	actual, err := GenerateDocumentationAndInstrumentation(ctx, sourceCode, "main")
+	if err != nil {
+		t.Fatalf("error generating the documentation: %s", err)
+	}
+
+	decoded, err := url.QueryUnescape(actual)
+	if err != nil {
+		t.Fatalf("error decoding the generated links: %s", err)
+	}
+
+	assert.Contains(t, actual, `prom.RegisterFunction("Server.Serve", "main"`, "A pointer receiver method must be registered as Type.Method.")
+	assert.Contains(t, actual, `prom.RegisterFunction("Cache.Get", "main"`, "A generic receiver method must be registered as Type.Method.")
+	assert.Contains(t, decoded, `function="Server.Serve"`, "The links must query the method as Type.Method.")
+	assert.Contains(t, decoded, `caller="main.Cache.Get"`, "The callee links must query the method as Type.Method.")
+}
+
+// TestStatsdImplementation calls GenerateDocumentationAndInstrumentation with the
+// statsd implementation, and checks that the generated calls use its import.
+func TestStatsdImplementation(t *testing.T) {
+	sourceCode := `// This is the package comment.
+package main
+
+import (
+	"github.com/autometrics-dev/autometrics-go/pkg/autometrics/statsd"
+	prom "github.com/autometrics-dev/autometrics-go/pkg/autometrics/prometheus"
+)
+
+//autometrics:doc
+func main() {
+	fmt.Println(hello)
+}
+`
+
+	ctx, err := internal.NewGeneratorContext(autometrics.STATSD, DefaultPrometheusInstanceUrl, false)
+	if err != nil {
+		t.Fatalf("error creating the generation context: %s", err)
+	}
+
+	actual, err := GenerateDocumentationAndInstrumentation(ctx, sourceCode, "main")
+	if err != nil {
+		t.Fatalf("error generating the documentation: %s", err)
+	}
+
+	assert.Contains(t, actual, "defer statsd.Instrument(statsd.PreInstrument(statsd.NewContext(", "The instrumentation must use the statsd import.")
+	assert.Contains(t, actual, `statsd.RegisterFunction("main", "main"`, "The registration must use the statsd import.")
+	assert.NotContains(t, actual, "prom.", "The instrumentation must not use the prometheus import.")
+
+	ctx, err = internal.NewGeneratorContext(autometrics.STATSD, DefaultPrometheusInstanceUrl, false)
+	if err != nil {
+		t.Fatalf("error creating the generation context: %s", err)
+	}
+
+	_, err = GenerateDocumentationAndInstrumentation(ctx, strings.Replace(sourceCode, "\t\"github.com/autometrics-dev/autometrics-go/pkg/autometrics/statsd\"\n", "", 1), "main")
+	assert.ErrorContains(t, err, AmStatsdPackage, "A missing statsd import must be reported.")
+}
+
+// TestCustomBackend calls GenerateDocumentationAndInstrumentation with the import
+// path of a custom implementation, and checks that the generated calls use its import.
+func TestCustomBackend(t *testing.T) {
+	sourceCode := `// This is the package comment.
+package main
+
+import (
+	"github.com/acme/ourmetrics/v2"
+)
+
+//autometrics:doc
+func main() {
+	fmt.Println(hello)
+}
+`
+
+	ctx, err := internal.NewGeneratorContext(autometrics.PROMETHEUS, DefaultPrometheusInstanceUrl, false)
+	if err != nil {
+		t.Fatalf("error creating the generation context: %s", err)
+	}
+	ctx.BackendImportPath = "github.com/acme/ourmetrics/v2"
+
+	actual, err := GenerateDocumentationAndInstrumentation(ctx, sourceCode, "main")
+	if err != nil {
+		t.Fatalf("error generating the documentation: %s", err)
+	}
+
+	assert.Contains(t, actual, "defer ourmetrics.Instrument(ourmetrics.PreInstrument(ourmetrics.NewContext(", "The instrumentation must use the custom import.")
+	assert.Contains(t, actual, `ourmetrics.RegisterFunction("main", "main"`, "The registration must use the custom import.")
+
+	ctx.BackendImportPath = "github.com/acme/othermetrics"
+	_, err = GenerateDocumentationAndInstrumentation(ctx, sourceCode, "main")
+	assert.ErrorContains(t, err, `"github.com/acme/othermetrics"`, "A missing custom import must be reported.")
+}
+
+// TestTraceDirective calls GenerateDocumentationAndInstrumentation with the --trace
+// argument, and checks that the spans are only enabled with the OpenTelemetry implementation.
+func TestTraceDirective(t *testing.T) {
+	sourceCode := `// This is the package comment.
+package main
+
+import (
+	amotel "github.com/autometrics-dev/autometrics-go/pkg/autometrics/otel"
+)
+
+//autometrics:doc --trace
+func main() {
+	fmt.Println(hello)
+}
+`
+
+	ctx, err := internal.NewGeneratorContext(autometrics.OTEL, DefaultPrometheusInstanceUrl, false)
+	if err != nil {
+		t.Fatalf("error creating the generation context: %s", err)
+	}
+
+	actual, err := GenerateDocumentationAndInstrumentation(ctx, sourceCode, "main")
+	if err != nil {
+		t.Fatalf("error generating the documentation: %s", err)
+	}
+
+	assert.Contains(t, actual, "amotel.WithTracing(true),", "The instrumentation must start a span.")
+
+	ctx, err = internal.NewGeneratorContext(autometrics.PROMETHEUS, DefaultPrometheusInstanceUrl, false)
+	if err != nil {
+		t.Fatalf("error creating the generation context: %s", err)
+	}
+
+	promSourceCode := strings.Replace(sourceCode, `amotel "github.com/autometrics-dev/autometrics-go/pkg/autometrics/otel"`, `prom "github.com/autometrics-dev/autometrics-go/pkg/autometrics/prometheus"`, 1)
+	_, err = GenerateDocumentationAndInstrumentation(ctx, promSourceCode, "main")
+	assert.ErrorContains(t, err, TraceArgument, "The --trace argument must be rejected without the OpenTelemetry implementation.")
+}
+
+// TestProfileLabelsDirective calls GenerateDocumentationAndInstrumentation with the
+// --profile-labels argument, and checks that the pprof labels are enabled, with the
+// context of the function to restore the labels of the caller, without tracking the
+// caller through this context outside of the -context-caller mode.
+func TestProfileLabelsDirective(t *testing.T) {
+	sourceCode := `// This is the package comment.
+package main
+
+import (
+	"context"
+
+	prom "github.com/autometrics-dev/autometrics-go/pkg/autometrics/prometheus"
+)
+
+//autometrics:doc --profile-labels
+func main() {
+	fmt.Println(hello)
+}
+
+//autometrics:doc --profile-labels
+func handler(ctx context.Context) error {
+	return nil
+}
+
+//autometrics:doc
+func other(ctx context.Context) error {
+	return nil
+}
+`
+
+	ctx, err := internal.NewGeneratorContext(autometrics.PROMETHEUS, DefaultPrometheusInstanceUrl, false)
+	if err != nil {
+		t.Fatalf("error creating the generation context: %s", err)
+	}
+
+	actual, err := GenerateDocumentationAndInstrumentation(ctx, sourceCode, "main")
+	if err != nil {
+		t.Fatalf("error generating the documentation: %s", err)
+	}
+
+	assert.Contains(t, actual, "prom.WithProfileLabels(true),", "The instrumentation must apply the pprof labels.")
+	assert.Equal(t, 1, strings.Count(actual, "prom.WithContext(&ctx),"),
+		"Only the functions with pprof labels must give their context without the -context-caller mode.")
+	assert.Equal(t, 1, strings.Count(actual, "prom.WithContextCaller(false),"),
+		"The caller must still be read from the stack frames without the -context-caller mode.")
+
+	ctx.ContextCaller = true
+	actual, err = GenerateDocumentationAndInstrumentation(ctx, sourceCode, "main")
+	if err != nil {
+		t.Fatalf("error generating the documentation: %s", err)
+	}
+
+	assert.Equal(t, 2, strings.Count(actual, "prom.WithContext(&ctx),"))
+	assert.NotContains(t, actual, "prom.WithContextCaller(false)", "The caller must be tracked through the context in the -context-caller mode.")
+}
+
+// TestRuntimeTraceDirective calls GenerateDocumentationAndInstrumentation with the
+// --runtime-trace argument, and checks that the runtime/trace regions are enabled, with
+// the context of the function to open a task.
+func TestRuntimeTraceDirective(t *testing.T) {
+	sourceCode := `// This is the package comment.
+package main
+
+import (
+	"context"
+
+	prom "github.com/autometrics-dev/autometrics-go/pkg/autometrics/prometheus"
+)
+
+//autometrics:doc --runtime-trace
+func main() {
+	fmt.Println(hello)
+}
+
+//autometrics:doc --runtime-trace
+func handler(ctx context.Context) error {
+	return nil
+}
+`
+
+	ctx, err := internal.NewGeneratorContext(autometrics.PROMETHEUS, DefaultPrometheusInstanceUrl, false)
+	if err != nil {
+		t.Fatalf("error creating the generation context: %s", err)
+	}
+
+	actual, err := GenerateDocumentationAndInstrumentation(ctx, sourceCode, "main")
+	if err != nil {
+		t.Fatalf("error generating the documentation: %s", err)
+	}
+
+	assert.Contains(t, actual, "prom.WithRuntimeTrace(true),", "The instrumentation must open a runtime/trace region.")
+	assert.Equal(t, 1, strings.Count(actual, "prom.WithContext(&ctx),"),
+		"The functions with a context must give it to open a runtime/trace task without the -context-caller mode.")
+	assert.Equal(t, 1, strings.Count(actual, "prom.WithContextCaller(false),"),
+		"The caller must still be read from the stack frames without the -context-caller mode.")
+}
+
+// TestLabelDirective calls GenerateDocumentationAndInstrumentation with --label
+// arguments, and checks that the expressions are passed to the instrumentation
+// only if they use the parameters of the function.
+func TestLabelDirective(t *testing.T) {
+	sourceCode := `// This is the package comment.
+package main
+
+import (
+	prom "github.com/autometrics-dev/autometrics-go/pkg/autometrics/prometheus"
+)
+
+//autometrics:doc --label tier=req.Tier --label "version=strings.ToLower(req.Header.Get(\"Version\"))"
+func handler(req *Request) {
+	fmt.Println(hello)
+}
+`
+
+	ctx, err := internal.NewGeneratorContext(autometrics.PROMETHEUS, DefaultPrometheusInstanceUrl, false)
+	if err != nil {
+		t.Fatalf("error creating the generation context: %s", err)
+	}
+
+	actual, err := GenerateDocumentationAndInstrumentation(ctx, sourceCode, "main")
+	if err != nil {
+		t.Fatalf("error generating the documentation: %s", err)
+	}
+
+	assert.Contains(t, actual, "prom.WithLabel(\"tier\", req.Tier),", "The label must be passed to the instrumentation.")
+	assert.Contains(t, actual, "prom.WithLabel(\"version\", strings.ToLower(req.Header.Get(\"Version\"))),", "The label must be passed to the instrumentation.")
+	assert.Equal(t, 2, strings.Count(actual, "WithLabel("), "The labels must not be in the registration, where the parameters are not in scope.")
+
+	invalidDirectives := map[string]string{
+		"--label tier=Tier":                     "must use a parameter",
+		"--label tier=request.Tier":             "must use a parameter",
+		"--label tier=req.Tier(":                "not a Go expression",
+		"--label tier":                          "name=expression",
+		"--label 1tier=req.Tier":                "invalid label name",
+		"--label tier=req.A --label tier=req.B": "more than once",
+	}
+	for directive, expectedError := range invalidDirectives {
+		_, err := GenerateDocumentationAndInstrumentation(ctx, strings.Replace(sourceCode, `--label tier=req.Tier --label "version=strings.ToLower(req.Header.Get(\"Version\"))"`, directive, 1), "main")
+		assert.ErrorContains(t, err, expectedError, "The directive %q must be rejected.", directive)
+	}
+}
+
+// TestTrackingAndNameDirectives calls GenerateDocumentationAndInstrumentation with the
+// arguments that disable the tracking of the calls and replace the names of the function,
+// and checks that both the instrumentation and the links use them.
+func TestTrackingAndNameDirectives(t *testing.T) {
+	sourceCode := `// This is the package comment.
+package main
+
+import (
+	prom "github.com/autometrics-dev/autometrics-go/pkg/autometrics/prometheus"
+)
+
+//autometrics:doc --no-concurrency --no-caller --name checkout --module shop
+func main() {
+	fmt.Println(hello)
+}
+`
+
+	ctx, err := internal.NewGeneratorContext(autometrics.PROMETHEUS, DefaultPrometheusInstanceUrl, false)
+	if err != nil {
+		t.Fatalf("error creating the generation context: %s", err)
+	}
+
+	actual, err := GenerateDocumentationAndInstrumentation(ctx, sourceCode, "main")
+	if err != nil {
+		t.Fatalf("error generating the documentation: %s", err)
+	}
+
+	assert.Contains(t, actual, "prom.WithConcurrentCalls(false),", "The concurrent calls must not be tracked.")
+	assert.Contains(t, actual, "prom.WithCallerName(false),", "The caller must not be tracked.")
+	assert.Equal(t, 2, strings.Count(actual, "prom.WithFunctionName(\"checkout\"),"), "The name must be passed to the instrumentation and the registration.")
+	assert.Equal(t, 2, strings.Count(actual, "prom.WithModuleName(\"shop\"),"), "The module must be passed to the instrumentation and the registration.")
+	assert.Contains(t, actual, "prom.RegisterFunction(\"checkout\", \"shop\",", "The function must be registered under its new names.")
+	assert.Contains(t, actual, "// View the live metrics for the `checkout` function:", "The links must use the new name.")
+	assert.NotContains(t, actual, "[Concurrent Calls]", "The concurrent calls link must be hidden.")
+
+	for _, directive := range []string{"--name", "--module --no-caller", "--name \"\""} {
+		_, err := GenerateDocumentationAndInstrumentation(ctx, strings.Replace(sourceCode, "--no-concurrency --no-caller --name checkout --module shop", directive, 1), "main")
+		assert.Error(t, err, "The directive %q must be rejected.", directive)
+	}
+}
+
+// TestNameDirectivesCalleeLinks calls GenerateDocumentationAndInstrumentation with the
+// --name and --module arguments, and checks that the callee links select the names that
+// the callees report as their caller: the names of the source code when the callees read
+// their caller from the stack frames, and the new names in the -context-caller mode.
+func TestNameDirectivesCalleeLinks(t *testing.T) {
+	sourceCode := `// This is the package comment.
+package main
+
+import (
+	"context"
+
+	prom "github.com/autometrics-dev/autometrics-go/pkg/autometrics/prometheus"
+)
+
+//autometrics:doc --name checkout --module shop
+func handler(ctx context.Context) error {
+	return nil
+}
+`
+
+	ctx, err := internal.NewGeneratorContext(autometrics.PROMETHEUS, DefaultPrometheusInstanceUrl, false)
+	if err != nil {
+		t.Fatalf("error creating the generation context: %s", err)
+	}
+
+	actual, err := GenerateDocumentationAndInstrumentation(ctx, sourceCode, "main")
+	if err != nil {
+		t.Fatalf("error generating the documentation: %s", err)
+	}
+
+	decoded, err := url.QueryUnescape(actual)
+	if err != nil {
+		t.Fatalf("error decoding the generated links: %s", err)
+	}
+
+	assert.Contains(t, decoded, `rate(function_calls_count{function="checkout",objective_copy=""}[5m])`, "The function links must use the new name.")
+	assert.Contains(t, decoded, `rate(function_calls_count{caller="main.handler",objective_copy=""}[5m])`,
+		"The callee links must use the names of the source code, reported by the stack frames.")
+	assert.NotContains(t, decoded, `caller="shop.checkout"`)
+
+	ctx.ContextCaller = true
+	actual, err = GenerateDocumentationAndInstrumentation(ctx, sourceCode, "main")
+	if err != nil {
+		t.Fatalf("error generating the documentation: %s", err)
+	}
+
+	decoded, err = url.QueryUnescape(actual)
+	if err != nil {
+		t.Fatalf("error decoding the generated links: %s", err)
+	}
+
+	assert.Contains(t, decoded, `rate(function_calls_count{caller="shop.checkout",objective_copy=""}[5m])`,
+		"The callee links must use the new names, reported through the context.")
+	assert.NotContains(t, decoded, `caller="main.handler"`)
+}
+
+// TestUnknownDirectiveArguments calls GenerateDocumentationAndInstrumentation with
+// unknown directives and arguments, and checks that the errors suggest the known ones
+// and report the position of the directive.
+func TestUnknownDirectiveArguments(t *testing.T) {
+	sourceCode := `// This is the package comment.
+package main
+
+import (
+	prom "github.com/autometrics-dev/autometrics-go/pkg/autometrics/prometheus"
+)
+
+// main is the entrypoint.
+//
+//autometrics:doc --slo "API" --success-target 99
+func main() {
+	fmt.Println(hello)
+}
+`
+
+	ctx, err := internal.NewGeneratorContext(autometrics.PROMETHEUS, DefaultPrometheusInstanceUrl, false)
+	if err != nil {
+		t.Fatalf("error creating the generation context: %s", err)
+	}
+	ctx.FileName = "main.go"
+
+	invalidDirectives := map[string]string{
+		`//autometrics:doc --slo "API" --sucess-target 99`: `main.go:10:1: failed to parse //autometrics directive for main: unknown argument "--sucess-target" (did you mean "--success-target"?)`,
+		`//autometrics:doc --slo "API" 99`:                 `main.go:10:1: failed to parse //autometrics directive for main: unknown argument "99"`,
+		`//autometrics:docs --slo "API"`:                   `main.go:10:1: failed to parse //autometrics directive for main: unknown directive "//autometrics:docs" (did you mean "//autometrics:doc"?)`,
+	}
+	for directive, expectedError := range invalidDirectives {
+		_, err := GenerateDocumentationAndInstrumentation(ctx, strings.Replace(sourceCode, `//autometrics:doc --slo "API" --success-target 99`, directive, 1), "main")
+		assert.EqualError(t, err, expectedError, "The directive %q must be rejected.", directive)
+
+		var positionErr *PositionError
+		if assert.ErrorAs(t, err, &positionErr) {
+			assert.Equal(t, 10, positionErr.Pos.Line, "The error must be positioned at the directive.")
+		}
+	}
+
+	_, err = GenerateDocumentationAndInstrumentation(ctx, strings.Replace(sourceCode, "func main() {", "func main() {{", 1), "main")
+	assert.ErrorContains(t, err, "main.go:13:3: error parsing source code", "The syntax errors must be positioned.")
+}
+
+// TestDurationAndPercentageDirective calls GenerateDocumentationAndInstrumentation with
+// SLOs given as durations and percentages, and checks that they are instrumented like
+// the ones given with the older arguments.
+func TestDurationAndPercentageDirective(t *testing.T) {
+	sourceCode := `// This is the package comment.
+package main
+
+import (
+	prom "github.com/autometrics-dev/autometrics-go/pkg/autometrics/prometheus"
+)
+
+//autometrics:doc --slo "API" --success-rate 99.9% --latency 250ms --latency-target 99%
+func main() {
+	fmt.Println(hello)
+}
+`
+
+	ctx, err := internal.NewGeneratorContext(autometrics.PROMETHEUS, DefaultPrometheusInstanceUrl, false)
+	if err != nil {
+		t.Fatalf("error creating the generation context: %s", err)
+	}
+
+	actual, err := GenerateDocumentationAndInstrumentation(ctx, sourceCode, "main")
+	if err != nil {
+		t.Fatalf("error generating the documentation: %s", err)
+	}
+
+	assert.Contains(t, actual, "prom.WithAlertLatency(250000000*time.Nanosecond, 99),", "The latency must be read as a duration.")
+	assert.Contains(t, actual, "prom.WithAlertSuccess(99.9),", "The success rate must be read as a percentage.")
+
+	newSyntax := `--slo "API" --success-rate 99.9% --latency 250ms --latency-target 99%`
+	oldSyntax := `--slo "API" --success-target 99.9 --latency-ms 250 --latency-target 99`
+	expected, err := GenerateDocumentationAndInstrumentation(ctx, strings.Replace(sourceCode, newSyntax, oldSyntax, 1), "main")
+	if err != nil {
+		t.Fatalf("error generating the documentation: %s", err)
+	}
+	assert.Equal(t, strings.Replace(expected, oldSyntax, newSyntax, 1), actual, "The older arguments must be aliases.")
+
+	invalidDirectives := map[string]string{
+		`--slo "API" --success-rate high`:                   "must be a percentage",
+		`--slo "API" --latency 250 --latency-target 99%`:    "must be a positive duration",
+		`--slo "API" --latency -250ms --latency-target 99%`: "must be a positive duration",
+	}
+	for directive, expectedError := range invalidDirectives {
+		_, err := GenerateDocumentationAndInstrumentation(ctx, strings.Replace(sourceCode, newSyntax, directive, 1), "main")
+		assert.ErrorContains(t, err, expectedError, "The directive %q must be rejected.", directive)
+	}
+}
+
+// TestMultipleSloDirective calls GenerateDocumentationAndInstrumentation with several
+// --slo arguments, and checks that each one starts another objective of the function.
+func TestMultipleSloDirective(t *testing.T) {
+	sourceCode := `// This is the package comment.
+package main
+
+import (
+	prom "github.com/autometrics-dev/autometrics-go/pkg/autometrics/prometheus"
+)
+
+//autometrics:doc --slo "API" --success-rate 99% --slo "Checkout" --latency 100ms --latency-target 90% --slo "Checkout" --latency 1s --latency-target 99%
+func main() {
+	fmt.Println(hello)
+}
+`
+
+	ctx, err := internal.NewGeneratorContext(autometrics.PROMETHEUS, DefaultPrometheusInstanceUrl, false)
+	if err != nil {
+		t.Fatalf("error creating the generation context: %s", err)
+	}
+
+	actual, err := GenerateDocumentationAndInstrumentation(ctx, sourceCode, "main")
+	if err != nil {
+		t.Fatalf("error generating the documentation: %s", err)
+	}
+
+	assert.Equal(t, 2, strings.Count(actual, "prom.WithSloName(\"API\"),"), "The first objective must be the SLO of the function.")
+	assert.Equal(t, 2, strings.Count(actual, "prom.WithAlertSuccess(99),"), "The first objective must keep its success rate.")
+	assert.Equal(t, 2, strings.Count(actual, "prom.WithObjective(\"Checkout\", prom.WithAlertLatency(100000000*time.Nanosecond, 90)),"),
+		"The next objectives must be added to the instrumentation and the registration.")
+	assert.Equal(t, 2, strings.Count(actual, "prom.WithObjective(\"Checkout\", prom.WithAlertLatency(1000000000*time.Nanosecond, 99)),"),
+		"The next objectives must be added to the instrumentation and the registration.")
+
+	_, err = GenerateDocumentationAndInstrumentation(ctx, strings.Replace(sourceCode, "--latency 1s --latency-target 99%", "--latency 100ms --latency-target 90%", 1), "main")
+	assert.ErrorContains(t, err, "same latency target twice", "The same objective must not be given twice.")
+
+	repeatedArguments := map[string]string{
+		`--slo "API" --latency 100ms --latency-target 90 --latency 1s --latency-target 99`: "--latency argument is given twice for the same SLO, start another objective with --slo",
+		`--slo "API" --latency-ms 100 --latency-target 90 --latency-target 99`:             "--latency-target argument is given twice for the same SLO, start another objective with --slo",
+		`--slo "API" --success-target 90 --success-rate 99`:                                "--success-rate argument is given twice for the same SLO, start another objective with --slo",
+	}
+	for arguments, expectedError := range repeatedArguments {
+		_, err = GenerateDocumentationAndInstrumentation(ctx, strings.Replace(sourceCode, `--slo "API" --success-rate 99% --slo "Checkout" --latency 100ms --latency-target 90% --slo "Checkout" --latency 1s --latency-target 99%`, arguments, 1), "main")
+		assert.ErrorContains(t, err, expectedError, "The arguments %q must be rejected.", arguments)
+	}
+}
+
 func TestCommentDirectiveErrors(t *testing.T) {
 	sourceCode := `// This is the package comment.
 package main
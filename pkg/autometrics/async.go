@@ -0,0 +1,131 @@
+package autometrics
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+var errTaskPanicked = errors.New("the async task panicked")
+
+// Async runs the instrumented async tasks of an implementation, for the Go function
+// and the Group type of the implementation packages.
+type Async struct {
+	// Backend records the calls of the tasks.
+	Backend Backend
+	// InFlight adds delta to the gauge of the running async tasks started by the spawning
+	// function. It is called with 1 when a task starts, and with -1 when it returns.
+	InFlight func(ctx context.Context, spawner CallInfo, delta int64)
+}
+
+// Go runs fn in a new goroutine, instrumented as the function name in the module
+// of spawner, with spawner as caller.
+//
+// spawner is the function starting the task, that the implementations read with
+// CallerInfo directly from their Go function.
+func (a Async) Go(ctx context.Context, spawner CallInfo, name string, fn func(ctx context.Context) error, opts ...Option) {
+	a.start(ctx, spawner)
+	go func() {
+		_ = a.run(ctx, spawner, name, fn, opts)
+	}()
+}
+
+// start counts a new async task of the spawning function.
+func (a Async) start(ctx context.Context, spawner CallInfo) {
+	if a.InFlight != nil {
+		a.InFlight(ctx, spawner, 1)
+	}
+}
+
+// run runs an async task started with start, and records it.
+func (a Async) run(ctx context.Context, spawner CallInfo, name string, fn func(ctx context.Context) error, opts []Option) (err error) {
+	if a.InFlight != nil {
+		defer a.InFlight(ctx, spawner, -1)
+	}
+
+	// PreInstrument reads the spawning function from the context, since the stack
+	// of the goroutine does not hold it.
+	taskCtx := ContextWithCaller(ctx, spawner.FuncName, spawner.ModuleName)
+	amCtx := a.Backend.NewContext(append(append([]Option{}, opts...), taskOption{
+		funcName:   name,
+		moduleName: spawner.ModuleName,
+		ctx:        &taskCtx,
+	})...)
+
+	// The error stays set if the task panics.
+	reported := errTaskPanicked
+	defer a.Backend.Instrument(a.Backend.PreInstrument(amCtx), &reported)
+
+	err = fn(taskCtx)
+	reported = err
+
+	return err
+}
+
+// taskOption sets the names and the context of an async task.
+type taskOption struct {
+	funcName   string
+	moduleName string
+	ctx        *context.Context
+}
+
+func (o taskOption) Apply(ctx *Context) {
+	ctx.CallInfo.FuncName = o.funcName
+	ctx.CallInfo.ModuleName = o.moduleName
+	ctx.ContextPointer = o.ctx
+}
+
+// Group runs instrumented async tasks and waits for them, like errgroup.Group. It is
+// the common part of the Group type of the implementation packages.
+//
+// The zero value is a valid Group that does not cancel anything on error.
+type Group struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+
+	errOnce sync.Once
+	err     error
+}
+
+// WithContext sets up the group to run its tasks with a context derived from ctx,
+// that is canceled when a task of the group returns an error, or when Wait returns.
+// It returns this context, and must be called before the first task.
+func (g *Group) WithContext(ctx context.Context) context.Context {
+	g.ctx, g.cancel = context.WithCancel(ctx)
+
+	return g.ctx
+}
+
+// Go runs fn in a new goroutine, instrumented like [Async.Go] with the context of the group.
+func (g *Group) Go(async Async, spawner CallInfo, name string, fn func(ctx context.Context) error, opts ...Option) {
+	ctx := g.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	g.wg.Add(1)
+	async.start(ctx, spawner)
+	go func() {
+		defer g.wg.Done()
+
+		if err := async.run(ctx, spawner, name, fn, opts); err != nil {
+			g.errOnce.Do(func() {
+				g.err = err
+				if g.cancel != nil {
+					g.cancel()
+				}
+			})
+		}
+	}()
+}
+
+// Wait blocks until all the tasks of the group returned, and returns the first error.
+func (g *Group) Wait() error {
+	g.wg.Wait()
+	if g.cancel != nil {
+		g.cancel()
+	}
+
+	return g.err
+}
@@ -0,0 +1,122 @@
+package autometrics
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// asyncRecorder is a Backend that records the calls of the async tasks, and the
+// in-flight hook of an Async.
+type asyncRecorder struct {
+	mutex    sync.Mutex
+	calls    map[string]error
+	callers  map[string]string
+	inFlight int64
+}
+
+func newAsyncRecorder() *asyncRecorder {
+	return &asyncRecorder{calls: make(map[string]error), callers: make(map[string]string)}
+}
+
+func (r *asyncRecorder) NewContext(opts ...Option) *Context {
+	ctx := NewContext()
+	for _, o := range opts {
+		o.Apply(&ctx)
+	}
+	return &ctx
+}
+
+func (r *asyncRecorder) PreInstrument(ctx *Context) *Context {
+	ctx.PropagateCaller()
+	return ctx
+}
+
+func (r *asyncRecorder) Instrument(ctx *Context, err *error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.calls[ctx.CallInfo.FuncName] = *err
+	r.callers[ctx.CallInfo.FuncName] = ctx.CallInfo.ParentModuleName + "." + ctx.CallInfo.ParentFuncName
+}
+
+func (r *asyncRecorder) RegisterFunction(string, string, *Context) {}
+
+func (r *asyncRecorder) async() Async {
+	return Async{
+		Backend: r,
+		InFlight: func(_ context.Context, spawner CallInfo, delta int64) {
+			r.mutex.Lock()
+			defer r.mutex.Unlock()
+
+			r.inFlight += delta
+		},
+	}
+}
+
+func TestGroup(t *testing.T) {
+	recorder := newAsyncRecorder()
+	spawner := CallInfo{FuncName: "checkout", ModuleName: "shop"}
+	errReservation := errors.New("out of stock")
+
+	var g Group
+	ctx := g.WithContext(context.Background())
+
+	release := make(chan struct{})
+	var canceled bool
+	g.Go(recorder.async(), spawner, "waitForCancel", func(ctx context.Context) error {
+		close(release)
+		<-ctx.Done()
+		canceled = true
+		return nil
+	})
+	g.Go(recorder.async(), spawner, "reserve", func(ctx context.Context) error {
+		<-release
+		return errReservation
+	})
+
+	assert.ErrorIs(t, g.Wait(), errReservation, "Wait must return the error of the failed task.")
+	assert.True(t, canceled, "The context of the group must be canceled when a task fails.")
+	assert.ErrorIs(t, ctx.Err(), context.Canceled, "The context of the group must be canceled after Wait.")
+
+	assert.Equal(t, map[string]error{"waitForCancel": nil, "reserve": errReservation}, recorder.calls,
+		"Each task must be recorded with its result.")
+	assert.Equal(t, map[string]string{"waitForCancel": "shop.checkout", "reserve": "shop.checkout"}, recorder.callers,
+		"The spawning function must be the caller of the tasks.")
+	assert.Equal(t, int64(0), recorder.inFlight, "The in-flight gauge must go back to 0 once the tasks returned.")
+}
+
+func TestGroupZeroValue(t *testing.T) {
+	recorder := newAsyncRecorder()
+	errSend := errors.New("the mail server is down")
+
+	var g Group
+	g.Go(recorder.async(), CallInfo{FuncName: "checkout", ModuleName: "shop"}, "sendReceipt", func(ctx context.Context) error {
+		return errSend
+	})
+
+	assert.ErrorIs(t, g.Wait(), errSend, "Wait must return the error of the failed task without a context.")
+	assert.Equal(t, map[string]error{"sendReceipt": errSend}, recorder.calls, "The task must be recorded with its result.")
+	assert.Equal(t, int64(0), recorder.inFlight, "The in-flight gauge must go back to 0 once the tasks returned.")
+}
+
+func TestAsyncGo(t *testing.T) {
+	recorder := newAsyncRecorder()
+	done := make(chan struct{})
+
+	recorder.async().Go(context.Background(), CallInfo{FuncName: "checkout", ModuleName: "shop"}, "sendReceipt", func(ctx context.Context) error {
+		defer close(done)
+
+		funcName, moduleName, ok := CallerFromContext(ctx)
+		assert.True(t, ok, "The context of the task must record an ancestor.")
+		assert.Equal(t, "sendReceipt", funcName, "The task must be the ancestor of the calls made with its context.")
+		assert.Equal(t, "shop", moduleName, "The task must be in the module of the spawning function.")
+
+		return nil
+	})
+
+	<-done
+}
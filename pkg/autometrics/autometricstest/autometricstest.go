@@ -0,0 +1,286 @@
+// Package autometricstest records the calls of the instrumented functions in memory,
+// and provides assertions on them for the test suites of instrumented code.
+//
+// The calls are recorded by every implementation (prometheus, otel and statsd),
+// whether its Init function has been called or not:
+//
+//	func TestIndexHandler(t *testing.T) {
+//		autometricstest.Reset()
+//
+//		indexHandler(failingRequest)
+//		indexHandler(failingRequest)
+//
+//		autometricstest.AssertCalled(t, "indexHandler", autometricstest.WithResult(autometricstest.ErrorResult), autometricstest.Times(2))
+//	}
+//
+// The Backend of this package records the calls without any metrics, for the packages
+// that take an [autometrics.Backend], like the http, grpc and sql packages.
+//
+// The recorders see the calls of the whole test binary: the tests asserting on
+// the recorded calls should not run in parallel with other tests calling the same functions.
+package autometricstest // import "github.com/autometrics-dev/autometrics-go/pkg/autometrics/autometricstest"
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/autometrics-dev/autometrics-go/pkg/autometrics"
+)
+
+const (
+	// OkResult is the result of a call that returned no error.
+	OkResult = "ok"
+	// ErrorResult is the result of a call that returned an error.
+	ErrorResult = "error"
+)
+
+// Call is a recorded call of an instrumented function.
+type Call struct {
+	// Function is the name of the function.
+	Function string
+	// Module is the name of the module of the function.
+	Module string
+	// Caller is the name of the function that called the function, empty if the function
+	// does not track its caller, like in the metrics.
+	Caller string
+	// CallerModule is the name of the module of the caller.
+	CallerModule string
+	// Result is OkResult or ErrorResult.
+	Result string
+	// Err is the error returned by the function, nil for a successful call.
+	Err error
+	// Duration is the duration of the call.
+	Duration time.Duration
+	// AlertConf is the SLO configuration of the function, nil if it has none.
+	AlertConf *autometrics.AlertConfiguration
+	// MoreAlertConfs are the other objectives of the function, if it has several.
+	MoreAlertConfs []autometrics.AlertConfiguration
+}
+
+// String returns a short description of the call, used in the assertion failures.
+func (c Call) String() string {
+	description := fmt.Sprintf("%s.%s called by %s.%s: %s in %s", c.Module, c.Function, c.CallerModule, c.Caller, c.Result, c.Duration)
+	if c.AlertConf != nil {
+		description += fmt.Sprintf(" (SLO %q)", c.AlertConf.ServiceName)
+	}
+
+	return description
+}
+
+// Recorder records the calls of the instrumented functions.
+type Recorder struct {
+	mutex  sync.Mutex
+	calls  []Call
+	remove func()
+}
+
+// NewRecorder returns a Recorder recording the calls until Stop is called.
+func NewRecorder() *Recorder {
+	r := &Recorder{}
+	r.remove = autometrics.AddCallObserver(r.record)
+
+	return r
+}
+
+// Stop stops recording the calls. The recorded calls are kept.
+func (r *Recorder) Stop() {
+	r.remove()
+}
+
+func (r *Recorder) record(call autometrics.ObservedCall) {
+	result := OkResult
+	if call.Err != nil {
+		result = ErrorResult
+	}
+
+	caller, callerModule := call.CallInfo.ParentFuncName, call.CallInfo.ParentModuleName
+	if !call.TrackCallerName {
+		caller, callerModule = "", ""
+	}
+
+	recorded := Call{
+		Function:       call.CallInfo.FuncName,
+		Module:         call.CallInfo.ModuleName,
+		Caller:         caller,
+		CallerModule:   callerModule,
+		Result:         result,
+		Err:            call.Err,
+		Duration:       call.Duration,
+		AlertConf:      call.AlertConf,
+		MoreAlertConfs: call.MoreAlertConfs,
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.calls = append(r.calls, recorded)
+}
+
+// Calls returns the recorded calls, in the order they ended.
+func (r *Recorder) Calls() []Call {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	return append([]Call(nil), r.calls...)
+}
+
+// Reset forgets the recorded calls.
+func (r *Recorder) Reset() {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.calls = nil
+}
+
+// TestingT is the part of testing.TB used by the assertions.
+type TestingT interface {
+	Helper()
+	Errorf(format string, args ...interface{})
+}
+
+// Matcher restricts the calls checked by an assertion.
+type Matcher func(*expectation)
+
+type expectation struct {
+	filters     []func(Call) bool
+	description []string
+	times       int
+}
+
+// WithModule only matches the calls of functions of the given module.
+func WithModule(module string) Matcher {
+	return func(e *expectation) {
+		e.add(fmt.Sprintf("module %q", module), func(c Call) bool { return c.Module == module })
+	}
+}
+
+// WithCaller only matches the calls made by the given function.
+func WithCaller(caller string) Matcher {
+	return func(e *expectation) {
+		e.add(fmt.Sprintf("caller %q", caller), func(c Call) bool { return c.Caller == caller })
+	}
+}
+
+// WithResult only matches the calls with the given result, OkResult or ErrorResult.
+func WithResult(result string) Matcher {
+	return func(e *expectation) {
+		e.add(fmt.Sprintf("result %q", result), func(c Call) bool { return c.Result == result })
+	}
+}
+
+// WithSloName only matches the calls of functions that belong to the given SLO, through
+// any of their objectives.
+func WithSloName(name string) Matcher {
+	return func(e *expectation) {
+		e.add(fmt.Sprintf("SLO %q", name), func(c Call) bool {
+			if c.AlertConf != nil && c.AlertConf.ServiceName == name {
+				return true
+			}
+			for _, alertConf := range c.MoreAlertConfs {
+				if alertConf.ServiceName == name {
+					return true
+				}
+			}
+			return false
+		})
+	}
+}
+
+// Times expects exactly n matching calls, instead of at least one.
+func Times(n int) Matcher {
+	return func(e *expectation) {
+		e.times = n
+	}
+}
+
+func (e *expectation) add(description string, filter func(Call) bool) {
+	e.description = append(e.description, description)
+	e.filters = append(e.filters, filter)
+}
+
+func (e *expectation) matches(c Call) bool {
+	for _, f := range e.filters {
+		if !f(c) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// AssertCalled checks that function was called, at least once or the number of
+// times given with Times, with calls matching all the matchers.
+//
+// It reports the failure with t.Errorf, and returns true if the assertion holds.
+func (r *Recorder) AssertCalled(t TestingT, function string, matchers ...Matcher) bool {
+	t.Helper()
+
+	e := expectation{times: -1}
+	e.add(fmt.Sprintf("function %q", function), func(c Call) bool { return c.Function == function })
+	for _, m := range matchers {
+		m(&e)
+	}
+
+	calls := r.Calls()
+	matching := 0
+	var recorded []string
+	for _, c := range calls {
+		if e.matches(c) {
+			matching++
+		}
+		if c.Function == function {
+			recorded = append(recorded, "\t"+c.String())
+		}
+	}
+
+	if (e.times < 0 && matching > 0) || matching == e.times {
+		return true
+	}
+
+	expected := "at least 1"
+	if e.times >= 0 {
+		expected = fmt.Sprint(e.times)
+	}
+	t.Errorf("expected %s call(s) with %s, got %d.\nRecorded calls of %q:\n%s",
+		expected, strings.Join(e.description, ", "), matching, function, strings.Join(recorded, "\n"))
+
+	return false
+}
+
+// AssertNotCalled checks that function was never called with calls matching all the matchers.
+//
+// It reports the failure with t.Errorf, and returns true if the assertion holds.
+func (r *Recorder) AssertNotCalled(t TestingT, function string, matchers ...Matcher) bool {
+	t.Helper()
+
+	return r.AssertCalled(t, function, append(matchers, Times(0))...)
+}
+
+// defaultRecorder records the calls from the start of the test binary.
+var defaultRecorder = NewRecorder()
+
+// Calls returns the calls recorded since the start of the test binary or the last Reset.
+func Calls() []Call {
+	return defaultRecorder.Calls()
+}
+
+// Reset forgets the calls recorded by the package-level functions.
+func Reset() {
+	defaultRecorder.Reset()
+}
+
+// AssertCalled checks the calls recorded since the start of the test binary or the
+// last Reset, see Recorder.AssertCalled.
+func AssertCalled(t TestingT, function string, matchers ...Matcher) bool {
+	t.Helper()
+
+	return defaultRecorder.AssertCalled(t, function, matchers...)
+}
+
+// AssertNotCalled checks the calls recorded since the start of the test binary or the
+// last Reset, see Recorder.AssertNotCalled.
+func AssertNotCalled(t TestingT, function string, matchers ...Matcher) bool {
+	t.Helper()
+
+	return defaultRecorder.AssertNotCalled(t, function, matchers...)
+}
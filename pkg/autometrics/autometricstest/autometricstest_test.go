@@ -0,0 +1,103 @@
+package autometricstest
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/autometrics-dev/autometrics-go/pkg/autometrics"
+	"github.com/autometrics-dev/autometrics-go/pkg/autometrics/prometheus"
+	"github.com/stretchr/testify/assert"
+)
+
+// indexHandler is instrumented with the prometheus implementation, whose Init
+// function is never called in these tests.
+func indexHandler(fail bool) (err error) {
+	defer prometheus.Instrument(prometheus.PreInstrument(prometheus.NewContext(
+		prometheus.WithSloName("API"),
+		prometheus.WithAlertSuccess(99),
+	)), &err)
+
+	if fail {
+		return errors.New("the request failed")
+	}
+
+	return nil
+}
+
+// fakeT records the failures of the assertions.
+type fakeT struct {
+	failures []string
+}
+
+func (*fakeT) Helper() {}
+
+func (f *fakeT) Errorf(format string, args ...interface{}) {
+	f.failures = append(f.failures, fmt.Sprintf(format, args...))
+}
+
+func TestAssertCalled(t *testing.T) {
+	Reset()
+
+	assert.Nil(t, indexHandler(false))
+	assert.NotNil(t, indexHandler(true))
+	assert.NotNil(t, indexHandler(true))
+
+	if assert.Len(t, Calls(), 3) {
+		call := Calls()[1]
+		assert.Equal(t, "indexHandler", call.Function)
+		assert.Equal(t, "autometricstest", call.Module)
+		assert.Equal(t, "TestAssertCalled", call.Caller)
+		assert.Equal(t, ErrorResult, call.Result)
+		if assert.NotNil(t, call.AlertConf) {
+			assert.Equal(t, "API", call.AlertConf.ServiceName)
+			assert.Equal(t, &autometrics.SuccessSlo{Objective: 99}, call.AlertConf.Success)
+		}
+	}
+
+	AssertCalled(t, "indexHandler")
+	AssertCalled(t, "indexHandler", WithResult(ErrorResult), Times(2))
+	AssertCalled(t, "indexHandler", WithResult(OkResult), WithModule("autometricstest"), WithCaller("TestAssertCalled"), WithSloName("API"), Times(1))
+	AssertNotCalled(t, "indexHandler", WithCaller("main"))
+
+	var fake fakeT
+	assert.False(t, AssertCalled(&fake, "indexHandler", WithResult(ErrorResult), Times(3)))
+	assert.False(t, AssertNotCalled(&fake, "indexHandler", WithResult(OkResult)))
+	assert.False(t, AssertCalled(&fake, "otherHandler"))
+	assert.Len(t, fake.failures, 3, "Every failed assertion must be reported.")
+
+	Reset()
+	AssertNotCalled(t, "indexHandler")
+}
+
+func TestRecorder(t *testing.T) {
+	recorder := NewRecorder()
+	assert.Nil(t, indexHandler(false))
+	recorder.Stop()
+	assert.Nil(t, indexHandler(false))
+
+	recorder.AssertCalled(t, "indexHandler", Times(1))
+}
+
+// checkoutHandler is instrumented with the in-memory Backend, like the http, grpc
+// and sql packages instrument the calls.
+func checkoutHandler(fail bool) (err error) {
+	defer Backend.Instrument(Backend.PreInstrument(Backend.NewContext()), &err)
+
+	if fail {
+		return errors.New("the checkout failed")
+	}
+
+	return nil
+}
+
+func TestBackend(t *testing.T) {
+	recorder := NewRecorder()
+	defer recorder.Stop()
+
+	assert.Nil(t, checkoutHandler(false))
+	assert.NotNil(t, checkoutHandler(true))
+
+	recorder.AssertCalled(t, "checkoutHandler", WithModule("autometricstest"), WithCaller("TestBackend"), Times(2))
+	recorder.AssertCalled(t, "checkoutHandler", WithResult(ErrorResult), Times(1))
+}
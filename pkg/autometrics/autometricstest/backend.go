@@ -0,0 +1,50 @@
+package autometricstest
+
+import (
+	"time"
+
+	"github.com/autometrics-dev/autometrics-go/pkg/autometrics"
+)
+
+// Backend is an in-memory [autometrics.Backend] that only gives the calls to the recorders,
+// for the tests of the packages that instrument calls without the generator, like the
+// http, grpc and sql packages, without the Init function of an implementation.
+var Backend autometrics.Backend = backend{}
+
+type backend struct{}
+
+func (backend) NewContext(opts ...autometrics.Option) *autometrics.Context {
+	ctx := autometrics.NewContext()
+	for _, o := range opts {
+		o.Apply(&ctx)
+	}
+
+	return &ctx
+}
+
+// PreInstrument reads the call information itself, so that the first frame is
+// the caller of the method. Like the implementations, it applies the pprof labels
+// and opens the runtime/trace region or task of the call.
+func (backend) PreInstrument(ctx *autometrics.Context) *autometrics.Context {
+	ctx.CallInfo = ctx.CallInfo.Complete(autometrics.CallerInfo())
+	ctx.PropagateCaller()
+	ctx.ApplyProfileLabels()
+	ctx.StartRuntimeTrace()
+	ctx.StartTime = time.Now()
+
+	return ctx
+}
+
+func (backend) Instrument(ctx *autometrics.Context, err *error) {
+	var callErr error
+	if err != nil {
+		callErr = *err
+	}
+
+	duration := time.Since(ctx.StartTime)
+	ctx.EndRuntimeTrace()
+	ctx.RestoreProfileLabels()
+	autometrics.ObserveCall(ctx, callErr, duration)
+}
+
+func (backend) RegisterFunction(string, string, *autometrics.Context) {}
@@ -0,0 +1,31 @@
+package autometrics
+
+// Backend is a metrics implementation, that records the calls of the instrumented functions.
+//
+// The implementation packages export their Backend, like prometheus.Backend, for the
+// packages that instrument calls without the generator, like the http, grpc and sql packages.
+//
+// The generated code calls the package-level functions of the implementation package
+// instead of the methods. A package usable with the -backend flag of the generator
+// exports functions with the names and signatures of the methods of Backend, along with
+// the options used in the generated code: WithConcurrentCalls, WithCallerName, WithSloName,
+// WithAlertLatency, WithAlertSuccess, WithContext, WithRequest and WithContextCaller, and WithTracing,
+// WithProfileLabels, WithRuntimeTrace, WithLabel, WithFunctionName and WithModuleName for
+// the functions with the --trace, --profile-labels, --runtime-trace, --label, --name and
+// --module arguments.
+type Backend interface {
+	// NewContext returns the instrumentation context of a call, configured with the options.
+	NewContext(opts ...Option) *Context
+	// PreInstrument starts the instrumentation of a call, and returns the context to give to Instrument.
+	//
+	// The empty names of ctx.CallInfo are read from the stack frames with CallerInfo, so the
+	// instrumented function must be the direct caller of PreInstrument. Implementations
+	// must call CallerInfo directly from PreInstrument for the frames to be right.
+	PreInstrument(ctx *Context) *Context
+	// Instrument records the call, with the error err points to as result. It is meant
+	// to be deferred in the instrumented function.
+	Instrument(ctx *Context, err *error)
+	// RegisterFunction announces an instrumented function before its first call,
+	// see the RegisterFunction function of this package.
+	RegisterFunction(funcName, moduleName string, ctx *Context)
+}
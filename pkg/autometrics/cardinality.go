@@ -0,0 +1,61 @@
+package autometrics
+
+import (
+	"sync"
+)
+
+// OverflowLabelValue is the value that replaces the values of a dynamic label
+// once a function exceeds the cardinality limit for this label.
+const OverflowLabelValue = "__overflow__"
+
+// CardinalityLimiter bounds the number of distinct values a dynamic label,
+// like the caller label, can take for each function.
+//
+// A nil CardinalityLimiter, or one with a limit of 0, allows all the values.
+type CardinalityLimiter struct {
+	limit  int
+	mutex  sync.Mutex
+	values map[string]map[string]struct{}
+}
+
+// NewCardinalityLimiter builds a limiter that allows at most limit distinct
+// values per function. A limit of 0 or less means no limit.
+func NewCardinalityLimiter(limit int) *CardinalityLimiter {
+	return &CardinalityLimiter{
+		limit:  limit,
+		values: make(map[string]map[string]struct{}),
+	}
+}
+
+// Allow returns true if the value can be used as a label value for the function.
+//
+// Values already seen for the function are always allowed, and new values are
+// allowed until the function reaches the limit of distinct values.
+func (l *CardinalityLimiter) Allow(funcName, moduleName, value string) bool {
+	if l == nil || l.limit <= 0 {
+		return true
+	}
+
+	key := moduleName + "." + funcName
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	seen, ok := l.values[key]
+	if !ok {
+		seen = make(map[string]struct{})
+		l.values[key] = seen
+	}
+
+	if _, ok := seen[value]; ok {
+		return true
+	}
+
+	if len(seen) >= l.limit {
+		return false
+	}
+
+	seen[value] = struct{}{}
+
+	return true
+}
@@ -0,0 +1,85 @@
+package autometrics
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCardinalityLimiter(t *testing.T) {
+	type call struct {
+		funcName string
+		value    string
+		want     bool
+	}
+
+	testCases := []struct {
+		name    string
+		limiter *CardinalityLimiter
+		calls   []call
+	}{
+		{
+			name:    "nil limiter",
+			limiter: nil,
+			calls: []call{
+				{funcName: "handler", value: "a", want: true},
+				{funcName: "handler", value: "b", want: true},
+			},
+		},
+		{
+			name:    "zero limit",
+			limiter: NewCardinalityLimiter(0),
+			calls: []call{
+				{funcName: "handler", value: "a", want: true},
+				{funcName: "handler", value: "b", want: true},
+				{funcName: "handler", value: "c", want: true},
+			},
+		},
+		{
+			name:    "negative limit",
+			limiter: NewCardinalityLimiter(-1),
+			calls: []call{
+				{funcName: "handler", value: "a", want: true},
+				{funcName: "handler", value: "b", want: true},
+			},
+		},
+		{
+			name:    "limit reached",
+			limiter: NewCardinalityLimiter(2),
+			calls: []call{
+				{funcName: "handler", value: "a", want: true},
+				{funcName: "handler", value: "b", want: true},
+				{funcName: "handler", value: "c", want: false},
+				{funcName: "handler", value: "d", want: false},
+			},
+		},
+		{
+			name:    "seen values stay allowed",
+			limiter: NewCardinalityLimiter(1),
+			calls: []call{
+				{funcName: "handler", value: "a", want: true},
+				{funcName: "handler", value: "b", want: false},
+				{funcName: "handler", value: "a", want: true},
+			},
+		},
+		{
+			name:    "limit per function",
+			limiter: NewCardinalityLimiter(1),
+			calls: []call{
+				{funcName: "handler", value: "a", want: true},
+				{funcName: "handler", value: "b", want: false},
+				{funcName: "other", value: "b", want: true},
+				{funcName: "other", value: "a", want: false},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			for i, c := range tc.calls {
+				assert.Equal(t, c.want, tc.limiter.Allow(c.funcName, "main", c.value),
+					"call %d: Allow(%q, %q) is not as expected.", i, c.funcName, c.value)
+			}
+		})
+	}
+}
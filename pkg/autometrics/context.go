@@ -0,0 +1,77 @@
+package autometrics
+
+import (
+	"context"
+)
+
+type callerContextKey struct{}
+
+type contextCaller struct {
+	funcName   string
+	moduleName string
+}
+
+// ContextWithCaller returns a copy of ctx that records the function as the
+// nearest instrumented ancestor of the calls made with this context.
+func ContextWithCaller(ctx context.Context, funcName, moduleName string) context.Context {
+	return context.WithValue(ctx, callerContextKey{}, contextCaller{funcName: funcName, moduleName: moduleName})
+}
+
+// CallerFromContext returns the nearest instrumented ancestor recorded in ctx
+// by ContextWithCaller.
+func CallerFromContext(ctx context.Context) (funcName, moduleName string, ok bool) {
+	if ctx == nil {
+		return "", "", false
+	}
+
+	caller, ok := ctx.Value(callerContextKey{}).(contextCaller)
+	if !ok {
+		return "", "", false
+	}
+
+	return caller.funcName, caller.moduleName, true
+}
+
+// PropagateCaller uses the context.Context of the instrumented function, given with
+// ContextPointer or RequestPointer, to track the caller across goroutines and adapters.
+//
+// The nearest instrumented ancestor found in the context replaces the caller read from
+// the stack frames in CallInfo, and the current function is stored in the context for the
+// nested instrumented calls. PreInstrument calls this method after setting CallInfo.
+//
+// Without [Context.TrackContextCaller], the caller read from the stack frames is kept and
+// the context is left as is, it only becomes c.Context for the pprof labels, the
+// runtime/trace tasks and the spans.
+func (c *Context) PropagateCaller() {
+	var goCtx context.Context
+
+	switch {
+	case c.ContextPointer != nil:
+		goCtx = *c.ContextPointer
+	case c.RequestPointer != nil && *c.RequestPointer != nil:
+		goCtx = (*c.RequestPointer).Context()
+	default:
+		return
+	}
+
+	if goCtx == nil {
+		goCtx = context.Background()
+	}
+
+	if c.TrackContextCaller {
+		if funcName, moduleName, ok := CallerFromContext(goCtx); ok {
+			c.CallInfo.ParentFuncName = funcName
+			c.CallInfo.ParentModuleName = moduleName
+		}
+
+		goCtx = ContextWithCaller(goCtx, c.CallInfo.FuncName, c.CallInfo.ModuleName)
+
+		if c.ContextPointer != nil {
+			*c.ContextPointer = goCtx
+		} else {
+			*c.RequestPointer = (*c.RequestPointer).WithContext(goCtx)
+		}
+	}
+
+	c.Context = goCtx
+}
@@ -0,0 +1,55 @@
+package autometrics
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPropagateCaller(t *testing.T) {
+	goCtx := ContextWithCaller(context.Background(), "handler", "api")
+
+	ctx := NewContext()
+	ctx.ContextPointer = &goCtx
+	ctx.CallInfo = CallInfo{FuncName: "child", ModuleName: "api", ParentFuncName: "frame", ParentModuleName: "main"}
+
+	ctx.PropagateCaller()
+	assert.Equal(t, "handler", ctx.CallInfo.ParentFuncName, "The caller must be read from the context.")
+	assert.Equal(t, "api", ctx.CallInfo.ParentModuleName)
+
+	funcName, _, _ := CallerFromContext(goCtx)
+	assert.Equal(t, "child", funcName, "The function must be stored in the context for the nested calls.")
+	assert.Equal(t, goCtx, ctx.Context)
+}
+
+func TestPropagateCallerWithoutContextCaller(t *testing.T) {
+	goCtx := ContextWithCaller(context.Background(), "handler", "api")
+	parent := goCtx
+
+	ctx := NewContext()
+	ctx.TrackContextCaller = false
+	ctx.ContextPointer = &goCtx
+	ctx.CallInfo = CallInfo{FuncName: "child", ModuleName: "api", ParentFuncName: "frame", ParentModuleName: "main"}
+
+	ctx.PropagateCaller()
+	assert.Equal(t, "frame", ctx.CallInfo.ParentFuncName, "The caller read from the stack frames must be kept.")
+	assert.Equal(t, "main", ctx.CallInfo.ParentModuleName)
+	assert.Equal(t, parent, goCtx, "The context of the function must be left as is.")
+	assert.Equal(t, parent, ctx.Context, "The context must still be used for the pprof labels and the traces.")
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil).WithContext(parent)
+	original := req
+
+	ctx = NewContext()
+	ctx.TrackContextCaller = false
+	ctx.RequestPointer = &req
+	ctx.CallInfo = CallInfo{FuncName: "child", ModuleName: "api", ParentFuncName: "frame", ParentModuleName: "main"}
+
+	ctx.PropagateCaller()
+	assert.Equal(t, "frame", ctx.CallInfo.ParentFuncName, "The caller read from the stack frames must be kept.")
+	assert.Same(t, original, req, "The request of the function must be left as is.")
+	assert.Equal(t, parent, ctx.Context)
+}
@@ -0,0 +1,339 @@
+// Package grpc provides gRPC interceptors that collect the autometrics metrics
+// of the gRPC methods, without the code generator.
+//
+// The interceptors record the calls with the backend of an implementation, like
+// prometheus.Backend. The function label of the metrics is the full method name, like
+// "/grpc.health.v1.Health/Check", and the module label is the service, like
+// "grpc.health.v1.Health", with the ClientModuleSuffix for the calls of the client
+// interceptors. The result is derived from the gRPC status code.
+package grpc // import "github.com/autometrics-dev/autometrics-go/pkg/autometrics/grpc"
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"sync"
+
+	"github.com/autometrics-dev/autometrics-go/pkg/autometrics"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// ClientModuleSuffix is added to the module label of the calls recorded by the client
+// interceptors, so that a program that is both a client and a server of a method
+// records the two sides in separate series.
+const ClientModuleSuffix = ":client"
+
+var errHandlerPanicked = errors.New("the handler panicked")
+
+// Option is an option for the interceptors.
+type Option func(*settings)
+
+type settings struct {
+	isError       func(code codes.Code) bool
+	methodOptions map[string][]autometrics.Option
+	propagator    *autometrics.Propagator
+}
+
+// WithErrorCodes sets the classification that decides whether a gRPC status code is an error.
+//
+// By default, IsServerError is used.
+func WithErrorCodes(isError func(code codes.Code) bool) Option {
+	return func(s *settings) {
+		s.isError = isError
+	}
+}
+
+// WithMethodOptions adds options of the implementation to the instrumentation context
+// of some methods, like the SLO options. The keys are the full method names, like
+// "/grpc.health.v1.Health/Check".
+func WithMethodOptions(methodOptions map[string][]autometrics.Option) Option {
+	return func(s *settings) {
+		for method, opts := range methodOptions {
+			s.methodOptions[method] = append(s.methodOptions[method], opts...)
+		}
+	}
+}
+
+// WithPropagator propagates the caller across services in the metadata of the calls:
+// the client interceptors inject it, and the server interceptors extract it.
+func WithPropagator(propagator autometrics.Propagator) Option {
+	return func(s *settings) {
+		s.propagator = &propagator
+	}
+}
+
+// IsServerError is the default classification, where only the codes that describe
+// a failure of the server are errors: Unknown, DeadlineExceeded, Unimplemented,
+// Internal, Unavailable and DataLoss.
+//
+// The codes that describe an invalid request, like InvalidArgument or NotFound,
+// are successful calls.
+func IsServerError(code codes.Code) bool {
+	switch code {
+	case codes.Unknown, codes.DeadlineExceeded, codes.Unimplemented, codes.Internal, codes.Unavailable, codes.DataLoss:
+		return true
+	default:
+		return false
+	}
+}
+
+func newSettings(opts []Option) settings {
+	s := settings{
+		isError:       IsServerError,
+		methodOptions: make(map[string][]autometrics.Option),
+	}
+
+	for _, o := range opts {
+		o(&s)
+	}
+
+	return s
+}
+
+// instrumentationContext builds the instrumentation context of a call to the method,
+// recorded in the module.
+//
+// The caller label is only set when ctx holds an instrumented ancestor.
+func (s settings) instrumentationContext(backend autometrics.Backend, ctx *context.Context, fullMethod, module string) *autometrics.Context {
+	_, _, hasAncestor := autometrics.CallerFromContext(*ctx)
+
+	opts := append([]autometrics.Option{
+		optionFunc(func(amCtx *autometrics.Context) {
+			amCtx.CallInfo.FuncName = fullMethod
+			amCtx.CallInfo.ModuleName = module
+			amCtx.TrackCallerName = hasAncestor
+			amCtx.ContextPointer = ctx
+		}),
+	}, s.methodOptions[fullMethod]...)
+
+	return backend.PreInstrument(backend.NewContext(opts...))
+}
+
+// result returns the error to report for the error returned by a call.
+func (s settings) result(err error) error {
+	if err == nil || errors.Is(err, io.EOF) {
+		return nil
+	}
+
+	if s.isError(status.Code(err)) {
+		return err
+	}
+
+	return nil
+}
+
+func (s settings) extract(ctx context.Context) context.Context {
+	if s.propagator == nil {
+		return ctx
+	}
+
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return ctx
+	}
+
+	return s.propagator.Extract(ctx, autometrics.MetadataCarrier(md))
+}
+
+func (s settings) inject(ctx context.Context) context.Context {
+	if s.propagator == nil {
+		return ctx
+	}
+
+	md, ok := metadata.FromOutgoingContext(ctx)
+	if ok {
+		md = md.Copy()
+	} else {
+		md = metadata.MD{}
+	}
+	s.propagator.Inject(ctx, autometrics.MetadataCarrier(md))
+
+	return metadata.NewOutgoingContext(ctx, md)
+}
+
+// UnaryServerInterceptor returns an interceptor that records the unary calls to the server.
+func UnaryServerInterceptor(backend autometrics.Backend, opts ...Option) grpc.UnaryServerInterceptor {
+	s := newSettings(opts)
+
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp interface{}, err error) {
+		ctx = s.extract(ctx)
+
+		// The error stays set if the handler panics.
+		reported := errHandlerPanicked
+		defer backend.Instrument(s.instrumentationContext(backend, &ctx, info.FullMethod, serviceName(info.FullMethod)), &reported)
+
+		resp, err = handler(ctx, req)
+		reported = s.result(err)
+
+		return resp, err
+	}
+}
+
+// StreamServerInterceptor returns an interceptor that records the streaming calls to the server.
+func StreamServerInterceptor(backend autometrics.Backend, opts ...Option) grpc.StreamServerInterceptor {
+	s := newSettings(opts)
+
+	return func(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx := s.extract(stream.Context())
+
+		// The error stays set if the handler panics.
+		reported := errHandlerPanicked
+		defer backend.Instrument(s.instrumentationContext(backend, &ctx, info.FullMethod, serviceName(info.FullMethod)), &reported)
+
+		err := handler(srv, &serverStream{ServerStream: stream, ctx: ctx})
+		reported = s.result(err)
+
+		return err
+	}
+}
+
+// UnaryClientInterceptor returns an interceptor that records the unary calls of the client.
+func UnaryClientInterceptor(backend autometrics.Backend, opts ...Option) grpc.UnaryClientInterceptor {
+	s := newSettings(opts)
+
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, callOpts ...grpc.CallOption) error {
+		// The caller injected in the metadata is the function making the call,
+		// so the context recording the method is only used by the instrumentation.
+		instrumentedCtx := ctx
+
+		reported := errHandlerPanicked
+		defer backend.Instrument(s.instrumentationContext(backend, &instrumentedCtx, method, serviceName(method)+ClientModuleSuffix), &reported)
+
+		err := invoker(s.inject(ctx), method, req, reply, cc, callOpts...)
+		reported = s.result(err)
+
+		return err
+	}
+}
+
+// StreamClientInterceptor returns an interceptor that records the streaming calls of the client.
+//
+// A call ends when the stream can no longer receive messages, the io.EOF of a
+// stream closed by the server is a success. A client-streaming call also ends when
+// its single response is received, and any call ends when its context is done.
+//
+// Like gRPC requires, the context of a stream that is abandoned before its end must be
+// canceled: otherwise the call is never recorded and stays counted as a concurrent
+// call, and the goroutine watching the context leaks.
+//
+// The call ends on another goroutine than the one starting it, so the pprof labels
+// of the call are not applied to the goroutines.
+func StreamClientInterceptor(backend autometrics.Backend, opts ...Option) grpc.StreamClientInterceptor {
+	s := newSettings(opts)
+
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, callOpts ...grpc.CallOption) (grpc.ClientStream, error) {
+		// The caller injected in the metadata is the function making the call,
+		// so the context recording the method is only used by the instrumentation.
+		instrumentedCtx := ctx
+		amCtx := s.instrumentationContext(backend, &instrumentedCtx, method, serviceName(method)+ClientModuleSuffix)
+		// Instrument cannot restore the labels of this goroutine once the call ends.
+		amCtx.RestoreProfileLabels()
+
+		stream, err := streamer(s.inject(ctx), desc, cc, method, callOpts...)
+		if err != nil {
+			reported := s.result(err)
+			backend.Instrument(amCtx, &reported)
+			return nil, err
+		}
+
+		wrapped := &clientStream{
+			ClientStream: stream,
+			desc:         desc,
+			done:         make(chan struct{}),
+			finish: func(err error) {
+				reported := s.result(err)
+				backend.Instrument(amCtx, &reported)
+			},
+		}
+		go wrapped.watch(ctx)
+
+		return wrapped, nil
+	}
+}
+
+type optionFunc func(*autometrics.Context)
+
+func (fn optionFunc) Apply(ctx *autometrics.Context) {
+	fn(ctx)
+}
+
+// serviceName returns the service of a full method name, like "grpc.health.v1.Health"
+// for "/grpc.health.v1.Health/Check".
+func serviceName(fullMethod string) string {
+	service := strings.TrimPrefix(fullMethod, "/")
+	if index := strings.LastIndex(service, "/"); index >= 0 {
+		return service[:index]
+	}
+
+	return service
+}
+
+// serverStream replaces the context of a server stream with the context holding the caller.
+type serverStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *serverStream) Context() context.Context {
+	return s.ctx
+}
+
+// clientStream records the end of a client stream.
+type clientStream struct {
+	grpc.ClientStream
+	desc   *grpc.StreamDesc
+	once   sync.Once
+	done   chan struct{}
+	finish func(err error)
+}
+
+// end records the end of the stream, only the first time it is called.
+func (s *clientStream) end(err error) {
+	s.once.Do(func() {
+		close(s.done)
+		s.finish(err)
+	})
+}
+
+// watch ends the stream when the context of the call is done, for the streams that
+// are abandoned before they can no longer receive messages. It only returns once the
+// stream ended, so it leaks with the stream if the context is never canceled.
+func (s *clientStream) watch(ctx context.Context) {
+	select {
+	case <-ctx.Done():
+		s.end(status.FromContextError(ctx.Err()).Err())
+	case <-s.done:
+	}
+}
+
+func (s *clientStream) SendMsg(m interface{}) error {
+	err := s.ClientStream.SendMsg(m)
+	if err != nil && !errors.Is(err, io.EOF) {
+		s.end(err)
+	}
+
+	return err
+}
+
+func (s *clientStream) RecvMsg(m interface{}) error {
+	err := s.ClientStream.RecvMsg(m)
+	if err != nil || !s.desc.ServerStreams {
+		// Without server streaming, the single response ends the call.
+		s.end(err)
+	}
+
+	return err
+}
+
+func (s *clientStream) Header() (metadata.MD, error) {
+	md, err := s.ClientStream.Header()
+	if err != nil {
+		s.end(err)
+	}
+
+	return md, err
+}
@@ -0,0 +1,341 @@
+package grpc
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net"
+	"runtime/pprof"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/autometrics-dev/autometrics-go/pkg/autometrics"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+type recordedCall struct {
+	callInfo  autometrics.CallInfo
+	sloName   string
+	withError bool
+}
+
+type recorder struct {
+	mutex sync.Mutex
+	calls []recordedCall
+}
+
+// NewContext, PreInstrument, Instrument and RegisterFunction make the recorder
+// an autometrics.Backend that records the instrumented calls.
+func (r *recorder) NewContext(opts ...autometrics.Option) *autometrics.Context {
+	ctx := autometrics.NewContext()
+	for _, o := range opts {
+		o.Apply(&ctx)
+	}
+	return &ctx
+}
+
+func (r *recorder) PreInstrument(ctx *autometrics.Context) *autometrics.Context {
+	ctx.PropagateCaller()
+	ctx.ApplyProfileLabels()
+	return ctx
+}
+
+func (r *recorder) Instrument(ctx *autometrics.Context, err *error) {
+	ctx.RestoreProfileLabels()
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	call := recordedCall{callInfo: ctx.CallInfo, withError: *err != nil}
+	if ctx.AlertConf != nil {
+		call.sloName = ctx.AlertConf.ServiceName
+	}
+	r.calls = append(r.calls, call)
+}
+
+func (r *recorder) RegisterFunction(string, string, *autometrics.Context) {}
+
+func (r *recorder) recorded() []recordedCall {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	return append([]recordedCall(nil), r.calls...)
+}
+
+type sloOption string
+
+func (o sloOption) Apply(ctx *autometrics.Context) {
+	ctx.AlertConf = &autometrics.AlertConfiguration{ServiceName: string(o)}
+}
+
+// uploadServiceDesc describes a service with a client-streaming method, which receives
+// health check requests until the client closes the stream and then answers once.
+var uploadServiceDesc = grpc.ServiceDesc{
+	ServiceName: "test.Upload",
+	HandlerType: (*interface{})(nil),
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "Send",
+			ClientStreams: true,
+			Handler: func(_ interface{}, stream grpc.ServerStream) error {
+				for {
+					var request healthpb.HealthCheckRequest
+					err := stream.RecvMsg(&request)
+					if err == io.EOF {
+						return stream.SendMsg(&healthpb.HealthCheckResponse{Status: healthpb.HealthCheckResponse_SERVING})
+					}
+					if err != nil {
+						return err
+					}
+				}
+			},
+		},
+	},
+}
+
+// startHealthServer starts an in-process gRPC server with the health and upload services and
+// the server interceptors, and returns a client connection with the client interceptors.
+func startHealthServer(t *testing.T, serverRecorder, clientRecorder *recorder, opts ...Option) *grpc.ClientConn {
+	listener := bufconn.Listen(1024 * 1024)
+
+	server := grpc.NewServer(
+		grpc.UnaryInterceptor(UnaryServerInterceptor(serverRecorder, opts...)),
+		grpc.StreamInterceptor(StreamServerInterceptor(serverRecorder, opts...)),
+	)
+	healthServer := health.NewServer()
+	healthServer.SetServingStatus("known", healthpb.HealthCheckResponse_SERVING)
+	healthpb.RegisterHealthServer(server, healthServer)
+	server.RegisterService(&uploadServiceDesc, struct{}{})
+
+	go func() {
+		_ = server.Serve(listener)
+	}()
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.Dial("bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return listener.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithUnaryInterceptor(UnaryClientInterceptor(clientRecorder, opts...)),
+		grpc.WithStreamInterceptor(StreamClientInterceptor(clientRecorder, opts...)),
+	)
+	if err != nil {
+		t.Fatalf("error dialing the in-process server: %s", err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+
+	return conn
+}
+
+func TestUnaryInterceptors(t *testing.T) {
+	var serverRecorder, clientRecorder recorder
+	conn := startHealthServer(t, &serverRecorder, &clientRecorder,
+		WithMethodOptions(map[string][]autometrics.Option{
+			"/grpc.health.v1.Health/Check": {sloOption("health")},
+		}),
+		WithPropagator(autometrics.NewBaggagePropagator()),
+	)
+	client := healthpb.NewHealthClient(conn)
+
+	ctx := autometrics.ContextWithCaller(context.Background(), "probe", "main")
+
+	_, err := client.Check(ctx, &healthpb.HealthCheckRequest{Service: "known"})
+	assert.NoError(t, err, "The check of a known service must succeed.")
+
+	// NotFound is an error of the client, not of the server.
+	_, err = client.Check(ctx, &healthpb.HealthCheckRequest{Service: "unknown"})
+	assert.Error(t, err, "The check of an unknown service must fail.")
+
+	recordedCalls := map[string][]recordedCall{
+		"grpc.health.v1.Health":                      serverRecorder.recorded(),
+		"grpc.health.v1.Health" + ClientModuleSuffix: clientRecorder.recorded(),
+	}
+	for module, calls := range recordedCalls {
+		if !assert.Len(t, calls, 2, "Each call must be instrumented exactly once.") {
+			continue
+		}
+
+		for _, call := range calls {
+			assert.Equal(t, "/grpc.health.v1.Health/Check", call.callInfo.FuncName, "The function label must be the full method name.")
+			assert.Equal(t, module, call.callInfo.ModuleName, "The module label must be the service, marked for the client.")
+			assert.Equal(t, "health", call.sloName, "The method options must be applied.")
+			assert.False(t, call.withError, "NotFound must not be classified as an error.")
+			assert.Equal(t, "probe", call.callInfo.ParentFuncName, "The caller must be the function making the call.")
+		}
+	}
+}
+
+func TestUnaryInterceptorsErrorCodes(t *testing.T) {
+	var serverRecorder, clientRecorder recorder
+	conn := startHealthServer(t, &serverRecorder, &clientRecorder,
+		WithErrorCodes(func(code codes.Code) bool { return code != codes.OK }),
+	)
+	client := healthpb.NewHealthClient(conn)
+
+	_, err := client.Check(context.Background(), &healthpb.HealthCheckRequest{Service: "unknown"})
+	assert.Error(t, err, "The check of an unknown service must fail.")
+
+	for _, calls := range [][]recordedCall{serverRecorder.recorded(), clientRecorder.recorded()} {
+		if assert.Len(t, calls, 1, "Each call must be instrumented exactly once.") {
+			assert.True(t, calls[0].withError, "NotFound must be an error with the custom classification.")
+		}
+	}
+}
+
+func TestStreamInterceptors(t *testing.T) {
+	var serverRecorder, clientRecorder recorder
+	conn := startHealthServer(t, &serverRecorder, &clientRecorder)
+	client := healthpb.NewHealthClient(conn)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stream, err := client.Watch(ctx, &healthpb.HealthCheckRequest{Service: "known"})
+	if err != nil {
+		t.Fatalf("error watching the health of the service: %s", err)
+	}
+
+	response, err := stream.Recv()
+	if assert.NoError(t, err, "The first message of the stream must be received.") {
+		assert.Equal(t, healthpb.HealthCheckResponse_SERVING, response.Status, "The service must be serving.")
+	}
+
+	cancel()
+	_, err = stream.Recv()
+	assert.Error(t, err, "The stream must end when the context is canceled.")
+
+	clientCalls := clientRecorder.recorded()
+	if assert.Len(t, clientCalls, 1, "The client stream must be instrumented exactly once.") {
+		assert.Equal(t, "/grpc.health.v1.Health/Watch", clientCalls[0].callInfo.FuncName, "The function label must be the full method name.")
+	}
+
+	// The server side of the stream ends asynchronously
+	assert.Eventually(t, func() bool {
+		serverCalls := serverRecorder.recorded()
+		return len(serverCalls) == 1 && serverCalls[0].callInfo.FuncName == "/grpc.health.v1.Health/Watch"
+	}, time.Second, 10*time.Millisecond, "The server stream must be instrumented exactly once.")
+}
+
+func TestClientStreamInterceptors(t *testing.T) {
+	var serverRecorder, clientRecorder recorder
+	conn := startHealthServer(t, &serverRecorder, &clientRecorder)
+
+	stream, err := conn.NewStream(context.Background(), &uploadServiceDesc.Streams[0], "/test.Upload/Send")
+	if err != nil {
+		t.Fatalf("error opening the upload stream: %s", err)
+	}
+
+	for i := 0; i < 2; i++ {
+		if err := stream.SendMsg(&healthpb.HealthCheckRequest{Service: "known"}); err != nil {
+			t.Fatalf("error sending a message: %s", err)
+		}
+	}
+
+	// Like the generated CloseAndRecv method.
+	if err := stream.CloseSend(); err != nil {
+		t.Fatalf("error closing the upload stream: %s", err)
+	}
+	var response healthpb.HealthCheckResponse
+	assert.NoError(t, stream.RecvMsg(&response), "The single response must be received.")
+
+	clientCalls := clientRecorder.recorded()
+	if assert.Len(t, clientCalls, 1, "The client stream must be instrumented once its response is received.") {
+		assert.Equal(t, "/test.Upload/Send", clientCalls[0].callInfo.FuncName, "The function label must be the full method name.")
+		assert.False(t, clientCalls[0].withError, "The upload must be successful.")
+	}
+
+	assert.Eventually(t, func() bool {
+		serverCalls := serverRecorder.recorded()
+		return len(serverCalls) == 1 && serverCalls[0].callInfo.FuncName == "/test.Upload/Send"
+	}, time.Second, 10*time.Millisecond, "The server stream must be instrumented exactly once.")
+}
+
+func TestAbandonedStreamInterceptors(t *testing.T) {
+	var serverRecorder, clientRecorder recorder
+	conn := startHealthServer(t, &serverRecorder, &clientRecorder)
+	client := healthpb.NewHealthClient(conn)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stream, err := client.Watch(ctx, &healthpb.HealthCheckRequest{Service: "known"})
+	if err != nil {
+		t.Fatalf("error watching the health of the service: %s", err)
+	}
+
+	_, err = stream.Recv()
+	assert.NoError(t, err, "The first message of the stream must be received.")
+
+	// The stream is not read until io.EOF, only its context is canceled.
+	cancel()
+
+	assert.Eventually(t, func() bool {
+		clientCalls := clientRecorder.recorded()
+		return len(clientCalls) == 1 && !clientCalls[0].withError
+	}, time.Second, 10*time.Millisecond, "The abandoned client stream must be instrumented as a canceled call.")
+}
+
+type profileLabelsOption struct{}
+
+func (profileLabelsOption) Apply(ctx *autometrics.Context) {
+	ctx.TrackProfileLabels = true
+}
+
+// goroutineLabels returns the pprof labels of the current goroutine, as written in
+// the goroutine profile, like `{"team":"checkout"}`.
+func goroutineLabels(t *testing.T) string {
+	var profile bytes.Buffer
+	if err := pprof.Lookup("goroutine").WriteTo(&profile, 1); err != nil {
+		t.Fatalf("error writing the goroutine profile: %s", err)
+	}
+
+	// The current goroutine is the one writing the profile.
+	for _, entry := range strings.Split(profile.String(), "\n\n") {
+		if !strings.Contains(entry, "runtime/pprof.writeGoroutine") {
+			continue
+		}
+		for _, line := range strings.Split(entry, "\n") {
+			if labels := strings.TrimPrefix(line, "# labels: "); labels != line {
+				return labels
+			}
+		}
+		return ""
+	}
+
+	t.Fatal("the current goroutine is not in the goroutine profile")
+	return ""
+}
+
+func TestStreamInterceptorsProfileLabels(t *testing.T) {
+	var serverRecorder, clientRecorder recorder
+	conn := startHealthServer(t, &serverRecorder, &clientRecorder,
+		WithMethodOptions(map[string][]autometrics.Option{
+			"/grpc.health.v1.Health/Watch": {profileLabelsOption{}},
+		}),
+	)
+	client := healthpb.NewHealthClient(conn)
+
+	// The labels of the caller, like the ones set by pprof.Do.
+	labelsCtx := pprof.WithLabels(context.Background(), pprof.Labels("team", "checkout"))
+	pprof.SetGoroutineLabels(labelsCtx)
+	defer pprof.SetGoroutineLabels(context.Background())
+	callerLabels := goroutineLabels(t)
+
+	ctx, cancel := context.WithCancel(labelsCtx)
+	defer cancel()
+	stream, err := client.Watch(ctx, &healthpb.HealthCheckRequest{Service: "known"})
+	if err != nil {
+		t.Fatalf("error watching the health of the service: %s", err)
+	}
+
+	assert.Equal(t, callerLabels, goroutineLabels(t),
+		"The labels of the caller must be kept, as the stream ends on another goroutine.")
+
+	_, err = stream.Recv()
+	assert.NoError(t, err, "The first message of the stream must be received.")
+}
@@ -0,0 +1,210 @@
+// Package http provides a net/http middleware that collects the autometrics
+// metrics of HTTP handlers, without the code generator.
+//
+// The function label of the metrics is the route pattern of the request, and the
+// result is derived from the status code of the response.
+package http // import "github.com/autometrics-dev/autometrics-go/pkg/autometrics/http"
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+
+	"github.com/autometrics-dev/autometrics-go/pkg/autometrics"
+)
+
+const (
+	// DefaultModuleName is the module label of the metrics collected by the middleware.
+	DefaultModuleName = "http"
+	// UnmatchedRoute is the function label of the requests that match no route pattern.
+	UnmatchedRoute = "unmatched"
+)
+
+var errHandlerPanicked = errors.New("the handler panicked")
+
+// Option is an option for the middleware.
+type Option func(*settings)
+
+type settings struct {
+	route       func(*http.Request) string
+	moduleName  string
+	isError     func(status int) bool
+	contextOpts []autometrics.Option
+}
+
+// WithRoute sets the function that returns the function label of a request.
+//
+// By default, the route pattern of the ServeMux is used when the wrapped handler
+// is a *http.ServeMux, and UnmatchedRoute otherwise. Use this option with other routers,
+// and never return unbounded values like the URL path.
+func WithRoute(route func(*http.Request) string) Option {
+	return func(s *settings) {
+		s.route = route
+	}
+}
+
+// WithFunctionName sets a fixed function label for all the requests.
+func WithFunctionName(name string) Option {
+	return WithRoute(func(*http.Request) string {
+		return name
+	})
+}
+
+// WithModuleName sets the module label of the metrics, DefaultModuleName by default.
+func WithModuleName(name string) Option {
+	return func(s *settings) {
+		s.moduleName = name
+	}
+}
+
+// WithErrorStatus sets the policy that decides whether a response status code is an error.
+//
+// By default, only the 5xx status codes are errors.
+func WithErrorStatus(isError func(status int) bool) Option {
+	return func(s *settings) {
+		s.isError = isError
+	}
+}
+
+// WithContextOptions adds options of the implementation to the instrumentation context,
+// like the SLO options.
+func WithContextOptions(opts ...autometrics.Option) Option {
+	return func(s *settings) {
+		s.contextOpts = append(s.contextOpts, opts...)
+	}
+}
+
+// IsServerError is the default status policy, where only the 5xx status codes are errors.
+func IsServerError(status int) bool {
+	return status >= 500
+}
+
+// Handler wraps the next handler so that every request is recorded in the autometrics metrics,
+// with the backend of an implementation like prometheus.Backend.
+//
+// The caller label is only set when the request context holds an instrumented
+// ancestor, for example when it is extracted by an [autometrics.Propagator].
+func Handler(backend autometrics.Backend, next http.Handler, opts ...Option) http.Handler {
+	s := settings{
+		moduleName: DefaultModuleName,
+		isError:    IsServerError,
+	}
+
+	if mux, ok := next.(*http.ServeMux); ok {
+		s.route = func(r *http.Request) string {
+			if _, pattern := mux.Handler(r); pattern != "" {
+				return pattern
+			}
+			return UnmatchedRoute
+		}
+	} else {
+		s.route = func(*http.Request) string {
+			return UnmatchedRoute
+		}
+	}
+
+	for _, o := range opts {
+		o(&s)
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _, hasAncestor := autometrics.CallerFromContext(r.Context())
+
+		contextOpts := append([]autometrics.Option{
+			callInfoOption(s.route(r), s.moduleName),
+			trackCallerOption(hasAncestor),
+			requestOption(&r),
+		}, s.contextOpts...)
+
+		// The error stays set if the handler panics.
+		err := errHandlerPanicked
+		defer backend.Instrument(backend.PreInstrument(backend.NewContext(contextOpts...)), &err)
+
+		recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(recorder, r)
+
+		err = nil
+		if s.isError(recorder.status) {
+			err = fmt.Errorf("response status %d", recorder.status)
+		}
+	})
+}
+
+type optionFunc func(*autometrics.Context)
+
+func (fn optionFunc) Apply(ctx *autometrics.Context) {
+	fn(ctx)
+}
+
+// callInfoOption sets the names of the function, so that PreInstrument does not read them from the stack frames.
+func callInfoOption(funcName, moduleName string) autometrics.Option {
+	return optionFunc(func(ctx *autometrics.Context) {
+		ctx.CallInfo.FuncName = funcName
+		ctx.CallInfo.ModuleName = moduleName
+	})
+}
+
+func trackCallerOption(enabled bool) autometrics.Option {
+	return optionFunc(func(ctx *autometrics.Context) {
+		ctx.TrackCallerName = enabled
+	})
+}
+
+func requestOption(r **http.Request) autometrics.Option {
+	return optionFunc(func(ctx *autometrics.Context) {
+		ctx.RequestPointer = r
+	})
+}
+
+// statusRecorder records the status code of the response.
+type statusRecorder struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	if !r.wroteHeader {
+		r.status = status
+		r.wroteHeader = true
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *statusRecorder) Write(b []byte) (int, error) {
+	r.wroteHeader = true
+	return r.ResponseWriter.Write(b)
+}
+
+// Flush implements http.Flusher if the wrapped ResponseWriter does.
+func (r *statusRecorder) Flush() {
+	if flusher, ok := r.ResponseWriter.(http.Flusher); ok {
+		r.wroteHeader = true
+		flusher.Flush()
+	}
+}
+
+// Hijack implements http.Hijacker if the wrapped ResponseWriter does, for the websocket upgrades.
+//
+// A hijacked connection without a status code is recorded with the 101 Switching Protocols status.
+func (r *statusRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := r.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, fmt.Errorf("%T does not implement http.Hijacker: %w", r.ResponseWriter, http.ErrNotSupported)
+	}
+
+	conn, rw, err := hijacker.Hijack()
+	if err == nil && !r.wroteHeader {
+		r.status = http.StatusSwitchingProtocols
+		r.wroteHeader = true
+	}
+
+	return conn, rw, err
+}
+
+// Unwrap returns the wrapped ResponseWriter, for http.ResponseController.
+func (r *statusRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
@@ -0,0 +1,191 @@
+package http
+
+import (
+	"bufio"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/autometrics-dev/autometrics-go/pkg/autometrics"
+	"github.com/stretchr/testify/assert"
+)
+
+type recordedCall struct {
+	callInfo autometrics.CallInfo
+	err      error
+}
+
+// recordingBackend is an autometrics.Backend that records the instrumented calls.
+type recordingBackend struct {
+	calls *[]recordedCall
+}
+
+func (b recordingBackend) NewContext(opts ...autometrics.Option) *autometrics.Context {
+	ctx := autometrics.NewContext()
+	for _, o := range opts {
+		o.Apply(&ctx)
+	}
+	return &ctx
+}
+
+func (b recordingBackend) PreInstrument(ctx *autometrics.Context) *autometrics.Context {
+	ctx.PropagateCaller()
+	return ctx
+}
+
+func (b recordingBackend) Instrument(ctx *autometrics.Context, err *error) {
+	*b.calls = append(*b.calls, recordedCall{callInfo: ctx.CallInfo, err: *err})
+}
+
+func (b recordingBackend) RegisterFunction(string, string, *autometrics.Context) {}
+
+func TestHandler(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/items/", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	})
+	mux.HandleFunc("/fail", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	})
+	mux.HandleFunc("/panic", func(w http.ResponseWriter, r *http.Request) {
+		panic(http.ErrAbortHandler)
+	})
+
+	testCases := []struct {
+		name         string
+		path         string
+		opts         []Option
+		wantFunction string
+		wantModule   string
+		wantError    bool
+	}{
+		{
+			name:         "route pattern",
+			path:         "/items/42",
+			wantFunction: "/items/",
+			wantModule:   DefaultModuleName,
+			wantError:    false,
+		},
+		{
+			name:         "server error",
+			path:         "/fail",
+			wantFunction: "/fail",
+			wantModule:   DefaultModuleName,
+			wantError:    true,
+		},
+		{
+			name:         "panic",
+			path:         "/panic",
+			wantFunction: "/panic",
+			wantModule:   DefaultModuleName,
+			wantError:    true,
+		},
+		{
+			name:         "unmatched route",
+			path:         "/unknown",
+			wantFunction: UnmatchedRoute,
+			wantModule:   DefaultModuleName,
+			wantError:    false,
+		},
+		{
+			name: "custom status policy and names",
+			path: "/items/42",
+			opts: []Option{
+				WithErrorStatus(func(status int) bool { return status >= 400 }),
+				WithFunctionName("items"),
+				WithModuleName("api"),
+			},
+			wantFunction: "items",
+			wantModule:   "api",
+			wantError:    true,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			var calls []recordedCall
+			handler := Handler(recordingBackend{calls: &calls}, mux, testCase.opts...)
+
+			func() {
+				defer func() { _ = recover() }()
+				handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, testCase.path, nil))
+			}()
+
+			if !assert.Len(t, calls, 1, "The request must be instrumented exactly once.") {
+				return
+			}
+			assert.Equal(t, testCase.wantFunction, calls[0].callInfo.FuncName, "The function label is not as expected.")
+			assert.Equal(t, testCase.wantModule, calls[0].callInfo.ModuleName, "The module label is not as expected.")
+			assert.Equal(t, testCase.wantError, calls[0].err != nil, "The result is not as expected.")
+		})
+	}
+}
+
+// notifyingBackend is a recordingBackend that signals the end of each instrumented call.
+type notifyingBackend struct {
+	recordingBackend
+	done chan struct{}
+}
+
+func (b notifyingBackend) Instrument(ctx *autometrics.Context, err *error) {
+	b.recordingBackend.Instrument(ctx, err)
+	b.done <- struct{}{}
+}
+
+func TestHandlerHijack(t *testing.T) {
+	var calls []recordedCall
+	backend := notifyingBackend{recordingBackend: recordingBackend{calls: &calls}, done: make(chan struct{}, 1)}
+
+	server := httptest.NewServer(Handler(backend, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hijacker, ok := w.(http.Hijacker)
+		if !ok {
+			w.WriteHeader(http.StatusNotImplemented)
+			return
+		}
+
+		conn, rw, err := hijacker.Hijack()
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		defer conn.Close()
+
+		_, _ = rw.WriteString("HTTP/1.1 101 Switching Protocols\r\nUpgrade: test\r\nConnection: Upgrade\r\n\r\n")
+		_ = rw.Flush()
+	}), WithFunctionName("upgrade")))
+	defer server.Close()
+
+	conn, err := net.Dial("tcp", server.Listener.Addr().String())
+	if err != nil {
+		t.Fatalf("error connecting to the server: %s", err)
+	}
+	defer conn.Close()
+
+	_, err = conn.Write([]byte("GET / HTTP/1.1\r\nHost: test\r\nUpgrade: test\r\nConnection: Upgrade\r\n\r\n"))
+	if err != nil {
+		t.Fatalf("error sending the upgrade request: %s", err)
+	}
+
+	response, err := http.ReadResponse(bufio.NewReader(conn), nil)
+	if err != nil {
+		t.Fatalf("error reading the upgrade response: %s", err)
+	}
+	assert.Equal(t, http.StatusSwitchingProtocols, response.StatusCode, "The handler must be able to hijack the connection.")
+
+	select {
+	case <-backend.done:
+	case <-time.After(time.Second):
+		t.Fatal("The upgraded request was not instrumented.")
+	}
+
+	if assert.Len(t, calls, 1, "The request must be instrumented exactly once.") {
+		assert.Equal(t, "upgrade", calls[0].callInfo.FuncName, "The function label is not as expected.")
+		assert.Nil(t, calls[0].err, "An upgraded request must be successful.")
+	}
+
+	recorder := &statusRecorder{ResponseWriter: httptest.NewRecorder()}
+	_, _, err = recorder.Hijack()
+	assert.ErrorIs(t, err, http.ErrNotSupported, "Hijack must fail when the wrapped ResponseWriter cannot hijack.")
+}
@@ -0,0 +1,144 @@
+package autometrics
+
+import (
+	"os"
+	"runtime/debug"
+	"time"
+)
+
+const (
+	// VersionEnvironmentVariable is the environment variable that overrides the version
+	// of the build information.
+	VersionEnvironmentVariable = "AUTOMETRICS_VERSION"
+	// CommitEnvironmentVariable is the environment variable that overrides the commit
+	// of the build information.
+	CommitEnvironmentVariable = "AUTOMETRICS_COMMIT"
+	// BranchEnvironmentVariable is the environment variable that overrides the branch
+	// of the build information.
+	BranchEnvironmentVariable = "AUTOMETRICS_BRANCH"
+	// ServiceNameEnvironmentVariable is the environment variable that sets the name
+	// of the service in all the metrics.
+	ServiceNameEnvironmentVariable = "AUTOMETRICS_SERVICE_NAME"
+)
+
+// InitOption is an option for the Init function of the implementations.
+type InitOption interface {
+	// ApplyInit applies the option to the settings used in Init.
+	ApplyInit(*InitSettings)
+}
+
+// InitSettings holds the configuration of the metrics collection
+// that is shared by all the instrumented functions.
+type InitSettings struct {
+	// BuildInfo is the information about the build of the running program.
+	BuildInfo BuildInfo
+	// ServiceName is the name of the service, added as a label to all the metrics.
+	ServiceName string
+	// NamingScheme is the set of names used for the metrics and labels.
+	NamingScheme NamingScheme
+	// CardinalityLimit is the maximum number of distinct callers recorded for each
+	// function, the other callers are recorded as OverflowLabelValue. 0 means no limit.
+	CardinalityLimit int
+	// ClosureSuffix keeps the suffix of closures in the function and caller names,
+	// see [SetClosureSuffix].
+	ClosureSuffix bool
+	// ProfileLabels applies pprof labels to the goroutines of the calls of all the
+	// instrumented functions, see [SetProfileLabels].
+	ProfileLabels bool
+	// RuntimeTrace opens runtime/trace regions or tasks for the calls of all the
+	// instrumented functions, see [SetRuntimeTrace].
+	RuntimeTrace bool
+	// Logger receives the log records of the failed and slow calls, see [SetLogger].
+	Logger Logger
+	// LogInterval is the minimum time between two log records of the same function,
+	// see [SetLogger].
+	LogInterval time.Duration
+	// AllowedLabels are the user-defined labels added to the function call metrics,
+	// see [LabelAllowlist].
+	AllowedLabels []AllowedLabel
+	// BatchMode records the last successful run and the duration of the last run of
+	// each function, for the programs that exit before being scraped.
+	BatchMode bool
+	// DurationDistributions sends the durations as DogStatsD distributions instead of
+	// StatsD timers, with the statsd implementation.
+	DurationDistributions bool
+}
+
+// NamingScheme is an enumeration type for the possible sets of names
+// used for the metrics and labels.
+type NamingScheme int
+
+const (
+	// LegacyNaming uses the historical names of this library, like
+	// function_calls_count or the single caller label.
+	LegacyNaming NamingScheme = iota
+	// SpecNaming uses the names of the Autometrics specification, shared with
+	// the other Autometrics implementations, like function_calls_total or the
+	// caller_function and caller_module labels.
+	SpecNaming
+)
+
+// BuildInfo holds the information about the build of the running program.
+type BuildInfo struct {
+	// Version is the version of the running program.
+	Version string
+	// Commit is the commit hash of the running program.
+	Commit string
+	// Branch is the VCS branch the running program was built from.
+	Branch string
+}
+
+// NewInitSettings builds the settings for Init.
+//
+// The build information is read from the VCS settings embedded in the binary
+// by the Go toolchain, then overridden by the AUTOMETRICS_VERSION, AUTOMETRICS_COMMIT
+// and AUTOMETRICS_BRANCH environment variables, then by the options.
+//
+// The service name is read from the AUTOMETRICS_SERVICE_NAME environment variable,
+// then overridden by the options.
+func NewInitSettings(opts ...InitOption) InitSettings {
+	settings := InitSettings{
+		BuildInfo: readBuildInfo(),
+	}
+
+	if version, ok := os.LookupEnv(VersionEnvironmentVariable); ok {
+		settings.BuildInfo.Version = version
+	}
+	if commit, ok := os.LookupEnv(CommitEnvironmentVariable); ok {
+		settings.BuildInfo.Commit = commit
+	}
+	if branch, ok := os.LookupEnv(BranchEnvironmentVariable); ok {
+		settings.BuildInfo.Branch = branch
+	}
+	if serviceName, ok := os.LookupEnv(ServiceNameEnvironmentVariable); ok {
+		settings.ServiceName = serviceName
+	}
+
+	for _, o := range opts {
+		o.ApplyInit(&settings)
+	}
+
+	return settings
+}
+
+// readBuildInfo returns the build information embedded in the binary.
+//
+// The Go toolchain does not record the branch, so it is always empty.
+func readBuildInfo() (buildInfo BuildInfo) {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return
+	}
+
+	if info.Main.Version != "(devel)" {
+		buildInfo.Version = info.Main.Version
+	}
+
+	for _, setting := range info.Settings {
+		if setting.Key == "vcs.revision" {
+			buildInfo.Commit = setting.Value
+		}
+	}
+
+	return
+}
@@ -1,8 +1,10 @@
 package autometrics
 
 import (
+	"fmt"
 	"runtime"
 	"strings"
+	"sync/atomic"
 )
 
 type Option interface {
@@ -10,6 +12,22 @@ type Option interface {
 	Apply(*Context)
 }
 
+// closureSuffix is 1 when the closure suffix is kept in the function names, see SetClosureSuffix.
+var closureSuffix int32
+
+// SetClosureSuffix sets whether the names of closures keep their suffix, like
+// "handler.func1", instead of being attributed to their enclosing function, like "handler".
+//
+// The Init function of the implementations calls it with the value of the WithClosureSuffix option.
+func SetClosureSuffix(enabled bool) {
+	var value int32
+	if enabled {
+		value = 1
+	}
+
+	atomic.StoreInt32(&closureSuffix, value)
+}
+
 // CallerInfo returns the (method name, module name) of the function that called the function that called this function.
 //
 // It also returns the information about its grandparent.
@@ -21,6 +39,8 @@ type Option interface {
 // then we can lift this artificial limitation here and use the full "module name" from the caller information.
 // Currently this compromise is the only way to have the documentation links generator creating correct
 // queries.
+//
+// See parseFrameName for the way the names of methods, closures and generic functions are normalized.
 func CallerInfo() (callInfo CallInfo) {
 	programCounters := make([]uintptr, 15)
 
@@ -30,24 +50,12 @@ func CallerInfo() (callInfo CallInfo) {
 	// frame 2: Instrument() calling this function -- we don't really care about our own library code
 	entries := runtime.Callers(3, programCounters)
 
+	// CallersFrames expands the inlined functions, so each frame is a function of the source code.
 	frames := runtime.CallersFrames(programCounters[:entries])
 	frame, hasParent := frames.Next()
 
-	functionName := frame.Function
-	index := strings.LastIndex(functionName, ".")
-
-	if index == -1 {
-		callInfo.FuncName = frame.Func.Name()
-	} else {
-		moduleIndex := strings.LastIndex(functionName[:index], ".")
-		if moduleIndex == -1 {
-			callInfo.ModuleName = functionName[:index]
-		} else {
-			callInfo.ModuleName = functionName[moduleIndex+1 : index]
-		}
-
-		callInfo.FuncName = functionName[index+1:]
-	}
+	keepClosureSuffix := atomic.LoadInt32(&closureSuffix) == 1
+	callInfo.FuncName, callInfo.ModuleName = parseFrameName(frame.Function, keepClosureSuffix)
 
 	if !hasParent {
 		return
@@ -55,22 +63,109 @@ func CallerInfo() (callInfo CallInfo) {
 
 	// Do the same with the parent
 	parentFrame, _ := frames.Next()
+	callInfo.ParentFuncName, callInfo.ParentModuleName = parseFrameName(parentFrame.Function, keepClosureSuffix)
+
+	return
+}
+
+// parseFrameName splits the fully qualified name of a runtime frame, like
+// "github.com/org/repo/pkg.(*Type[...]).Method.func1", into a function name
+// and a module name.
+//
+// The module name is the last element of the package path, with the escaped dots restored.
+//
+// The function name is normalized so that it matches the name in the source code:
+//   - methods are named "Type.Method", whether the receiver is a pointer or not,
+//   - the type parameters of generic functions and types are removed,
+//   - the "-fm" suffix of method values is removed,
+//   - closures, goroutine and defer wrappers are attributed to their enclosing
+//     function, unless keepClosureSuffix is true: then they keep their suffix,
+//     like "handler.func1".
+func parseFrameName(name string, keepClosureSuffix bool) (funcName, moduleName string) {
+	name = stripTypeParameters(name)
 
-	parentFunctionName := parentFrame.Function
-	index = strings.LastIndex(parentFunctionName, ".")
-
-	if index == -1 {
-		callInfo.ParentFuncName = parentFrame.Func.Name()
-	} else {
-		moduleIndex := strings.LastIndex(parentFunctionName[:index], ".")
-		if moduleIndex == -1 {
-			callInfo.ParentModuleName = parentFunctionName[:index]
-		} else {
-			callInfo.ParentModuleName = parentFunctionName[moduleIndex+1 : index]
+	// The package path is everything up to the first dot after the last slash,
+	// as dots in the last element of the package path are escaped.
+	lastSlash := strings.LastIndex(name, "/")
+	dot := strings.Index(name[lastSlash+1:], ".")
+	if dot == -1 {
+		return name, ""
+	}
+	dot += lastSlash + 1
+
+	moduleName = strings.ReplaceAll(name[lastSlash+1:dot], "%2e", ".")
+
+	symbol := strings.TrimSuffix(name[dot+1:], "-fm")
+	parts := strings.Split(symbol, ".")
+
+	var functionParts []string
+	i := 0
+	for ; i < len(parts); i++ {
+		if i > 0 && isClosurePart(parts[i]) {
+			break
 		}
 
-		callInfo.ParentFuncName = parentFunctionName[index+1:]
+		// Package level closures have an empty part, like "glob..func1"
+		if parts[i] != "" {
+			functionParts = append(functionParts, strings.TrimSuffix(strings.TrimPrefix(parts[i], "(*"), ")"))
+		}
 	}
 
-	return
+	funcName = strings.Join(functionParts, ".")
+	if keepClosureSuffix && i < len(parts) {
+		funcName = fmt.Sprintf("%s.%s", funcName, strings.Join(parts[i:], "."))
+	}
+
+	return funcName, moduleName
+}
+
+// isClosurePart returns true if the part of a frame name is added by the compiler
+// for a closure ("func1", or "1" for nested closures), a goroutine wrapper ("gowrap1"),
+// a defer wrapper ("deferwrap1") or one of multiple init functions ("init.0").
+func isClosurePart(part string) bool {
+	for _, prefix := range []string{"func", "gowrap", "deferwrap"} {
+		if rest, found := cutPrefix(part, prefix); found {
+			part = rest
+			break
+		}
+	}
+
+	if part == "" {
+		return false
+	}
+
+	for _, r := range part {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+
+	return true
+}
+
+// stripTypeParameters removes the bracketed type parameters from the name, like "[...]".
+func stripTypeParameters(name string) string {
+	var builder strings.Builder
+	depth := 0
+
+	for _, r := range name {
+		switch {
+		case r == '[':
+			depth++
+		case r == ']' && depth > 0:
+			depth--
+		case depth == 0:
+			builder.WriteRune(r)
+		}
+	}
+
+	return builder.String()
+}
+
+// Backport of strings.CutPrefix for pre-1.20
+func cutPrefix(s, prefix string) (after string, found bool) {
+	if !strings.HasPrefix(s, prefix) {
+		return s, false
+	}
+	return s[len(prefix):], true
 }
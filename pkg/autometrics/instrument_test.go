@@ -0,0 +1,216 @@
+package autometrics
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseFrameName(t *testing.T) {
+	testCases := []struct {
+		name              string
+		frame             string
+		keepClosureSuffix bool
+		wantFunction      string
+		wantModule        string
+	}{
+		{
+			name:         "function",
+			frame:        "github.com/org/repo/pkg.handler",
+			wantFunction: "handler",
+			wantModule:   "pkg",
+		},
+		{
+			name:         "main package",
+			frame:        "main.main",
+			wantFunction: "main",
+			wantModule:   "main",
+		},
+		{
+			name:         "escaped dot in package",
+			frame:        "gopkg.in/yaml%2ev3.Marshal",
+			wantFunction: "Marshal",
+			wantModule:   "yaml.v3",
+		},
+		{
+			name:         "method",
+			frame:        "github.com/org/repo/pkg.Server.Serve",
+			wantFunction: "Server.Serve",
+			wantModule:   "pkg",
+		},
+		{
+			name:         "pointer method",
+			frame:        "github.com/org/repo/pkg.(*Server).Serve",
+			wantFunction: "Server.Serve",
+			wantModule:   "pkg",
+		},
+		{
+			name:         "method value",
+			frame:        "github.com/org/repo/pkg.(*Server).Serve-fm",
+			wantFunction: "Server.Serve",
+			wantModule:   "pkg",
+		},
+		{
+			name:         "closure",
+			frame:        "github.com/org/repo/pkg.handler.func1",
+			wantFunction: "handler",
+			wantModule:   "pkg",
+		},
+		{
+			name:              "closure with suffix",
+			frame:             "github.com/org/repo/pkg.handler.func1",
+			keepClosureSuffix: true,
+			wantFunction:      "handler.func1",
+			wantModule:        "pkg",
+		},
+		{
+			name:         "nested closure",
+			frame:        "github.com/org/repo/pkg.handler.func1.2",
+			wantFunction: "handler",
+			wantModule:   "pkg",
+		},
+		{
+			name:              "nested closure with suffix",
+			frame:             "github.com/org/repo/pkg.handler.func1.2",
+			keepClosureSuffix: true,
+			wantFunction:      "handler.func1.2",
+			wantModule:        "pkg",
+		},
+		{
+			name:         "closure in a method",
+			frame:        "github.com/org/repo/pkg.(*Server).Serve.func3",
+			wantFunction: "Server.Serve",
+			wantModule:   "pkg",
+		},
+		{
+			name:         "package level closure",
+			frame:        "github.com/org/repo/pkg.glob..func1",
+			wantFunction: "glob",
+			wantModule:   "pkg",
+		},
+		{
+			name:         "goroutine wrapper",
+			frame:        "github.com/org/repo/pkg.handler.gowrap1",
+			wantFunction: "handler",
+			wantModule:   "pkg",
+		},
+		{
+			name:         "defer wrapper",
+			frame:        "github.com/org/repo/pkg.handler.deferwrap2",
+			wantFunction: "handler",
+			wantModule:   "pkg",
+		},
+		{
+			name:         "multiple init functions",
+			frame:        "github.com/org/repo/pkg.init.0",
+			wantFunction: "init",
+			wantModule:   "pkg",
+		},
+		{
+			name:         "generic function",
+			frame:        "github.com/org/repo/pkg.Map[...]",
+			wantFunction: "Map",
+			wantModule:   "pkg",
+		},
+		{
+			name:         "generic function with shapes",
+			frame:        "github.com/org/repo/pkg.Map[go.shape.string,go.shape.[]uint8]",
+			wantFunction: "Map",
+			wantModule:   "pkg",
+		},
+		{
+			name:         "closure in a generic function",
+			frame:        "github.com/org/repo/pkg.Map[...].func1",
+			wantFunction: "Map",
+			wantModule:   "pkg",
+		},
+		{
+			name:         "method of a generic type",
+			frame:        "github.com/org/repo/pkg.(*Cache[...]).Get",
+			wantFunction: "Cache.Get",
+			wantModule:   "pkg",
+		},
+		{
+			name:         "no package",
+			frame:        "handler",
+			wantFunction: "handler",
+			wantModule:   "",
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			function, module := parseFrameName(testCase.frame, testCase.keepClosureSuffix)
+			assert.Equal(t, testCase.wantFunction, function, "The function name is not as expected.")
+			assert.Equal(t, testCase.wantModule, module, "The module name is not as expected.")
+		})
+	}
+}
+
+type callerInfoReceiver struct{}
+
+// preInstrument mimics the PreInstrument functions of the implementations,
+// which are the function calling CallerInfo.
+func preInstrument() CallInfo {
+	return CallerInfo()
+}
+
+func (callerInfoReceiver) method() CallInfo {
+	return preInstrument()
+}
+
+func genericFunction[T any]() CallInfo {
+	return preInstrument()
+}
+
+// inlinedFunction is small enough to be inlined by the compiler.
+func inlinedFunction() CallInfo {
+	return preInstrument()
+}
+
+func callerOfInlinedFunction() CallInfo {
+	return inlinedFunction()
+}
+
+func TestCallerInfo(t *testing.T) {
+	testCases := []struct {
+		name         string
+		callInfo     func() CallInfo
+		wantFunction string
+		wantParent   string
+	}{
+		{
+			name:         "method",
+			callInfo:     callerInfoReceiver{}.method,
+			wantFunction: "callerInfoReceiver.method",
+			wantParent:   "TestCallerInfo",
+		},
+		{
+			name:         "closure",
+			callInfo:     func() CallInfo { return preInstrument() },
+			wantFunction: "TestCallerInfo",
+			wantParent:   "TestCallerInfo",
+		},
+		{
+			name:         "generic function",
+			callInfo:     genericFunction[string],
+			wantFunction: "genericFunction",
+			wantParent:   "TestCallerInfo",
+		},
+		{
+			name:         "inlined function",
+			callInfo:     callerOfInlinedFunction,
+			wantFunction: "inlinedFunction",
+			wantParent:   "callerOfInlinedFunction",
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			callInfo := testCase.callInfo()
+			assert.Equal(t, testCase.wantFunction, callInfo.FuncName, "The function name is not as expected.")
+			assert.Equal(t, "autometrics", callInfo.ModuleName, "The module name is not as expected.")
+			assert.Equal(t, testCase.wantParent, callInfo.ParentFuncName, "The parent function name is not as expected.")
+		})
+	}
+}
@@ -0,0 +1,121 @@
+package autometrics
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// labelNamePattern is the syntax of the label names, shared by Prometheus, OpenTelemetry and StatsD.
+var labelNamePattern = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+// Label is a user-defined label of the metrics of a call, given with the WithLabel option
+// of the implementations.
+type Label struct {
+	// Name is the name of the label, declared in Init with the WithLabelAllowlist option.
+	Name string
+	// Value is the value of the label for the call.
+	Value string
+}
+
+// AllowedLabel is a user-defined label declared in Init, with the values it can take.
+type AllowedLabel struct {
+	// Name is the name of the label.
+	Name string
+	// Values are the allowed values of the label, the other values are recorded as OverflowLabelValue.
+	Values []string
+}
+
+// ValidateLabelName returns an error if name cannot be the name of a user-defined label.
+func ValidateLabelName(name string) error {
+	if !labelNamePattern.MatchString(name) {
+		return fmt.Errorf("invalid label name %q: it must match %v", name, labelNamePattern)
+	}
+
+	if strings.HasPrefix(name, "__") {
+		return fmt.Errorf("invalid label name %q: the names starting with '__' are reserved", name)
+	}
+
+	return nil
+}
+
+// LabelAllowlist holds the user-defined labels declared in Init, that are added to the
+// function call metrics after the labels of the base schema.
+//
+// A nil LabelAllowlist has no labels.
+type LabelAllowlist struct {
+	names  []string
+	values map[string]map[string]struct{}
+}
+
+// NewLabelAllowlist builds the allowlist of the labels, checking that their names are valid
+// and are not one of the reserved names of the base schema.
+func NewLabelAllowlist(labels []AllowedLabel, reserved ...string) (*LabelAllowlist, error) {
+	allowlist := &LabelAllowlist{
+		values: make(map[string]map[string]struct{}),
+	}
+
+	for _, label := range labels {
+		if err := ValidateLabelName(label.Name); err != nil {
+			return nil, err
+		}
+
+		if contains(reserved, label.Name) {
+			return nil, fmt.Errorf("invalid label name %q: it is already a label of the metrics", label.Name)
+		}
+
+		if _, ok := allowlist.values[label.Name]; ok {
+			return nil, fmt.Errorf("the label %q is declared more than once", label.Name)
+		}
+
+		values := make(map[string]struct{}, len(label.Values))
+		for _, value := range label.Values {
+			values[value] = struct{}{}
+		}
+
+		allowlist.names = append(allowlist.names, label.Name)
+		allowlist.values[label.Name] = values
+	}
+
+	return allowlist, nil
+}
+
+// Names returns the names of the labels, in the order of their declaration.
+func (a *LabelAllowlist) Names() []string {
+	if a == nil {
+		return nil
+	}
+
+	return a.names
+}
+
+// Values returns the values of the labels for the call, in the order of Names.
+//
+// The labels missing from the call have an empty value, and the values missing from the
+// allowlist are replaced with OverflowLabelValue: overflowed holds the names of those labels.
+// The labels of the call that were not declared in Init are ignored.
+func (a *LabelAllowlist) Values(ctx *Context) (values []string, overflowed []string) {
+	if a == nil || len(a.names) == 0 {
+		return nil, nil
+	}
+
+	values = make([]string, len(a.names))
+	for i, name := range a.names {
+		for _, label := range ctx.Labels {
+			if label.Name != name {
+				continue
+			}
+
+			if _, ok := a.values[name][label.Value]; ok {
+				values[i] = label.Value
+			} else {
+				values[i] = OverflowLabelValue
+				overflowed = append(overflowed, name)
+			}
+
+			break
+		}
+	}
+
+	return values, overflowed
+}
@@ -0,0 +1,167 @@
+package autometrics
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultLogInterval is the minimum time between two log records of the same function,
+	// when the interval given to SetLogger is 0.
+	DefaultLogInterval = time.Second
+
+	// FailedCallMessage is the message of the log records of the calls that returned an error.
+	FailedCallMessage = "autometrics: function call failed"
+	// SlowCallMessage is the message of the log records of the calls that exceeded the latency
+	// target of their SLO.
+	SlowCallMessage = "autometrics: function call exceeded its latency target"
+)
+
+// Logger receives the log records of the failed and slow calls, as a message followed
+// by alternating keys and values.
+//
+// A *slog.Logger of the standard library implements Logger.
+type Logger interface {
+	// ErrorContext logs a call that returned an error.
+	ErrorContext(ctx context.Context, msg string, args ...interface{})
+	// WarnContext logs a call that exceeded the latency target of its SLO.
+	WarnContext(ctx context.Context, msg string, args ...interface{})
+}
+
+// callLogger logs the calls, with at most one record per function and per interval.
+type callLogger struct {
+	logger   Logger
+	interval time.Duration
+
+	mutex     sync.Mutex
+	functions map[functionKey]*loggedFunction
+}
+
+type functionKey struct {
+	funcName   string
+	moduleName string
+}
+
+// loggedFunction is the rate limiting state of a function.
+type loggedFunction struct {
+	lastRecord time.Time
+	suppressed int
+}
+
+var (
+	callLoggerMutex sync.RWMutex
+	currentLogger   *callLogger
+)
+
+// SetLogger sets the logger of the failed and slow calls, nil to disable the log records.
+//
+// The records are rate limited: a function logs at most one record per interval, and the
+// next record has a "suppressed" value with the number of records dropped in between.
+// An interval of 0 means DefaultLogInterval.
+//
+// The Init function of the implementations calls it with the values of the WithLogger
+// and WithLogInterval options.
+func SetLogger(logger Logger, interval time.Duration) {
+	var l *callLogger
+	if logger != nil {
+		if interval <= 0 {
+			interval = DefaultLogInterval
+		}
+
+		l = &callLogger{
+			logger:    logger,
+			interval:  interval,
+			functions: make(map[functionKey]*loggedFunction),
+		}
+	}
+
+	callLoggerMutex.Lock()
+	defer callLoggerMutex.Unlock()
+	currentLogger = l
+}
+
+// LogCall gives a finished call to the logger set with SetLogger, if the call returned an
+// error or exceeded the latency target of one of its SLOs.
+//
+// The implementations call it from their Instrument function.
+func LogCall(ctx *Context, err error, duration time.Duration) {
+	callLoggerMutex.RLock()
+	l := currentLogger
+	callLoggerMutex.RUnlock()
+
+	if l == nil {
+		return
+	}
+
+	// The lowest latency target exceeded by the call, if any.
+	var threshold time.Duration
+	for _, alertConf := range ctx.AlertConfs() {
+		if alertConf.Latency != nil && duration > alertConf.Latency.Target &&
+			(threshold == 0 || alertConf.Latency.Target < threshold) {
+			threshold = alertConf.Latency.Target
+		}
+	}
+	slow := threshold > 0
+	if err == nil && !slow {
+		return
+	}
+
+	suppressed, ok := l.allow(ctx.CallInfo.FuncName, ctx.CallInfo.ModuleName)
+	if !ok {
+		return
+	}
+
+	args := []interface{}{
+		"function", ctx.CallInfo.FuncName,
+		"module", ctx.CallInfo.ModuleName,
+	}
+	if ctx.CallInfo.ParentFuncName != "" {
+		args = append(args, "caller", fmt.Sprintf("%s.%s", ctx.CallInfo.ParentModuleName, ctx.CallInfo.ParentFuncName))
+	}
+	args = append(args, "duration", duration)
+	if names := ctx.ObjectiveNames(); names != "" {
+		args = append(args, "objective_name", names)
+	}
+	if suppressed > 0 {
+		args = append(args, "suppressed", suppressed)
+	}
+
+	goCtx := ctx.Context
+	if goCtx == nil {
+		goCtx = context.Background()
+	}
+
+	if err != nil {
+		l.logger.ErrorContext(goCtx, FailedCallMessage, append(args, "error", err)...)
+	} else {
+		l.logger.WarnContext(goCtx, SlowCallMessage, append(args, "latency_threshold", threshold)...)
+	}
+}
+
+// allow returns true if the function can log a record now, along with the number of
+// records it dropped since its last record.
+func (l *callLogger) allow(funcName, moduleName string) (suppressed int, ok bool) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	key := functionKey{funcName: funcName, moduleName: moduleName}
+	function, found := l.functions[key]
+	if !found {
+		function = &loggedFunction{}
+		l.functions[key] = function
+	}
+
+	now := time.Now()
+	if found && now.Sub(function.lastRecord) < l.interval {
+		function.suppressed++
+		return 0, false
+	}
+
+	suppressed = function.suppressed
+	function.lastRecord = now
+	function.suppressed = 0
+
+	return suppressed, true
+}
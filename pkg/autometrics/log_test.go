@@ -0,0 +1,118 @@
+package autometrics
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type logRecord struct {
+	level string
+	msg   string
+	args  map[interface{}]interface{}
+}
+
+// recordingLogger is a Logger that records the log records.
+type recordingLogger struct {
+	records []logRecord
+}
+
+func (l *recordingLogger) record(level, msg string, args []interface{}) {
+	values := make(map[interface{}]interface{})
+	for i := 0; i+1 < len(args); i += 2 {
+		values[args[i]] = args[i+1]
+	}
+	l.records = append(l.records, logRecord{level: level, msg: msg, args: values})
+}
+
+func (l *recordingLogger) ErrorContext(_ context.Context, msg string, args ...interface{}) {
+	l.record("error", msg, args)
+}
+
+func (l *recordingLogger) WarnContext(_ context.Context, msg string, args ...interface{}) {
+	l.record("warn", msg, args)
+}
+
+func TestLogCall(t *testing.T) {
+	logger := &recordingLogger{}
+	SetLogger(logger, time.Hour)
+	defer SetLogger(nil, 0)
+
+	ctx := NewContext()
+	ctx.CallInfo = CallInfo{FuncName: "indexHandler", ModuleName: "main", ParentFuncName: "serve", ParentModuleName: "http"}
+	ctx.AlertConf = &AlertConfiguration{ServiceName: "API", Latency: &LatencySlo{Target: 100 * time.Millisecond, Objective: 99}}
+
+	callErr := errors.New("the call failed")
+	LogCall(&ctx, nil, time.Millisecond)
+	LogCall(&ctx, callErr, time.Millisecond)
+	LogCall(&ctx, callErr, time.Millisecond)
+	LogCall(&ctx, nil, time.Second)
+
+	other := ctx
+	other.CallInfo.FuncName = "otherHandler"
+	LogCall(&other, nil, time.Second)
+
+	if !assert.Len(t, logger.records, 2, "The successful calls must not be logged, and the records must be rate limited per function.") {
+		return
+	}
+
+	failed := logger.records[0]
+	assert.Equal(t, "error", failed.level)
+	assert.Equal(t, FailedCallMessage, failed.msg)
+	assert.Equal(t, "indexHandler", failed.args["function"])
+	assert.Equal(t, "main", failed.args["module"])
+	assert.Equal(t, "http.serve", failed.args["caller"])
+	assert.Equal(t, time.Millisecond, failed.args["duration"])
+	assert.Equal(t, "API", failed.args["objective_name"])
+	assert.Equal(t, callErr, failed.args["error"])
+
+	slow := logger.records[1]
+	assert.Equal(t, "warn", slow.level)
+	assert.Equal(t, SlowCallMessage, slow.msg)
+	assert.Equal(t, "otherHandler", slow.args["function"])
+	assert.Equal(t, 100*time.Millisecond, slow.args["latency_threshold"])
+
+	SetLogger(logger, 50*time.Millisecond)
+	LogCall(&ctx, callErr, time.Millisecond)
+	LogCall(&ctx, callErr, time.Millisecond)
+	time.Sleep(60 * time.Millisecond)
+	LogCall(&ctx, callErr, time.Millisecond)
+
+	if assert.Len(t, logger.records, 4) {
+		assert.Equal(t, 1, logger.records[3].args["suppressed"], "The next record must count the suppressed records.")
+	}
+}
+
+func TestLogCallSeveralObjectives(t *testing.T) {
+	logger := &recordingLogger{}
+	SetLogger(logger, time.Nanosecond)
+	defer SetLogger(nil, 0)
+
+	ctx := NewContext()
+	ctx.CallInfo = CallInfo{FuncName: "indexHandler", ModuleName: "main"}
+	ctx.AlertConf = &AlertConfiguration{ServiceName: "API", Success: &SuccessSlo{Objective: 99}}
+	ctx.MoreAlertConfs = []AlertConfiguration{
+		{ServiceName: "Checkout", Latency: &LatencySlo{Target: time.Second, Objective: 99}},
+		{ServiceName: "Checkout", Latency: &LatencySlo{Target: 100 * time.Millisecond, Objective: 90}},
+	}
+
+	LogCall(&ctx, nil, 50*time.Millisecond)
+	assert.Empty(t, logger.records, "A call within all the latency targets must not be logged.")
+
+	LogCall(&ctx, nil, 500*time.Millisecond)
+	if assert.Len(t, logger.records, 1, "A call exceeding the latency target of another objective than the first must be logged.") {
+		assert.Equal(t, SlowCallMessage, logger.records[0].msg)
+		assert.Equal(t, 100*time.Millisecond, logger.records[0].args["latency_threshold"])
+		assert.Equal(t, "API,Checkout", logger.records[0].args["objective_name"])
+	}
+
+	time.Sleep(time.Millisecond)
+	LogCall(&ctx, nil, 2*time.Second)
+	if assert.Len(t, logger.records, 2) {
+		assert.Equal(t, 100*time.Millisecond, logger.records[1].args["latency_threshold"],
+			"The threshold must be the lowest latency target exceeded.")
+	}
+}
@@ -4,6 +4,9 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"net/http"
+	"runtime/trace"
+	"strings"
 	"time"
 )
 
@@ -25,6 +28,7 @@ type Implementation int
 const (
 	PROMETHEUS Implementation = iota
 	OTEL                      = iota
+	STATSD                    = iota
 )
 
 // Context holds the configuration
@@ -36,8 +40,23 @@ type Context struct {
 	TrackConcurrentCalls bool
 	// TrackCallerName adds a label with the caller name in all the collected metrics.
 	TrackCallerName bool
+	// TrackSpan starts an OpenTelemetry span for each call of the function, with the otel implementation.
+	TrackSpan bool
+	// TrackProfileLabels applies pprof labels to the goroutine for the duration of each call
+	// of the function, see [Context.ApplyProfileLabels].
+	TrackProfileLabels bool
+	// TrackRuntimeTrace opens a runtime/trace region or task for each call of the function,
+	// see [Context.StartRuntimeTrace].
+	TrackRuntimeTrace bool
 	// AlertConf is an optional configuration to add alerting capabilities to the metrics.
 	AlertConf *AlertConfiguration
+	// MoreAlertConfs are the other objectives of a function that has several of them, like
+	// a function in two SLOs. The calls are recorded once more for each of these objectives,
+	// in series marked as copies. See [Context.AlertConfs].
+	MoreAlertConfs []AlertConfiguration
+	// Labels are the user-defined labels of the call, recorded if they are declared
+	// in Init, see [LabelAllowlist].
+	Labels []Label
 	// startTime is the start time of a single function execution.
 	// Only autometrics.Instrument should read this value.
 	// Only autometrics.PreInstrument should write this value.
@@ -51,6 +70,26 @@ type Context struct {
 	// This value is only exported for the child packages "prometheus" and "otel"
 	CallInfo CallInfo
 	Context  context.Context
+	// ContextPointer is an optional pointer to the context.Context variable of the
+	// instrumented function, used to track the caller through the context instead
+	// of the stack frames. See [Context.PropagateCaller].
+	ContextPointer *context.Context
+	// RequestPointer is an optional pointer to the *http.Request variable of the
+	// instrumented function, used like ContextPointer with the context of the request.
+	RequestPointer **http.Request
+	// TrackContextCaller tracks the caller through the context given with ContextPointer
+	// or RequestPointer. Without it, the context is only used for the pprof labels, the
+	// runtime/trace tasks and the spans of the function, and the caller is read from the
+	// stack frames. See [Context.PropagateCaller].
+	TrackContextCaller bool
+	// profileParent is the context whose pprof labels are restored at the end of the call.
+	profileParent context.Context
+	// profileLabelsApplied is true if ApplyProfileLabels set the pprof labels of the goroutine.
+	profileLabelsApplied bool
+	// runtimeTraceRegion is the runtime/trace region opened by StartRuntimeTrace, if any.
+	runtimeTraceRegion *trace.Region
+	// runtimeTraceTask is the runtime/trace task created by StartRuntimeTrace, if any.
+	runtimeTraceTask *trace.Task
 }
 
 // CallInfo holds the information about the current function call and its parent names.
@@ -65,57 +104,132 @@ type CallInfo struct {
 	ParentModuleName string
 }
 
+// Complete returns the call information, with its empty names taken from other.
+//
+// PreInstrument uses it to keep the names that were set before the call, like the
+// route pattern of an HTTP middleware, and read the other ones from the stack frames.
+func (c CallInfo) Complete(other CallInfo) CallInfo {
+	if c.FuncName == "" {
+		c.FuncName = other.FuncName
+	}
+	if c.ModuleName == "" {
+		c.ModuleName = other.ModuleName
+	}
+	if c.ParentFuncName == "" {
+		c.ParentFuncName = other.ParentFuncName
+	}
+	if c.ParentModuleName == "" {
+		c.ParentModuleName = other.ParentModuleName
+	}
+
+	return c
+}
+
 func NewContext() Context {
 	return Context{
 		TrackConcurrentCalls: true,
 		TrackCallerName:      true,
+		TrackContextCaller:   true,
 		AlertConf:            nil,
 		Context:              context.Background(),
 	}
 }
 
 func (c Context) Validate(allowCustomLatencies bool) error {
-	if c.AlertConf != nil {
-		if c.AlertConf.ServiceName == "" {
-			return fmt.Errorf("Cannot have an AlertConfiguration without a service name")
+	alertConfs := c.AlertConfs()
+	for i, alertConf := range alertConfs {
+		if err := alertConf.validate(allowCustomLatencies); err != nil {
+			return err
 		}
 
-		if c.AlertConf.Success != nil && c.AlertConf.Success.Objective <= 0 {
-			return fmt.Errorf("Cannot have a target success rate that is negative")
+		// The same objective twice would be counted twice by the alerting rules.
+		for _, other := range alertConfs[:i] {
+			if other.ServiceName != alertConf.ServiceName {
+				continue
+			}
+			if other.Success != nil && alertConf.Success != nil && *other.Success == *alertConf.Success {
+				return fmt.Errorf("Cannot have the same target success rate twice in the %q SLO", alertConf.ServiceName)
+			}
+			if other.Latency != nil && alertConf.Latency != nil && *other.Latency == *alertConf.Latency {
+				return fmt.Errorf("Cannot have the same latency target twice in the %q SLO", alertConf.ServiceName)
+			}
 		}
+	}
 
-		if c.AlertConf.Success != nil && c.AlertConf.Success.Objective <= 1 {
-			log.Println("Warning: the target success rate is between 0 and 1, which is between 0 and 1%%. '1' is 1%% not 100%%!")
-		}
+	return nil
+}
 
-		if c.AlertConf.Success != nil && c.AlertConf.Success.Objective > 100 {
-			return fmt.Errorf("Cannot have a target success rate that is strictly greater than 100 (more than 100%%)")
-		}
+// AlertConfs returns all the objectives of the function: AlertConf, if any, followed by MoreAlertConfs.
+func (c *Context) AlertConfs() []AlertConfiguration {
+	var alertConfs []AlertConfiguration
+	if c.AlertConf != nil {
+		alertConfs = append(alertConfs, *c.AlertConf)
+	}
 
-		if c.AlertConf.Success != nil && !contains(DefObjectives, c.AlertConf.Success.Objective) {
-			return fmt.Errorf("Cannot have a target success rate that is not one of the predetermined ones by generated rules files (valid targets are %v)", DefObjectives)
-		}
+	return append(alertConfs, c.MoreAlertConfs...)
+}
 
-		if c.AlertConf.Latency != nil {
-			if c.AlertConf.Latency.Objective <= 0 {
-				return fmt.Errorf("Cannot have a target for latency SLO that is negative")
-			}
-			if c.AlertConf.Latency.Objective <= 1 {
-				log.Println("Warning: the latency target success rate is between 0 and 1, which is between 0 and 1%%. '1' is 1%% not 100%%!")
-			}
-			if c.AlertConf.Latency.Objective > 100 {
-				return fmt.Errorf("Cannot have a target for latency SLO that is greater than 100 (more than 100%%)")
-			}
-			if !contains(DefObjectives, c.AlertConf.Latency.Objective) {
-				return fmt.Errorf("Cannot have a target for latency SLO that is not one of the predetermined in the generated rules files (valid targets are %v)", DefObjectives)
-			}
-			if c.AlertConf.Latency.Target <= 0 {
-				return fmt.Errorf("Cannot have a target latency SLO threshold that is negative (responses expected before the query)")
-			}
-			if !allowCustomLatencies && !contains(DefBuckets, c.AlertConf.Latency.Target.Seconds()) {
-				return fmt.Errorf("Cannot have a target latency SLO threshold that does not match a bucket (valid threshold in seconds are %v). If you set custom latencies in your Init call, then you can add the %v flag to the //go:generate invocation to remove this error", DefBuckets, AllowCustomLatenciesFlag)
+// ObjectiveNames returns the names of the SLOs of the function, without duplicates and
+// separated by commas, or an empty string if the function has no objective.
+func (c *Context) ObjectiveNames() string {
+	var names []string
+	for _, alertConf := range c.AlertConfs() {
+		found := false
+		for _, name := range names {
+			if name == alertConf.ServiceName {
+				found = true
+				break
 			}
 		}
+		if !found {
+			names = append(names, alertConf.ServiceName)
+		}
+	}
+
+	return strings.Join(names, ",")
+}
+
+// validate returns an error if the objective cannot be used by the generated rules files.
+func (a AlertConfiguration) validate(allowCustomLatencies bool) error {
+	if a.ServiceName == "" {
+		return fmt.Errorf("Cannot have an AlertConfiguration without a service name")
+	}
+
+	if a.Success != nil && a.Success.Objective <= 0 {
+		return fmt.Errorf("Cannot have a target success rate that is negative")
+	}
+
+	if a.Success != nil && a.Success.Objective <= 1 {
+		log.Println("Warning: the target success rate is between 0 and 1, which is between 0 and 1%%. '1' is 1%% not 100%%!")
+	}
+
+	if a.Success != nil && a.Success.Objective > 100 {
+		return fmt.Errorf("Cannot have a target success rate that is strictly greater than 100 (more than 100%%)")
+	}
+
+	if a.Success != nil && !contains(DefObjectives, a.Success.Objective) {
+		return fmt.Errorf("Cannot have a target success rate that is not one of the predetermined ones by generated rules files (valid targets are %v)", DefObjectives)
+	}
+
+	if a.Latency != nil {
+		if a.Latency.Objective <= 0 {
+			return fmt.Errorf("Cannot have a target for latency SLO that is negative")
+		}
+		if a.Latency.Objective <= 1 {
+			log.Println("Warning: the latency target success rate is between 0 and 1, which is between 0 and 1%%. '1' is 1%% not 100%%!")
+		}
+		if a.Latency.Objective > 100 {
+			return fmt.Errorf("Cannot have a target for latency SLO that is greater than 100 (more than 100%%)")
+		}
+		if !contains(DefObjectives, a.Latency.Objective) {
+			return fmt.Errorf("Cannot have a target for latency SLO that is not one of the predetermined in the generated rules files (valid targets are %v)", DefObjectives)
+		}
+		if a.Latency.Target <= 0 {
+			return fmt.Errorf("Cannot have a target latency SLO threshold that is negative (responses expected before the query)")
+		}
+		if !allowCustomLatencies && !contains(DefBuckets, a.Latency.Target.Seconds()) {
+			return fmt.Errorf("Cannot have a target latency SLO threshold that does not match a bucket (valid threshold in seconds are %v). If you set custom latencies in your Init call, then you can add the %v flag to the //go:generate invocation to remove this error", DefBuckets, AllowCustomLatenciesFlag)
+		}
 	}
 
 	return nil
@@ -130,6 +244,10 @@ func contains[T comparable](s []T, e T) bool {
 	return false
 }
 
+// ObjectiveCopyValue is the value of the label marking the series recorded for the objectives
+// of a function after its first one, see [Context.MoreAlertConfs].
+const ObjectiveCopyValue = "true"
+
 // AlertConfiguration is the configuration for autometric alerting.
 type AlertConfiguration struct {
 	// ServiceName is the name of the Service that will appear in the alerts.
@@ -144,12 +262,12 @@ type AlertConfiguration struct {
 type LatencySlo struct {
 	// Target is the maximum allowed latency for the endpoint.
 	Target time.Duration
-	// Objective is the success rate allowed for the given latency, from 0 to 1.
+	// Objective is the percentage of calls that must last less than Target, from 0 to 100.
 	Objective float64
 }
 
 // SuccessSlo is an objective for the success rate of the function
 type SuccessSlo struct {
-	// Objective is the success rate allowed for the given function, from 0 to 1.
+	// Objective is the percentage of calls that must succeed, from 0 to 100.
 	Objective float64
 }
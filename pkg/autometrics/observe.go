@@ -0,0 +1,98 @@
+package autometrics
+
+import (
+	"log"
+	"sync"
+	"time"
+)
+
+// ObservedCall is a finished call of an instrumented function, as given to the call observers.
+type ObservedCall struct {
+	// CallInfo holds the names of the function and of its caller.
+	CallInfo CallInfo
+	// Err is the error returned by the function, nil for a successful call.
+	Err error
+	// Duration is the duration of the call.
+	Duration time.Duration
+	// AlertConf is the SLO configuration of the function, if any.
+	AlertConf *AlertConfiguration
+	// MoreAlertConfs are the other objectives of the function, see [Context.MoreAlertConfs].
+	MoreAlertConfs []AlertConfiguration
+	// TrackCallerName is true if the caller is recorded in the metrics.
+	TrackCallerName bool
+}
+
+type callObserver struct {
+	observe func(ObservedCall)
+}
+
+var (
+	callObserversMutex sync.RWMutex
+	callObservers      []*callObserver
+
+	// uninitializedWarnings holds the implementations that already warned about a missing Init.
+	uninitializedWarnings sync.Map
+)
+
+// AddCallObserver adds a function called with every finished call of the
+// instrumented functions, and returns the function that removes it.
+//
+// The implementations call the observers from their Instrument function, even
+// when their Init function has not been called: the observers are meant for tests,
+// see the autometricstest package.
+func AddCallObserver(observe func(ObservedCall)) (remove func()) {
+	observer := &callObserver{observe: observe}
+
+	callObserversMutex.Lock()
+	callObservers = append(callObservers, observer)
+	callObserversMutex.Unlock()
+
+	return func() {
+		callObserversMutex.Lock()
+		defer callObserversMutex.Unlock()
+
+		for i, o := range callObservers {
+			if o == observer {
+				callObservers = append(callObservers[:i:i], callObservers[i+1:]...)
+				return
+			}
+		}
+	}
+}
+
+// ObserveCall gives a finished call to the call observers.
+//
+// The implementations call it from their Instrument function.
+func ObserveCall(ctx *Context, err error, duration time.Duration) {
+	callObserversMutex.RLock()
+	observers := callObservers
+	callObserversMutex.RUnlock()
+
+	if len(observers) == 0 {
+		return
+	}
+
+	call := ObservedCall{
+		CallInfo:        ctx.CallInfo,
+		Err:             err,
+		Duration:        duration,
+		AlertConf:       ctx.AlertConf,
+		MoreAlertConfs:  ctx.MoreAlertConfs,
+		TrackCallerName: ctx.TrackCallerName,
+	}
+
+	for _, o := range observers {
+		o.observe(call)
+	}
+}
+
+// WarnUninitialized logs a warning the first time an implementation records a call
+// before its Init function is called.
+//
+// The implementations call it from their Instrument function instead of recording the
+// metrics, as the call only goes to the call observers until Init is called.
+func WarnUninitialized(implementation string) {
+	if _, warned := uninitializedWarnings.LoadOrStore(implementation, true); !warned {
+		log.Printf("Warning: the autometrics %v implementation records calls before its Init function is called, these calls are not in the metrics.", implementation)
+	}
+}
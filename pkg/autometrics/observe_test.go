@@ -0,0 +1,23 @@
+package autometrics
+
+import (
+	"bytes"
+	"log"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWarnUninitialized(t *testing.T) {
+	var output bytes.Buffer
+	defer log.SetOutput(log.Writer())
+	log.SetOutput(&output)
+
+	WarnUninitialized("test")
+	WarnUninitialized("test")
+	WarnUninitialized("other")
+
+	assert.Equal(t, 1, strings.Count(output.String(), "autometrics test implementation"), "The warning must be logged once per implementation.")
+	assert.Equal(t, 1, strings.Count(output.String(), "autometrics other implementation"), "The warning must be logged once per implementation.")
+}
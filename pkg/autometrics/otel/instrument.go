@@ -15,58 +15,96 @@ import (
 //
 // The first argument SHOULD be a call to PreInstrument so that
 // the "concurrent calls" gauge is correctly setup.
-func  Instrument(ctx *autometrics.Context, err *error) {
+func Instrument(ctx *autometrics.Context, err *error) {
 	result := "ok"
+	var callErr error
 
 	if err != nil && *err != nil {
 		result = "error"
+		callErr = *err
 	}
 
-	var callerLabel, sloName, latencyTarget, latencyObjective, successObjective string
+	duration := time.Since(ctx.StartTime)
+	ctx.EndRuntimeTrace()
+	ctx.RestoreProfileLabels()
+	autometrics.ObserveCall(ctx, callErr, duration)
+	autometrics.LogCall(ctx, callErr, duration)
 
-	if ctx.TrackCallerName {
-		callerLabel = fmt.Sprintf("%s.%s", ctx.CallInfo.ParentModuleName, ctx.CallInfo.ParentFuncName)
+	if ctx.TrackSpan {
+		endSpan(ctx, callErr)
 	}
 
-	if ctx.AlertConf != nil {
-		sloName = ctx.AlertConf.ServiceName
-
-		if ctx.AlertConf.Latency != nil {
-			latencyTarget = strconv.FormatFloat(ctx.AlertConf.Latency.Target.Seconds(), 'f', -1, 64)
-			latencyObjective = strconv.FormatFloat(ctx.AlertConf.Latency.Objective, 'f', -1, 64)
-		}
+	// Without Init, the calls only go to the call observers.
+	if functionCallsCount == nil {
+		autometrics.WarnUninitialized("otel")
+		return
+	}
 
-		if ctx.AlertConf.Success != nil {
-			successObjective = strconv.FormatFloat(ctx.AlertConf.Success.Objective, 'f', -1, 64)
-		}
+	callerFunction, callerModule := callerNames(ctx)
+	if callerModule == autometrics.OverflowLabelValue {
+		cardinalityOverflows.Add(ctx.Context, 1,
+			attribute.Key(FunctionLabel).String(ctx.CallInfo.FuncName),
+			attribute.Key(ModuleLabel).String(ctx.CallInfo.ModuleName),
+			attribute.Key(LabelNameLabel).String(callerLabelName()),
+			attribute.Key(ServiceNameLabel).String(serviceName),
+		)
 	}
 
-	functionCallsCount.Add(ctx.Context, 1,
-		[]attribute.KeyValue{
+	custom, overflowed := customLabels.Values(ctx)
+	for _, name := range overflowed {
+		cardinalityOverflows.Add(ctx.Context, 1,
 			attribute.Key(FunctionLabel).String(ctx.CallInfo.FuncName),
 			attribute.Key(ModuleLabel).String(ctx.CallInfo.ModuleName),
-			attribute.Key(CallerLabel).String(callerLabel),
+			attribute.Key(LabelNameLabel).String(name),
+			attribute.Key(ServiceNameLabel).String(serviceName),
+		)
+	}
+
+	sloName, latencyTarget, latencyObjective, successObjective := sloLabels(ctx.AlertConf)
+
+	functionCallsCount.Add(ctx.Context, 1,
+		append(functionAttributes(ctx.CallInfo.FuncName, ctx.CallInfo.ModuleName, callerFunction, callerModule, custom),
 			attribute.Key(ResultLabel).String(result),
 			attribute.Key(TargetSuccessRateLabel).String(successObjective),
 			attribute.Key(SloNameLabel).String(sloName),
-		}...)
-	functionCallsDuration.Record(ctx.Context, time.Since(ctx.StartTime).Seconds(),
-		[]attribute.KeyValue{
-			attribute.Key(FunctionLabel).String(ctx.CallInfo.FuncName),
-			attribute.Key(ModuleLabel).String(ctx.CallInfo.ModuleName),
-			attribute.Key(CallerLabel).String(callerLabel),
+		)...)
+	functionCallsDuration.Record(ctx.Context, duration.Seconds(),
+		append(functionAttributes(ctx.CallInfo.FuncName, ctx.CallInfo.ModuleName, callerFunction, callerModule, custom),
 			attribute.Key(TargetLatencyLabel).String(latencyTarget),
 			attribute.Key(TargetSuccessRateLabel).String(latencyObjective),
 			attribute.Key(SloNameLabel).String(sloName),
-		}...)
+		)...)
+
+	// The calls are recorded once more for each other objective of the function, in data points
+	// marked as copies so that the queries counting the calls can leave them out.
+	for i := range ctx.MoreAlertConfs {
+		alertConf := &ctx.MoreAlertConfs[i]
+		sloName, latencyTarget, latencyObjective, successObjective := sloLabels(alertConf)
+
+		if alertConf.Success != nil {
+			functionCallsCount.Add(ctx.Context, 1,
+				append(functionAttributes(ctx.CallInfo.FuncName, ctx.CallInfo.ModuleName, callerFunction, callerModule, custom),
+					attribute.Key(ResultLabel).String(result),
+					attribute.Key(TargetSuccessRateLabel).String(successObjective),
+					attribute.Key(SloNameLabel).String(sloName),
+					attribute.Key(ObjectiveCopyLabel).String(autometrics.ObjectiveCopyValue),
+				)...)
+		}
+
+		if alertConf.Latency != nil {
+			functionCallsDuration.Record(ctx.Context, duration.Seconds(),
+				append(functionAttributes(ctx.CallInfo.FuncName, ctx.CallInfo.ModuleName, callerFunction, callerModule, custom),
+					attribute.Key(TargetLatencyLabel).String(latencyTarget),
+					attribute.Key(TargetSuccessRateLabel).String(latencyObjective),
+					attribute.Key(SloNameLabel).String(sloName),
+					attribute.Key(ObjectiveCopyLabel).String(autometrics.ObjectiveCopyValue),
+				)...)
+		}
+	}
 
 	if ctx.TrackConcurrentCalls {
 		functionCallsConcurrent.Add(ctx.Context, -1,
-			[]attribute.KeyValue{
-				attribute.Key(FunctionLabel).String(ctx.CallInfo.FuncName),
-				attribute.Key(ModuleLabel).String(ctx.CallInfo.ModuleName),
-				attribute.Key(CallerLabel).String(callerLabel),
-			}...)
+			functionAttributes(ctx.CallInfo.FuncName, ctx.CallInfo.ModuleName, callerFunction, callerModule, custom)...)
 	}
 }
 
@@ -74,25 +112,117 @@ func  Instrument(ctx *autometrics.Context, err *error) {
 //
 // It is meant to be called as the first argument to Instrument in a
 // defer call.
-func  PreInstrument(ctx *autometrics.Context) *autometrics.Context {
-	ctx.CallInfo = autometrics.CallerInfo()
-	ctx.Context = context.Background()
+func PreInstrument(ctx *autometrics.Context) *autometrics.Context {
+	return preInstrument(ctx, autometrics.CallerInfo())
+}
 
-	var callerLabel string
-	if ctx.TrackCallerName {
-		callerLabel = fmt.Sprintf("%s.%s", ctx.CallInfo.ParentModuleName, ctx.CallInfo.ParentFuncName)
+// preInstrument runs PreInstrument with the call information read from the stack
+// frames, that the callers read themselves to skip the right number of frames.
+func preInstrument(ctx *autometrics.Context, callInfo autometrics.CallInfo) *autometrics.Context {
+	ctx.CallInfo = ctx.CallInfo.Complete(callInfo)
+	if ctx.Context == nil {
+		ctx.Context = context.Background()
 	}
+	ctx.PropagateCaller()
 
-	if ctx.TrackConcurrentCalls {
+	if ctx.TrackSpan {
+		startSpan(ctx)
+	}
+	ctx.ApplyProfileLabels()
+	ctx.StartRuntimeTrace()
+
+	if ctx.TrackConcurrentCalls && functionCallsConcurrent != nil {
+		callerFunction, callerModule := callerNames(ctx)
+		custom, _ := customLabels.Values(ctx)
 		functionCallsConcurrent.Add(ctx.Context, 1,
-			[]attribute.KeyValue{
-				attribute.Key(FunctionLabel).String(ctx.CallInfo.FuncName),
-				attribute.Key(ModuleLabel).String(ctx.CallInfo.ModuleName),
-				attribute.Key(CallerLabel).String(callerLabel),
-			}...)
+			functionAttributes(ctx.CallInfo.FuncName, ctx.CallInfo.ModuleName, callerFunction, callerModule, custom)...)
 	}
 
 	ctx.StartTime = time.Now()
 
 	return ctx
 }
+
+// sloLabels returns the values of the (SloNameLabel, TargetLatencyLabel, latency TargetSuccessRateLabel,
+// success TargetSuccessRateLabel) attributes for an objective of the function, which can be nil.
+func sloLabels(alertConf *autometrics.AlertConfiguration) (sloName, latencyTarget, latencyObjective, successObjective string) {
+	if alertConf != nil {
+		sloName = alertConf.ServiceName
+
+		if alertConf.Latency != nil {
+			latencyTarget = strconv.FormatFloat(alertConf.Latency.Target.Seconds(), 'f', -1, 64)
+			latencyObjective = strconv.FormatFloat(alertConf.Latency.Objective, 'f', -1, 64)
+		}
+
+		if alertConf.Success != nil {
+			successObjective = strconv.FormatFloat(alertConf.Success.Objective, 'f', -1, 64)
+		}
+	}
+
+	return
+}
+
+// callerNames returns the function and module names of the caller, or empty strings
+// if the context does not track the caller name.
+//
+// Both names are [autometrics.OverflowLabelValue] if the function exceeded the cardinality
+// limit for its callers.
+func callerNames(ctx *autometrics.Context) (callerFunction, callerModule string) {
+	if !ctx.TrackCallerName {
+		return "", ""
+	}
+
+	caller := fmt.Sprintf("%s.%s", ctx.CallInfo.ParentModuleName, ctx.CallInfo.ParentFuncName)
+	if !callerLimiter.Allow(ctx.CallInfo.FuncName, ctx.CallInfo.ModuleName, caller) {
+		return autometrics.OverflowLabelValue, autometrics.OverflowLabelValue
+	}
+
+	return ctx.CallInfo.ParentFuncName, ctx.CallInfo.ParentModuleName
+}
+
+// callerLabelName returns the name of the attribute holding the caller, following
+// the naming scheme given to Init.
+func callerLabelName() string {
+	if namingScheme == autometrics.SpecNaming {
+		return CallerFunctionLabel
+	}
+
+	return CallerLabel
+}
+
+// functionAttributes returns the attributes that identify a function, its caller and the service,
+// following the naming scheme given to Init, along with the user-defined labels declared in Init.
+//
+// An empty callerFunction and callerModule means the caller is unknown. custom holds the values
+// of the user-defined labels, see [autometrics.LabelAllowlist.Values]; the missing values are empty.
+func functionAttributes(funcName, moduleName, callerFunction, callerModule string, custom []string) []attribute.KeyValue {
+	attributes := []attribute.KeyValue{
+		attribute.Key(FunctionLabel).String(funcName),
+		attribute.Key(ModuleLabel).String(moduleName),
+		attribute.Key(ServiceNameLabel).String(serviceName),
+	}
+
+	for i, name := range customLabels.Names() {
+		value := ""
+		if i < len(custom) {
+			value = custom[i]
+		}
+		attributes = append(attributes, attribute.Key(name).String(value))
+	}
+
+	if namingScheme == autometrics.SpecNaming {
+		return append(attributes,
+			attribute.Key(CallerFunctionLabel).String(callerFunction),
+			attribute.Key(CallerModuleLabel).String(callerModule),
+		)
+	}
+
+	var callerLabel string
+	if callerModule == autometrics.OverflowLabelValue {
+		callerLabel = autometrics.OverflowLabelValue
+	} else if callerFunction != "" || callerModule != "" {
+		callerLabel = fmt.Sprintf("%s.%s", callerModule, callerFunction)
+	}
+
+	return append(attributes, attribute.Key(CallerLabel).String(callerLabel))
+}
@@ -0,0 +1,22 @@
+package otel
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReservedLabelNames(t *testing.T) {
+	for _, name := range []string{
+		FunctionLabel, ResultLabel, ServiceNameLabel,
+		"objective_name", "objective_percentile", "objective_latency_threshold", "objective_copy",
+		"caller_function", "caller_module", "le", "otel_scope_name",
+	} {
+		err := Init("test", DefBuckets, WithRegisterer(prometheus.NewRegistry()), WithLabelAllowlist(name, "value"))
+		assert.ErrorContains(t, err, name, "The exported name of an attribute of the metrics must be rejected.")
+	}
+
+	err := Init("test", DefBuckets, WithRegisterer(prometheus.NewRegistry()), WithLabelAllowlist("tier", "free", "pro"))
+	assert.NoError(t, err, "A user-defined attribute must be accepted.")
+}
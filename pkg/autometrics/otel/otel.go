@@ -1,10 +1,14 @@
 package otel // import "github.com/autometrics-dev/autometrics-go/pkg/autometrics/otel"
 
 import (
+	"context"
 	"fmt"
+	"strings"
 
 	"github.com/autometrics-dev/autometrics-go/pkg/autometrics"
+	prom "github.com/prometheus/client_golang/prometheus"
 
+	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/exporters/prometheus"
 	"go.opentelemetry.io/otel/metric/instrument"
 	"go.opentelemetry.io/otel/sdk/instrumentation"
@@ -13,9 +17,15 @@ import (
 )
 
 var (
-	functionCallsCount      instrument.Int64UpDownCounter
+	functionCallsCount      int64Adder
 	functionCallsDuration   instrument.Float64Histogram
 	functionCallsConcurrent instrument.Int64UpDownCounter
+	cardinalityOverflows    instrument.Int64Counter
+	asyncTasksInFlight      instrument.Int64UpDownCounter
+	callerLimiter           *autometrics.CardinalityLimiter
+	customLabels            *autometrics.LabelAllowlist
+	serviceName             string
+	namingScheme            autometrics.NamingScheme
 	DefBuckets              = autometrics.DefBuckets
 )
 
@@ -26,6 +36,23 @@ const (
 	FunctionCallsDurationName = "function.calls.duration"
 	// FunctionCallsConcurrentName is the name of the openTelemetry metric for the number of simulateneously active calls to specific functions.
 	FunctionCallsConcurrentName = "function.calls.concurrent"
+	// BuildInfoName is the name of the openTelemetry metric for the version, commit and branch of the running program.
+	BuildInfoName = "autometrics.build_info"
+	// FunctionCallsName is the name of the openTelemetry metric for the counter of calls to specific functions,
+	// when using [autometrics.SpecNaming]. The exporter adds the '_total' suffix.
+	FunctionCallsName = "function.calls"
+	// FunctionCallsDurationSecondsName is the name of the openTelemetry metric for the duration histogram of calls
+	// to specific functions, when using [autometrics.SpecNaming].
+	FunctionCallsDurationSecondsName = "function.calls.duration.seconds"
+	// BuildInfoSpecName is the name of the openTelemetry metric for the version, commit and branch of the running program,
+	// when using [autometrics.SpecNaming].
+	BuildInfoSpecName = "build_info"
+	// CardinalityOverflowName is the name of the openTelemetry metric for the counter of calls recorded with
+	// the [autometrics.OverflowLabelValue] value because of the cardinality limit. The exporter adds the '_total' suffix.
+	CardinalityOverflowName = "autometrics.cardinality.overflow"
+	// AsyncTasksInFlightName is the name of the openTelemetry metric for the number of running async tasks
+	// started by specific functions with Go or Group.
+	AsyncTasksInFlightName = "function.async_tasks.in_flight"
 
 	// FunctionLabel is the openTelemetry attribute that describes the function name.
 	//
@@ -40,6 +67,12 @@ const (
 	// CallerLabel is the openTelemetry attribute that describes the name of the function that called
 	// the current function.
 	CallerLabel = "caller"
+	// CallerFunctionLabel is the openTelemetry attribute that describes the name of the function that called
+	// the current function, when using [autometrics.SpecNaming].
+	CallerFunctionLabel = "caller.function"
+	// CallerModuleLabel is the openTelemetry attribute that describes the module name of the function that called
+	// the current function, when using [autometrics.SpecNaming].
+	CallerModuleLabel = "caller.module"
 	// ResultLabel is the openTelemetry attribute that describes whether a function call is successful.
 	ResultLabel = "result"
 	// TargetLatencyLabel is the openTelemetry attribute that describes the latency to respect to match
@@ -56,25 +89,136 @@ const (
 	TargetSuccessRateLabel = "objective.percentile"
 	// SloLabelName is the openTelemetry attribute that describes the name of the Service Level Objective.
 	SloNameLabel = "objective.name"
+	// ObjectiveCopyLabel is the openTelemetry attribute that marks the data points recorded for the
+	// objectives of a function after its first one, see [autometrics.Context.MoreAlertConfs]. The
+	// queries that count the calls of the functions must leave out the data points that have it.
+	ObjectiveCopyLabel = "objective.copy"
+	// VersionLabel is the openTelemetry attribute that describes the version of the running program.
+	VersionLabel = "version"
+	// CommitLabel is the openTelemetry attribute that describes the commit of the running program.
+	CommitLabel = "commit"
+	// BranchLabel is the openTelemetry attribute that describes the VCS branch of the running program.
+	BranchLabel = "branch"
+	// ServiceNameLabel is the openTelemetry attribute that describes the name of the service
+	// the metrics come from.
+	ServiceNameLabel = "service_name"
+	// LabelNameLabel is the openTelemetry attribute that describes the name of the attribute whose values
+	// exceeded the cardinality limit.
+	LabelNameLabel = "label"
 )
 
+// int64Adder is the common interface of the monotonic and UpDown counters.
+type int64Adder interface {
+	Add(ctx context.Context, incr int64, attrs ...attribute.KeyValue)
+}
 
 func completeMeterName(meterName string) string {
 	return fmt.Sprintf("autometrics/%v", meterName)
 }
 
+// reservedLabelNames returns the names that the user-defined attributes cannot have: the
+// exported names of the attributes of the metrics, where the Prometheus exporter replaces
+// the dots with underscores, and the labels added by the exporter.
+func reservedLabelNames() []string {
+	attributes := []string{
+		FunctionLabel, ModuleLabel, CallerLabel, CallerFunctionLabel, CallerModuleLabel, ResultLabel,
+		TargetLatencyLabel, TargetSuccessRateLabel, SloNameLabel, ObjectiveCopyLabel, ServiceNameLabel,
+	}
+
+	reserved := []string{"le", "otel_scope_name", "otel_scope_version"}
+	for _, name := range attributes {
+		reserved = append(reserved, strings.ReplaceAll(name, ".", "_"))
+	}
+
+	return reserved
+}
+
+// registererOption is the option returned by WithRegisterer, read by Init
+// as the registerer is not part of [autometrics.InitSettings].
+type registererOption struct {
+	registerer prom.Registerer
+}
+
+func (registererOption) ApplyInit(*autometrics.InitSettings) {}
+
+// WithRegisterer sets the Prometheus registerer of the exporter of the metrics,
+// instead of the default registerer of the Prometheus client.
+func WithRegisterer(registerer prom.Registerer) autometrics.InitOption {
+	return registererOption{registerer: registerer}
+}
+
 // Init sets up the metrics required for autometrics' decorated functions and registers
 // them to the Prometheus exporter
 //
 // Make sure that all the latency targets you want to use for SLOs are
 // present in the histogramBuckets array, otherwise the alerts will fail
 // to work (they will never trigger.)
-func Init(meterName string, histogramBuckets []float64) error {
+//
+// The version, commit and branch of the running program are published in the
+// BuildInfoName gauge, see [autometrics.NewInitSettings] for the way they are
+// detected and the options to override them.
+//
+// All the metrics have a ServiceNameLabel, set with the AUTOMETRICS_SERVICE_NAME
+// environment variable or the WithService option.
+//
+// The WithCardinalityLimit option bounds the number of distinct callers recorded for
+// each function, the calls recorded with the overflow value are counted in the
+// CardinalityOverflowName counter.
+//
+// The async tasks started with Go or Group are counted in the AsyncTasksInFlightName
+// gauge of the function that started them.
+//
+// The WithNamingScheme option switches the names of the metrics and of the caller
+// attributes to the ones of the Autometrics specification.
+//
+// The WithLabelAllowlist option adds user-defined attributes to the function call metrics,
+// the values missing from the allowlist are counted in the CardinalityOverflowName counter.
+//
+// The WithTracerProvider option sets the provider of the spans of the functions
+// using WithTracing, instead of the global provider of OpenTelemetry.
+//
+// The WithRegisterer option sets the Prometheus registerer of the exporter, instead
+// of the default one.
+func Init(meterName string, histogramBuckets []float64, opts ...autometrics.InitOption) error {
+	settings := autometrics.NewInitSettings(opts...)
+
+	allowlist, err := autometrics.NewLabelAllowlist(settings.AllowedLabels, reservedLabelNames()...)
+	if err != nil {
+		return fmt.Errorf("error initializing the user-defined labels: %w", err)
+	}
+
+	customLabels = allowlist
+	serviceName = settings.ServiceName
+	namingScheme = settings.NamingScheme
+	callerLimiter = autometrics.NewCardinalityLimiter(settings.CardinalityLimit)
+	autometrics.SetClosureSuffix(settings.ClosureSuffix)
+	autometrics.SetProfileLabels(settings.ProfileLabels)
+	autometrics.SetRuntimeTrace(settings.RuntimeTrace)
+	autometrics.SetLogger(settings.Logger, settings.LogInterval)
+
+	tracerProvider = nil
+	registerer := prom.DefaultRegisterer
+	for _, o := range opts {
+		switch o := o.(type) {
+		case tracerProviderOption:
+			tracerProvider = o.provider
+		case registererOption:
+			registerer = o.registerer
+		}
+	}
+
+	durationName, buildInfoName := FunctionCallsDurationName, BuildInfoName
+	if namingScheme == autometrics.SpecNaming {
+		// The exporter does not add a unit suffix for seconds, so it is part of the name.
+		durationName, buildInfoName = FunctionCallsDurationSecondsName, BuildInfoSpecName
+	}
+
 	exporter, err := prometheus.New(
 		// The units are removed from the exporter so that the names of the
 		// exported metrics after the View rename are consistent with the
 		// autometrics.rules.yml file
 		prometheus.WithoutUnits(),
+		prometheus.WithRegisterer(registerer),
 	)
 	if err != nil {
 		return fmt.Errorf("error initializing prometheus exporter: %w", err)
@@ -83,7 +227,7 @@ func Init(meterName string, histogramBuckets []float64) error {
 		metric.WithReader(exporter),
 		metric.WithView(metric.NewView(
 			metric.Instrument{
-				Name:  FunctionCallsDurationName,
+				Name:  durationName,
 				Scope: instrumentation.Scope{Name: completeMeterName(meterName)},
 			},
 			metric.Stream{
@@ -95,18 +239,27 @@ func Init(meterName string, histogramBuckets []float64) error {
 	)
 	meter := provider.Meter(completeMeterName(meterName))
 
-	// We are using an UpDown counter instead of the natural Counter because with a monotonic counter
-	// there is no way to remove the '_total' suffix from the exported metric name. This suffix
-	// makes the exported metrics incompatible with the autometrics.rules.yml file.
-	// Ref: https://github.com/open-telemetry/opentelemetry-go/blob/6b7e207953ce0a13d38da628a6aa48ad56058d2a/exporters/prometheus/exporter.go#L212-L215
-	functionCallsCount, err = meter.Int64UpDownCounter(FunctionCallsCountName, instrument.WithDescription("The number of times the function has been called"))
-	if err != nil {
-		return fmt.Errorf("error initializing %v metric: %w", FunctionCallsCountName, err)
+	if namingScheme == autometrics.SpecNaming {
+		// The specification names the counter with the '_total' suffix added
+		// by the exporter to monotonic counters.
+		functionCallsCount, err = meter.Int64Counter(FunctionCallsName, instrument.WithDescription("The number of times the function has been called"))
+		if err != nil {
+			return fmt.Errorf("error initializing %v metric: %w", FunctionCallsName, err)
+		}
+	} else {
+		// We are using an UpDown counter instead of the natural Counter because with a monotonic counter
+		// there is no way to remove the '_total' suffix from the exported metric name. This suffix
+		// makes the exported metrics incompatible with the autometrics.rules.yml file.
+		// Ref: https://github.com/open-telemetry/opentelemetry-go/blob/6b7e207953ce0a13d38da628a6aa48ad56058d2a/exporters/prometheus/exporter.go#L212-L215
+		functionCallsCount, err = meter.Int64UpDownCounter(FunctionCallsCountName, instrument.WithDescription("The number of times the function has been called"))
+		if err != nil {
+			return fmt.Errorf("error initializing %v metric: %w", FunctionCallsCountName, err)
+		}
 	}
 
-	functionCallsDuration, err = meter.Float64Histogram(FunctionCallsDurationName, instrument.WithDescription("The duration of each function call, in seconds"))
+	functionCallsDuration, err = meter.Float64Histogram(durationName, instrument.WithDescription("The duration of each function call, in seconds"))
 	if err != nil {
-		return fmt.Errorf("error initializing %v metric: %w", FunctionCallsDurationName, err)
+		return fmt.Errorf("error initializing %v metric: %w", durationName, err)
 	}
 
 	functionCallsConcurrent, err = meter.Int64UpDownCounter(FunctionCallsConcurrentName, instrument.WithDescription("The number of simultaneous calls of the function"))
@@ -114,5 +267,97 @@ func Init(meterName string, histogramBuckets []float64) error {
 		return fmt.Errorf("error initializing %v metric: %w", FunctionCallsConcurrentName, err)
 	}
 
+	_, err = meter.Int64ObservableGauge(buildInfoName,
+		instrument.WithDescription("The version, commit and branch of the running program"),
+		instrument.WithInt64Callback(func(_ context.Context, observer instrument.Int64Observer) error {
+			observer.Observe(1,
+				attribute.Key(VersionLabel).String(settings.BuildInfo.Version),
+				attribute.Key(CommitLabel).String(settings.BuildInfo.Commit),
+				attribute.Key(BranchLabel).String(settings.BuildInfo.Branch),
+				attribute.Key(ServiceNameLabel).String(serviceName),
+			)
+			return nil
+		}))
+	if err != nil {
+		return fmt.Errorf("error initializing %v metric: %w", buildInfoName, err)
+	}
+
+	cardinalityOverflows, err = meter.Int64Counter(CardinalityOverflowName, instrument.WithDescription("The number of calls recorded with the overflow value because of the cardinality limit"))
+	if err != nil {
+		return fmt.Errorf("error initializing %v metric: %w", CardinalityOverflowName, err)
+	}
+
+	asyncTasksInFlight, err = meter.Int64UpDownCounter(AsyncTasksInFlightName, instrument.WithDescription("The number of running async tasks started by the function"))
+	if err != nil {
+		return fmt.Errorf("error initializing %v metric: %w", AsyncTasksInFlightName, err)
+	}
+
+	for _, function := range autometrics.RegisteredFunctions() {
+		initializeFunctionMetrics(function)
+	}
+
 	return nil
 }
+
+// RegisterFunction announces an instrumented function, so that its metrics
+// exist with a zero value before the function gets called for the first time.
+//
+// The generator adds calls to RegisterFunction in an init function for all the
+// instrumented functions of a file. The zero-valued series are created in Init,
+// or immediately if Init has already been called.
+func RegisterFunction(funcName, moduleName string, ctx *autometrics.Context) {
+	function := autometrics.RegisteredFunction{
+		FuncName:   funcName,
+		ModuleName: moduleName,
+		Context:    *ctx,
+	}
+
+	autometrics.RegisterFunction(function)
+
+	if functionCallsCount != nil {
+		initializeFunctionMetrics(function)
+	}
+}
+
+// initializeFunctionMetrics creates the zero-valued series of a registered
+// function, for all the possible results.
+//
+// The caller of the function is not known before it is called, so the caller attribute is empty.
+//
+// OpenTelemetry histograms cannot have a data point without recording a
+// value, so only the counters are initialized.
+func initializeFunctionMetrics(function autometrics.RegisteredFunction) {
+	sloName, _, _, successObjective := sloLabels(function.Context.AlertConf)
+
+	for _, result := range []string{"ok", "error"} {
+		functionCallsCount.Add(context.Background(), 0,
+			append(functionAttributes(function.FuncName, function.ModuleName, "", "", nil),
+				attribute.Key(ResultLabel).String(result),
+				attribute.Key(TargetSuccessRateLabel).String(successObjective),
+				attribute.Key(SloNameLabel).String(sloName),
+			)...)
+	}
+
+	for i := range function.Context.MoreAlertConfs {
+		alertConf := &function.Context.MoreAlertConfs[i]
+		if alertConf.Success == nil {
+			continue
+		}
+
+		sloName, _, _, successObjective := sloLabels(alertConf)
+		for _, result := range []string{"ok", "error"} {
+			functionCallsCount.Add(context.Background(), 0,
+				append(functionAttributes(function.FuncName, function.ModuleName, "", "", nil),
+					attribute.Key(ResultLabel).String(result),
+					attribute.Key(TargetSuccessRateLabel).String(successObjective),
+					attribute.Key(SloNameLabel).String(sloName),
+					attribute.Key(ObjectiveCopyLabel).String(autometrics.ObjectiveCopyValue),
+				)...)
+		}
+	}
+
+	if function.Context.TrackConcurrentCalls {
+		functionCallsConcurrent.Add(context.Background(), 0,
+			functionAttributes(function.FuncName, function.ModuleName, "", "", nil)...)
+	}
+}
@@ -0,0 +1,183 @@
+package otel
+
+import (
+	"testing"
+	"time"
+
+	"github.com/autometrics-dev/autometrics-go/pkg/autometrics"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+)
+
+// gatheredSeries is a series exported to a registry, with the value of its counter or
+// gauge, or the number of observations of its histogram.
+type gatheredSeries struct {
+	name   string
+	labels map[string]string
+	value  float64
+}
+
+// gather returns the series exported to the registry.
+func gather(t *testing.T, reg *prometheus.Registry) []gatheredSeries {
+	families, err := reg.Gather()
+	if err != nil {
+		t.Fatalf("Gather failed: %v", err)
+	}
+
+	var series []gatheredSeries
+	for _, family := range families {
+		for _, metric := range family.GetMetric() {
+			labels := make(map[string]string)
+			for _, label := range metric.GetLabel() {
+				labels[label.GetName()] = label.GetValue()
+			}
+
+			value := metric.GetCounter().GetValue() + metric.GetGauge().GetValue() + float64(metric.GetHistogram().GetSampleCount())
+			series = append(series, gatheredSeries{name: family.GetName(), labels: labels, value: value})
+		}
+	}
+
+	return series
+}
+
+// functionSeries returns the series of the exported metric for the function.
+func functionSeries(series []gatheredSeries, name, funcName string) []gatheredSeries {
+	var found []gatheredSeries
+	for _, s := range series {
+		if s.name == name && s.labels[FunctionLabel] == funcName {
+			found = append(found, s)
+		}
+	}
+
+	return found
+}
+
+func TestRegisterFunction(t *testing.T) {
+	RegisterFunction("registeredBeforeInit", "main", NewContext(WithSloName("API"), WithAlertSuccess(99)))
+
+	reg := prometheus.NewRegistry()
+	if err := Init("test", DefBuckets, WithRegisterer(reg)); err != nil {
+		t.Fatalf("Init failed: %v", err)
+	}
+
+	RegisterFunction("registeredAfterInit", "main", NewContext(WithConcurrentCalls(false)))
+
+	series := gather(t, reg)
+
+	counts := functionSeries(series, "function_calls_count", "registeredBeforeInit")
+	if assert.Len(t, counts, 2, "A registered function must have a series for each result.") {
+		for _, count := range counts {
+			assert.Equal(t, 0.0, count.value, "The series must be zero-valued.")
+			assert.Equal(t, "API", count.labels["objective_name"], "The series must have the objective of the function.")
+			assert.Equal(t, "99", count.labels["objective_percentile"])
+			assert.Equal(t, "", count.labels[CallerLabel], "The caller is not known before the first call.")
+		}
+	}
+	assert.Len(t, functionSeries(series, "function_calls_concurrent", "registeredBeforeInit"), 1)
+
+	assert.Len(t, functionSeries(series, "function_calls_count", "registeredAfterInit"), 2,
+		"A function registered after Init must have its series immediately.")
+	assert.Empty(t, functionSeries(series, "function_calls_concurrent", "registeredAfterInit"),
+		"A function that does not track the concurrent calls must not have a concurrency series.")
+}
+
+func TestBuildInfo(t *testing.T) {
+	t.Setenv(autometrics.VersionEnvironmentVariable, "v1.0.0")
+	t.Setenv(autometrics.BranchEnvironmentVariable, "main")
+
+	reg := prometheus.NewRegistry()
+	if err := Init("test", DefBuckets, WithRegisterer(reg), WithVersion("v1.2.3"), WithCommit("abc123")); err != nil {
+		t.Fatalf("Init failed: %v", err)
+	}
+
+	var buildInfos []gatheredSeries
+	for _, s := range gather(t, reg) {
+		if s.name == "autometrics_build_info" {
+			buildInfos = append(buildInfos, s)
+		}
+	}
+
+	if assert.Len(t, buildInfos, 1, "The build information must be a single series.") {
+		assert.Equal(t, 1.0, buildInfos[0].value)
+		assert.Equal(t, "v1.2.3", buildInfos[0].labels[VersionLabel], "The options must override the environment variables.")
+		assert.Equal(t, "abc123", buildInfos[0].labels[CommitLabel])
+		assert.Equal(t, "main", buildInfos[0].labels[BranchLabel], "The environment variables must be read.")
+	}
+}
+
+func servedHandler() (err error) {
+	defer Instrument(PreInstrument(NewContext(WithSloName("API"), WithAlertLatency(100*time.Millisecond, 99))), &err)
+
+	return nil
+}
+
+func TestServiceName(t *testing.T) {
+	t.Setenv(autometrics.ServiceNameEnvironmentVariable, "checkout")
+
+	reg := prometheus.NewRegistry()
+	if err := Init("test", DefBuckets, WithRegisterer(reg)); err != nil {
+		t.Fatalf("Init failed: %v", err)
+	}
+
+	assert.Nil(t, servedHandler())
+
+	series := gather(t, reg)
+	for _, name := range []string{"function_calls_count", "function_calls_duration", "function_calls_concurrent"} {
+		found := functionSeries(series, name, "servedHandler")
+		if assert.NotEmpty(t, found, "The call must be recorded in %v.", name) {
+			assert.Equal(t, "checkout", found[0].labels[ServiceNameLabel], "The service name must be read from the environment.")
+		}
+	}
+
+	for _, s := range series {
+		if s.name == "autometrics_build_info" {
+			assert.Equal(t, "checkout", s.labels[ServiceNameLabel], "The build information must have the service name.")
+		}
+	}
+
+	if err := Init("test", DefBuckets, WithRegisterer(prometheus.NewRegistry()), WithService("payments")); err != nil {
+		t.Fatalf("Init failed: %v", err)
+	}
+	assert.Equal(t, "payments", serviceName, "The option must override the environment variable.")
+}
+
+func specCallee() (err error) {
+	defer Instrument(PreInstrument(NewContext()), &err)
+
+	return nil
+}
+
+func specCaller() error {
+	return specCallee()
+}
+
+func TestSpecNaming(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	if err := Init("test", DefBuckets, WithRegisterer(reg), WithNamingScheme(autometrics.SpecNaming)); err != nil {
+		t.Fatalf("Init failed: %v", err)
+	}
+
+	assert.Nil(t, specCaller())
+
+	series := gather(t, reg)
+	for _, name := range []string{"function_calls_total", "function_calls_duration_seconds"} {
+		found := functionSeries(series, name, "specCallee")
+		if assert.Len(t, found, 1, "The call must be exported as %v.", name) {
+			assert.Equal(t, "specCaller", found[0].labels["caller_function"])
+			assert.Equal(t, "otel", found[0].labels["caller_module"])
+			assert.NotContains(t, found[0].labels, CallerLabel, "The legacy caller attribute must not be used.")
+		}
+	}
+	assert.Empty(t, functionSeries(series, "function_calls_count", "specCallee"), "The legacy names must not be used.")
+
+	var buildInfos int
+	for _, s := range series {
+		switch s.name {
+		case "build_info":
+			buildInfos++
+		case "autometrics_build_info":
+			t.Errorf("The legacy build information metric must not be used.")
+		}
+	}
+	assert.Equal(t, 1, buildInfos, "The build information must use the name of the specification.")
+}
@@ -0,0 +1,165 @@
+package otel // import "github.com/autometrics-dev/autometrics-go/pkg/autometrics/otel"
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/autometrics-dev/autometrics-go/pkg/autometrics"
+
+	otelglobal "go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	// TracerName is the name of the tracer that starts the spans of the instrumented functions.
+	TracerName = "github.com/autometrics-dev/autometrics-go"
+
+	// SpanCallerFunctionAttribute is the span attribute that describes the name of the function that called
+	// the current function.
+	SpanCallerFunctionAttribute = "caller.function"
+	// SpanCallerModuleAttribute is the span attribute that describes the module name of the function that called
+	// the current function.
+	SpanCallerModuleAttribute = "caller.module"
+	// SpanLatencyObjectiveAttribute is the span attribute that describes the percentage of calls that must last
+	// less than the value in [TargetLatencyLabel].
+	//
+	// The spans use [TargetSuccessRateLabel] for the success objective only.
+	SpanLatencyObjectiveAttribute = "objective.latency_percentile"
+)
+
+// tracerProvider is the provider given to Init with WithTracerProvider, nil to use the global one.
+var tracerProvider trace.TracerProvider
+
+// WithTracing starts an OpenTelemetry span for each call of the function, see [autometrics.Context.TrackSpan].
+//
+// The span is named after the function, and is ended by Instrument with an error status when the
+// function fails. It is started with the provider given to Init with WithTracerProvider, or with
+// the global provider of OpenTelemetry.
+//
+// The generated code uses this option for the functions with the --trace argument.
+func WithTracing(enabled bool) autometrics.Option {
+	return optionFunc(func(ctx *autometrics.Context) {
+		ctx.TrackSpan = enabled
+	})
+}
+
+// tracerProviderOption is the option returned by WithTracerProvider, read by Init
+// as the provider is not part of [autometrics.InitSettings].
+type tracerProviderOption struct {
+	provider trace.TracerProvider
+}
+
+func (tracerProviderOption) ApplyInit(*autometrics.InitSettings) {}
+
+// WithTracerProvider sets the provider of the spans started for the functions using WithTracing,
+// instead of the global provider of OpenTelemetry.
+func WithTracerProvider(provider trace.TracerProvider) autometrics.InitOption {
+	return tracerProviderOption{provider: provider}
+}
+
+// startSpan starts the span of the call, and stores it in the context of the call and
+// in the context.Context or *http.Request variable of the function, if any, so that the
+// spans of the nested calls are its children.
+func startSpan(ctx *autometrics.Context) {
+	provider := tracerProvider
+	if provider == nil {
+		provider = otelglobal.GetTracerProvider()
+	}
+
+	goCtx, _ := provider.Tracer(TracerName).Start(ctx.Context, ctx.CallInfo.FuncName,
+		trace.WithAttributes(spanAttributes(ctx)...))
+	ctx.Context = goCtx
+
+	if ctx.ContextPointer != nil {
+		*ctx.ContextPointer = goCtx
+	} else if ctx.RequestPointer != nil && *ctx.RequestPointer != nil {
+		*ctx.RequestPointer = (*ctx.RequestPointer).WithContext(goCtx)
+	}
+}
+
+// endSpan ends the span of the call started by startSpan, with an error status if the call failed.
+func endSpan(ctx *autometrics.Context, err error) {
+	span := trace.SpanFromContext(ctx.Context)
+
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+
+	span.End()
+}
+
+// spanAttributes returns the attributes of the span of the call: the function, its caller,
+// and the objectives of the function.
+func spanAttributes(ctx *autometrics.Context) []attribute.KeyValue {
+	attributes := []attribute.KeyValue{
+		attribute.Key(FunctionLabel).String(ctx.CallInfo.FuncName),
+		attribute.Key(ModuleLabel).String(ctx.CallInfo.ModuleName),
+	}
+
+	if ctx.TrackCallerName {
+		attributes = append(attributes,
+			attribute.Key(SpanCallerFunctionAttribute).String(ctx.CallInfo.ParentFuncName),
+			attribute.Key(SpanCallerModuleAttribute).String(ctx.CallInfo.ParentModuleName),
+		)
+	}
+
+	// With several objectives, each attribute has the values of all the objectives in the same
+	// order, separated by commas, and an empty value for the objectives that do not set it.
+	alertConfs := ctx.AlertConfs()
+	if len(alertConfs) > 0 {
+		attributes = append(attributes, attribute.Key(SloNameLabel).String(joinObjectives(alertConfs, func(a autometrics.AlertConfiguration) string {
+			return a.ServiceName
+		})))
+	}
+	if objectivesHave(alertConfs, func(a autometrics.AlertConfiguration) bool { return a.Latency != nil }) {
+		attributes = append(attributes,
+			attribute.Key(TargetLatencyLabel).String(joinObjectives(alertConfs, func(a autometrics.AlertConfiguration) string {
+				if a.Latency == nil {
+					return ""
+				}
+				return strconv.FormatFloat(a.Latency.Target.Seconds(), 'f', -1, 64)
+			})),
+			attribute.Key(SpanLatencyObjectiveAttribute).String(joinObjectives(alertConfs, func(a autometrics.AlertConfiguration) string {
+				if a.Latency == nil {
+					return ""
+				}
+				return strconv.FormatFloat(a.Latency.Objective, 'f', -1, 64)
+			})),
+		)
+	}
+	if objectivesHave(alertConfs, func(a autometrics.AlertConfiguration) bool { return a.Success != nil }) {
+		attributes = append(attributes,
+			attribute.Key(TargetSuccessRateLabel).String(joinObjectives(alertConfs, func(a autometrics.AlertConfiguration) string {
+				if a.Success == nil {
+					return ""
+				}
+				return strconv.FormatFloat(a.Success.Objective, 'f', -1, 64)
+			})))
+	}
+
+	return attributes
+}
+
+// joinObjectives returns the values of the objectives separated by commas.
+func joinObjectives(alertConfs []autometrics.AlertConfiguration, value func(autometrics.AlertConfiguration) string) string {
+	values := make([]string, 0, len(alertConfs))
+	for _, alertConf := range alertConfs {
+		values = append(values, value(alertConf))
+	}
+
+	return strings.Join(values, ",")
+}
+
+// objectivesHave returns true if one of the objectives matches.
+func objectivesHave(alertConfs []autometrics.AlertConfiguration, match func(autometrics.AlertConfiguration) bool) bool {
+	for _, alertConf := range alertConfs {
+		if match(alertConf) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,78 @@
+package otel
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func tracedParent(ctx context.Context) (err error) {
+	defer Instrument(PreInstrument(NewContext(
+		WithTracing(true),
+		WithContext(&ctx),
+	)), &err)
+
+	return tracedChild(ctx)
+}
+
+func tracedChild(ctx context.Context) (err error) {
+	defer Instrument(PreInstrument(NewContext(
+		WithTracing(true),
+		WithContext(&ctx),
+		WithSloName("API"),
+		WithAlertSuccess(99.9),
+	)), &err)
+
+	return errors.New("the call failed")
+}
+
+func TestTracing(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+
+	if err := Init("test", DefBuckets, WithTracerProvider(provider)); err != nil {
+		t.Fatalf("Init failed: %s", err)
+	}
+
+	assert.NotNil(t, tracedParent(context.Background()))
+
+	spans := recorder.Ended()
+	if !assert.Len(t, spans, 2, "Each call must have its own span.") {
+		return
+	}
+	child, parent := spans[0], spans[1]
+
+	assert.Equal(t, "tracedChild", child.Name())
+	assert.Equal(t, "tracedParent", parent.Name())
+	assert.Equal(t, parent.SpanContext().SpanID(), child.Parent().SpanID(), "The span of the nested call must be a child of the caller span.")
+
+	assert.Equal(t, codes.Error, child.Status().Code, "The span of a failed call must have an error status.")
+	assert.Len(t, child.Events(), 1, "The error must be recorded in the span.")
+	assert.Contains(t, child.Attributes(), attribute.String(FunctionLabel, "tracedChild"))
+	assert.Contains(t, child.Attributes(), attribute.String(ModuleLabel, "otel"))
+	assert.Contains(t, child.Attributes(), attribute.String(SpanCallerFunctionAttribute, "tracedParent"))
+	assert.Contains(t, child.Attributes(), attribute.String(SloNameLabel, "API"))
+	assert.Contains(t, child.Attributes(), attribute.String(TargetSuccessRateLabel, "99.9"))
+}
+
+func TestSpanAttributesSeveralObjectives(t *testing.T) {
+	ctx := NewContext(
+		WithSloName("API"),
+		WithAlertSuccess(99.9),
+		WithObjective("Checkout", WithAlertLatency(250*time.Millisecond, 99)),
+	)
+
+	attributes := spanAttributes(ctx)
+	assert.Contains(t, attributes, attribute.String(SloNameLabel, "API,Checkout"))
+	assert.Contains(t, attributes, attribute.String(TargetSuccessRateLabel, "99.9,"),
+		"Each objective must have its value in the same order as the names.")
+	assert.Contains(t, attributes, attribute.String(TargetLatencyLabel, ",0.25"))
+	assert.Contains(t, attributes, attribute.String(SpanLatencyObjectiveAttribute, ",99"))
+}
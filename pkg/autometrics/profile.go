@@ -0,0 +1,88 @@
+package autometrics
+
+import (
+	"context"
+	"runtime/pprof"
+	"sync/atomic"
+)
+
+const (
+	// ProfileFunctionLabel is the pprof label that describes the function name.
+	ProfileFunctionLabel = "function"
+	// ProfileModuleLabel is the pprof label that describes the module name that contains the function.
+	ProfileModuleLabel = "module"
+	// ProfileSloNameLabel is the pprof label that describes the names of the Service Level Objectives
+	// of the function, separated by commas, if it has any.
+	ProfileSloNameLabel = "objective_name"
+)
+
+// profileLabels is 1 when all the functions apply pprof labels, see SetProfileLabels.
+var profileLabels int32
+
+// SetProfileLabels sets whether all the instrumented functions apply pprof labels to the goroutine
+// of their calls, instead of the functions with [Context.TrackProfileLabels] only.
+//
+// The Init function of the implementations calls it with the value of the WithGlobalProfileLabels option.
+func SetProfileLabels(enabled bool) {
+	var value int32
+	if enabled {
+		value = 1
+	}
+
+	atomic.StoreInt32(&profileLabels, value)
+}
+
+// ApplyProfileLabels applies the pprof labels of the function (ProfileFunctionLabel, ProfileModuleLabel
+// and ProfileSloNameLabel) to the current goroutine, if the function tracks them, so that the CPU
+// profiles can be filtered by function, like with `-tagfocus function=indexHandler`.
+//
+// The labels are added to c.Context, and to the context.Context or *http.Request variable of
+// the function given with ContextPointer or RequestPointer, so that the nested calls keep them.
+// The goroutine labels cannot be read, so they are only set when the function has such a
+// variable: its context holds the labels of the caller, that RestoreProfileLabels restores.
+// Without it, the labels of the caller, like the ones set by pprof.Do, are left as is.
+// PreInstrument calls this method after PropagateCaller.
+func (c *Context) ApplyProfileLabels() {
+	if !c.TrackProfileLabels && atomic.LoadInt32(&profileLabels) != 1 {
+		return
+	}
+
+	labels := []string{
+		ProfileFunctionLabel, c.CallInfo.FuncName,
+		ProfileModuleLabel, c.CallInfo.ModuleName,
+	}
+	if names := c.ObjectiveNames(); names != "" {
+		labels = append(labels, ProfileSloNameLabel, names)
+	}
+
+	if c.Context == nil {
+		c.Context = context.Background()
+	}
+
+	parent := c.Context
+	c.Context = pprof.WithLabels(c.Context, pprof.Labels(labels...))
+
+	if c.ContextPointer != nil {
+		*c.ContextPointer = c.Context
+	} else if c.RequestPointer != nil && *c.RequestPointer != nil {
+		*c.RequestPointer = (*c.RequestPointer).WithContext(c.Context)
+	} else {
+		return
+	}
+
+	c.profileParent = parent
+	c.profileLabelsApplied = true
+	pprof.SetGoroutineLabels(c.Context)
+}
+
+// RestoreProfileLabels restores the pprof labels of the goroutine that were set before
+// ApplyProfileLabels, which are the labels of the context of the function. Instrument
+// calls this method when the call ends.
+func (c *Context) RestoreProfileLabels() {
+	if !c.profileLabelsApplied {
+		return
+	}
+
+	pprof.SetGoroutineLabels(c.profileParent)
+	c.profileLabelsApplied = false
+}
@@ -0,0 +1,134 @@
+package autometrics
+
+import (
+	"bytes"
+	"context"
+	"runtime/pprof"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// goroutineLabels returns the pprof labels of the current goroutine, as written in
+// the goroutine profile, like `{"function":"child", "module":"api"}`.
+func goroutineLabels(t *testing.T) string {
+	var profile bytes.Buffer
+	if err := pprof.Lookup("goroutine").WriteTo(&profile, 1); err != nil {
+		t.Fatalf("error writing the goroutine profile: %s", err)
+	}
+
+	// The current goroutine is the one writing the profile.
+	for _, entry := range strings.Split(profile.String(), "\n\n") {
+		if !strings.Contains(entry, "runtime/pprof.writeGoroutine") {
+			continue
+		}
+		for _, line := range strings.Split(entry, "\n") {
+			if labels := strings.TrimPrefix(line, "# labels: "); labels != line {
+				return labels
+			}
+		}
+		return ""
+	}
+
+	t.Fatal("the current goroutine is not in the goroutine profile")
+	return ""
+}
+
+func TestApplyProfileLabels(t *testing.T) {
+	// The labels of the caller, like the ones set by pprof.Do in a middleware.
+	goCtx := pprof.WithLabels(context.Background(), pprof.Labels(ProfileFunctionLabel, "parent", "team", "checkout"))
+	pprof.SetGoroutineLabels(goCtx)
+	defer pprof.SetGoroutineLabels(context.Background())
+	callerLabels := goroutineLabels(t)
+
+	ctx := NewContext()
+	ctx.Context = goCtx
+	ctx.ContextPointer = &goCtx
+	ctx.CallInfo = CallInfo{FuncName: "child", ModuleName: "api"}
+	ctx.AlertConf = &AlertConfiguration{ServiceName: "API"}
+
+	ctx.ApplyProfileLabels()
+	assert.Equal(t, callerLabels, goroutineLabels(t), "The labels must be left as is for a function that does not track them.")
+	assert.False(t, ctx.profileLabelsApplied)
+
+	ctx.TrackProfileLabels = true
+	ctx.ApplyProfileLabels()
+
+	function, _ := pprof.Label(goCtx, ProfileFunctionLabel)
+	module, _ := pprof.Label(goCtx, ProfileModuleLabel)
+	sloName, _ := pprof.Label(goCtx, ProfileSloNameLabel)
+	team, _ := pprof.Label(goCtx, "team")
+	assert.Equal(t, "child", function)
+	assert.Equal(t, "api", module)
+	assert.Equal(t, "API", sloName)
+	assert.Equal(t, "checkout", team, "The labels of the caller must be kept.")
+	assert.Equal(t, `{"function":"child", "module":"api", "objective_name":"API", "team":"checkout"}`, goroutineLabels(t),
+		"The labels of the function must be applied to the goroutine.")
+
+	ctx.RestoreProfileLabels()
+	assert.Equal(t, callerLabels, goroutineLabels(t), "The labels of the caller must be restored.")
+	assert.False(t, ctx.profileLabelsApplied)
+}
+
+func TestApplyProfileLabelsWithoutContext(t *testing.T) {
+	pprof.SetGoroutineLabels(pprof.WithLabels(context.Background(), pprof.Labels("team", "checkout")))
+	defer pprof.SetGoroutineLabels(context.Background())
+	callerLabels := goroutineLabels(t)
+
+	// A nested function without context.Context or *http.Request variable.
+	ctx := NewContext()
+	ctx.CallInfo = CallInfo{FuncName: "child", ModuleName: "api"}
+	ctx.TrackProfileLabels = true
+
+	ctx.ApplyProfileLabels()
+	function, _ := pprof.Label(ctx.Context, ProfileFunctionLabel)
+	assert.Equal(t, "child", function, "The labels must be added to the context of the call.")
+	assert.Equal(t, callerLabels, goroutineLabels(t), "The goroutine labels must not be replaced without a context to restore them from.")
+
+	ctx.RestoreProfileLabels()
+	assert.Equal(t, callerLabels, goroutineLabels(t), "The labels of the caller must be kept after the call.")
+}
+
+func TestApplyProfileLabelsSeveralObjectives(t *testing.T) {
+	ctx := NewContext()
+	ctx.CallInfo = CallInfo{FuncName: "child", ModuleName: "api"}
+	ctx.TrackProfileLabels = true
+	ctx.AlertConf = &AlertConfiguration{ServiceName: "API"}
+	ctx.MoreAlertConfs = []AlertConfiguration{{ServiceName: "Checkout"}, {ServiceName: "API"}}
+
+	ctx.ApplyProfileLabels()
+	sloName, _ := pprof.Label(ctx.Context, ProfileSloNameLabel)
+	assert.Equal(t, "API,Checkout", sloName, "The label must have the names of all the objectives.")
+}
+
+func TestSetProfileLabels(t *testing.T) {
+	SetProfileLabels(true)
+	defer SetProfileLabels(false)
+
+	pprof.SetGoroutineLabels(pprof.WithLabels(context.Background(), pprof.Labels("team", "checkout")))
+	defer pprof.SetGoroutineLabels(context.Background())
+	callerLabels := goroutineLabels(t)
+
+	// A function generated without -context-caller or --profile-labels is not given its context.
+	ctx := NewContext()
+	ctx.CallInfo = CallInfo{FuncName: "child", ModuleName: "api"}
+
+	ctx.ApplyProfileLabels()
+	function, _ := pprof.Label(ctx.Context, ProfileFunctionLabel)
+	assert.Equal(t, "child", function, "The labels must be added to the context of the call.")
+	assert.Equal(t, callerLabels, goroutineLabels(t), "The goroutine labels must be left as is without a context.")
+	ctx.RestoreProfileLabels()
+
+	goCtx := pprof.WithLabels(context.Background(), pprof.Labels("team", "checkout"))
+	ctx = NewContext()
+	ctx.Context = goCtx
+	ctx.ContextPointer = &goCtx
+	ctx.CallInfo = CallInfo{FuncName: "child", ModuleName: "api"}
+
+	ctx.ApplyProfileLabels()
+	assert.Equal(t, `{"function":"child", "module":"api", "team":"checkout"}`, goroutineLabels(t),
+		"The labels must be applied to the goroutine of all the functions given their context.")
+	ctx.RestoreProfileLabels()
+	assert.Equal(t, callerLabels, goroutineLabels(t), "The labels of the caller must be restored.")
+}
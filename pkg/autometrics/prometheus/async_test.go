@@ -0,0 +1,78 @@
+package prometheus
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/autometrics-dev/autometrics-go/pkg/autometrics"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+)
+
+var errOutOfStock = errors.New("out of stock")
+
+func reserveItems(ctx context.Context) error {
+	g, _ := NewGroup(ctx)
+	g.Go("reserveItem", func(ctx context.Context) error {
+		return nil
+	})
+	g.Go("reserveItem", func(ctx context.Context) error {
+		return errOutOfStock
+	})
+
+	return g.Wait()
+}
+
+func TestAsyncTasks(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	if err := Init(reg, DefBuckets); err != nil {
+		t.Fatalf("Init failed: %v", err)
+	}
+
+	var mutex sync.Mutex
+	var calls []autometrics.ObservedCall
+	defer autometrics.AddCallObserver(func(call autometrics.ObservedCall) {
+		mutex.Lock()
+		defer mutex.Unlock()
+
+		if call.CallInfo.FuncName == "reserveItem" {
+			calls = append(calls, call)
+		}
+	})()
+
+	assert.ErrorIs(t, reserveItems(context.Background()), errOutOfStock, "Wait must return the error of the failed task.")
+
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	if assert.Len(t, calls, 2, "Each task must be recorded.") {
+		errorCount := 0
+		for _, call := range calls {
+			assert.Equal(t, "reserveItems", call.CallInfo.ParentFuncName, "The spawning function must be the caller of the task.")
+			assert.Equal(t, "prometheus", call.CallInfo.ModuleName, "The task must be in the module of the spawning function.")
+			if call.Err != nil {
+				errorCount++
+			}
+		}
+		assert.Equal(t, 1, errorCount, "The failed task must be recorded with its error.")
+	}
+
+	families, err := reg.Gather()
+	if err != nil {
+		t.Fatalf("Gather failed: %v", err)
+	}
+
+	found := false
+	for _, family := range families {
+		if family.GetName() != AsyncTasksInFlightName {
+			continue
+		}
+		for _, metric := range family.GetMetric() {
+			found = true
+			assert.Equal(t, float64(0), metric.GetGauge().GetValue(), "The in-flight gauge must go back to 0 once the tasks returned.")
+		}
+	}
+	assert.True(t, found, "The in-flight gauge of the spawning function must be published.")
+}
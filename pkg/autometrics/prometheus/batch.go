@@ -0,0 +1,50 @@
+package prometheus // import "github.com/autometrics-dev/autometrics-go/pkg/autometrics/prometheus"
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/autometrics-dev/autometrics-go/pkg/autometrics"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/push"
+)
+
+// Push sends all the metrics of the registry given to Init to the Pushgateway at url,
+// replacing the metrics previously pushed with the same job and grouping key.
+//
+// Batch jobs and CLI tools call it before exiting, since Prometheus does not scrape them.
+func Push(ctx context.Context, url, job string, grouping map[string]string) error {
+	if gatherer == nil {
+		return fmt.Errorf("error pushing metrics to %v: Init has not been called", url)
+	}
+
+	pusher := push.New(url, job).Gatherer(gatherer)
+	for name, value := range grouping {
+		pusher = pusher.Grouping(name, value)
+	}
+
+	if err := pusher.PushContext(ctx); err != nil {
+		return fmt.Errorf("error pushing metrics to %v: %w", url, err)
+	}
+
+	return nil
+}
+
+// recordLastRun records the batch mode gauges of a finished call.
+func recordLastRun(ctx *autometrics.Context, result string, duration time.Duration) {
+	functionLastRunDuration.With(prometheus.Labels{
+		FunctionLabel:    ctx.CallInfo.FuncName,
+		ModuleLabel:      ctx.CallInfo.ModuleName,
+		ResultLabel:      result,
+		ServiceNameLabel: serviceName,
+	}).Set(duration.Seconds())
+
+	if result == "ok" {
+		functionLastSuccess.With(prometheus.Labels{
+			FunctionLabel:    ctx.CallInfo.FuncName,
+			ModuleLabel:      ctx.CallInfo.ModuleName,
+			ServiceNameLabel: serviceName,
+		}).Set(float64(ctx.StartTime.Add(duration).UnixNano()) / float64(time.Second))
+	}
+}
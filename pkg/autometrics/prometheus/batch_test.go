@@ -0,0 +1,82 @@
+package prometheus
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+	"github.com/stretchr/testify/assert"
+)
+
+func batchJob() (err error) {
+	defer Instrument(PreInstrument(NewContext()), &err)
+
+	return nil
+}
+
+func failingBatchJob() (err error) {
+	defer Instrument(PreInstrument(NewContext()), &err)
+
+	return errors.New("the job failed")
+}
+
+func TestPush(t *testing.T) {
+	var pushedPath, pushedMethod string
+	pushed := make(map[string]*dto.MetricFamily)
+
+	pushgateway := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		pushedPath, pushedMethod = r.URL.Path, r.Method
+
+		decoder := expfmt.NewDecoder(r.Body, expfmt.ResponseFormat(r.Header))
+		for {
+			family := &dto.MetricFamily{}
+			if err := decoder.Decode(family); err != nil {
+				if !errors.Is(err, io.EOF) {
+					t.Errorf("The pushed metrics cannot be decoded: %v", err)
+				}
+				break
+			}
+			pushed[family.GetName()] = family
+		}
+
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer pushgateway.Close()
+
+	start := time.Now()
+	if err := Init(prometheus.NewRegistry(), DefBuckets, WithBatchMode(true)); err != nil {
+		t.Fatalf("Init failed: %v", err)
+	}
+
+	assert.Nil(t, batchJob())
+	assert.NotNil(t, failingBatchJob())
+
+	err := Push(context.Background(), pushgateway.URL, "nightly", map[string]string{"instance": "worker-1"})
+	if !assert.Nil(t, err, "The metrics must be pushed.") {
+		return
+	}
+
+	assert.Equal(t, http.MethodPut, pushedMethod, "The push must replace the metrics of the group.")
+	assert.Equal(t, "/metrics/job/nightly/instance/worker-1", pushedPath, "The job and grouping key are not as expected.")
+	assert.Contains(t, pushed, FunctionCallsCountName)
+	assert.Contains(t, pushed, FunctionLastRunDurationName)
+
+	if assert.Contains(t, pushed, FunctionLastSuccessTimestampName) {
+		successes := pushed[FunctionLastSuccessTimestampName].GetMetric()
+		if assert.Len(t, successes, 1, "Only the successful job has a last success timestamp.") {
+			labels := make(map[string]string)
+			for _, label := range successes[0].GetLabel() {
+				labels[label.GetName()] = label.GetValue()
+			}
+			assert.Equal(t, "batchJob", labels[FunctionLabel], "The function label is not as expected.")
+			assert.GreaterOrEqual(t, successes[0].GetGauge().GetValue(), float64(start.Unix()))
+		}
+	}
+}
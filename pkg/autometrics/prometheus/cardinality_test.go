@@ -0,0 +1,65 @@
+package prometheus
+
+import (
+	"testing"
+
+	"github.com/autometrics-dev/autometrics-go/pkg/autometrics"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+)
+
+func limitedCallee() (err error) {
+	defer Instrument(PreInstrument(NewContext()), &err)
+
+	return nil
+}
+
+func firstCaller() error {
+	return limitedCallee()
+}
+
+func secondCaller() error {
+	return limitedCallee()
+}
+
+func TestCardinalityLimit(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	if err := Init(reg, DefBuckets, WithCardinalityLimit(1)); err != nil {
+		t.Fatalf("Init failed: %v", err)
+	}
+
+	assert.Nil(t, firstCaller())
+	assert.Nil(t, secondCaller())
+	assert.Nil(t, secondCaller())
+	assert.Nil(t, firstCaller())
+
+	families, err := reg.Gather()
+	if err != nil {
+		t.Fatalf("Gather failed: %v", err)
+	}
+
+	counts := make(map[string]float64)
+	overflows := make(map[string]float64)
+	for _, family := range families {
+		for _, metric := range family.GetMetric() {
+			labels := make(map[string]string)
+			for _, label := range metric.GetLabel() {
+				labels[label.GetName()] = label.GetValue()
+			}
+			if labels[FunctionLabel] != "limitedCallee" {
+				continue
+			}
+
+			switch family.GetName() {
+			case FunctionCallsCountName:
+				counts[labels[CallerLabel]] += metric.GetCounter().GetValue()
+			case CardinalityOverflowName:
+				overflows[labels[LabelNameLabel]] += metric.GetCounter().GetValue()
+			}
+		}
+	}
+
+	assert.Equal(t, map[string]float64{"prometheus.firstCaller": 2, autometrics.OverflowLabelValue: 2}, counts,
+		"The callers over the limit must be recorded with the overflow value.")
+	assert.Equal(t, map[string]float64{CallerLabel: 2}, overflows, "The overflowed callers must be counted.")
+}
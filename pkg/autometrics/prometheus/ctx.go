@@ -1,6 +1,9 @@
 package prometheus // import "github.com/autometrics-dev/autometrics-go/pkg/autometrics/prometheus"
 
 import (
+	"context"
+	"fmt"
+	"net/http"
 	"time"
 
 	"github.com/autometrics-dev/autometrics-go/pkg/autometrics"
@@ -15,7 +18,7 @@ func (fn optionFunc) Apply(ctx *autometrics.Context) {
 func NewContext(opts ...autometrics.Option) *autometrics.Context {
 	ctx := autometrics.NewContext()
 
-	for _, o := range(opts) {
+	for _, o := range opts {
 		o.Apply(&ctx)
 	}
 
@@ -65,6 +68,32 @@ func WithSloName(name string) autometrics.Option {
 	})
 }
 
+// WithObjective adds an objective named name, set with the WithAlertLatency and WithAlertSuccess
+// options, to a function that has several of them. The first objective of a function is its
+// AlertConf, the calls are recorded once more for each of the next ones.
+//
+// The generated code uses this option for the --slo arguments after the first one.
+func WithObjective(name string, opts ...autometrics.Option) autometrics.Option {
+	return optionFunc(func(ctx *autometrics.Context) {
+		var objective autometrics.Context
+		for _, o := range opts {
+			o.Apply(&objective)
+		}
+
+		alertConf := autometrics.AlertConfiguration{}
+		if objective.AlertConf != nil {
+			alertConf = *objective.AlertConf
+		}
+		alertConf.ServiceName = name
+
+		if ctx.AlertConf == nil {
+			ctx.AlertConf = &alertConf
+		} else {
+			ctx.MoreAlertConfs = append(ctx.MoreAlertConfs, alertConf)
+		}
+	})
+}
+
 func WithConcurrentCalls(enabled bool) autometrics.Option {
 	return optionFunc(func(ctx *autometrics.Context) {
 		ctx.TrackConcurrentCalls = enabled
@@ -76,3 +105,199 @@ func WithCallerName(enabled bool) autometrics.Option {
 		ctx.TrackCallerName = enabled
 	})
 }
+
+// WithFunctionName records the calls under name instead of the name of the instrumented function
+// in the metrics. The functions it calls only see this name as caller when the caller is tracked
+// through the context, see WithContext: the stack frames hold the Go name.
+//
+// The generated code uses this option for the --name argument.
+func WithFunctionName(name string) autometrics.Option {
+	return optionFunc(func(ctx *autometrics.Context) {
+		ctx.CallInfo.FuncName = name
+	})
+}
+
+// WithModuleName records the calls under module instead of the module of the instrumented function
+// in the metrics. The functions it calls only see this module as caller when the caller is tracked
+// through the context, see WithContext: the stack frames hold the Go package.
+//
+// The generated code uses this option for the --module argument.
+func WithModuleName(module string) autometrics.Option {
+	return optionFunc(func(ctx *autometrics.Context) {
+		ctx.CallInfo.ModuleName = module
+	})
+}
+
+// WithLabel sets the value of a user-defined label of the metrics of the call. The label must be
+// declared in Init with WithLabelAllowlist, and value is formatted with fmt.Sprint.
+//
+// The generated code uses this option for the --label arguments.
+func WithLabel(name string, value interface{}) autometrics.Option {
+	return optionFunc(func(ctx *autometrics.Context) {
+		label := autometrics.Label{Name: name, Value: fmt.Sprint(value)}
+		for i := range ctx.Labels {
+			if ctx.Labels[i].Name == name {
+				ctx.Labels[i] = label
+				return
+			}
+		}
+		ctx.Labels = append(ctx.Labels, label)
+	})
+}
+
+// WithProfileLabels applies pprof labels to the goroutine for the duration of each call of the function,
+// see [autometrics.Context.ApplyProfileLabels].
+func WithProfileLabels(enabled bool) autometrics.Option {
+	return optionFunc(func(ctx *autometrics.Context) {
+		ctx.TrackProfileLabels = enabled
+	})
+}
+
+// WithRuntimeTrace opens a runtime/trace region or task for each call of the function,
+// see [autometrics.Context.StartRuntimeTrace].
+func WithRuntimeTrace(enabled bool) autometrics.Option {
+	return optionFunc(func(ctx *autometrics.Context) {
+		ctx.TrackRuntimeTrace = enabled
+	})
+}
+
+// WithContext tracks the caller through the context.Context variable ctx points to, instead
+// of the stack frames, see [autometrics.Context.PropagateCaller].
+//
+// PreInstrument replaces the variable with a context recording the instrumented function.
+func WithContext(ctx *context.Context) autometrics.Option {
+	return optionFunc(func(amCtx *autometrics.Context) {
+		amCtx.ContextPointer = ctx
+	})
+}
+
+// WithRequest tracks the caller through the context of the *http.Request variable r points to,
+// instead of the stack frames, see [autometrics.Context.PropagateCaller].
+//
+// PreInstrument replaces the variable with a request whose context records the instrumented function.
+func WithRequest(r **http.Request) autometrics.Option {
+	return optionFunc(func(amCtx *autometrics.Context) {
+		amCtx.RequestPointer = r
+	})
+}
+
+// WithContextCaller sets whether the caller is tracked through the context given with WithContext
+// or WithRequest, true by default. When disabled, the caller is read from the stack frames and
+// the context is only used for the pprof labels, the runtime/trace tasks and the spans,
+// see [autometrics.Context.TrackContextCaller].
+func WithContextCaller(enabled bool) autometrics.Option {
+	return optionFunc(func(ctx *autometrics.Context) {
+		ctx.TrackContextCaller = enabled
+	})
+}
+
+type initOptionFunc func(*autometrics.InitSettings)
+
+func (fn initOptionFunc) ApplyInit(settings *autometrics.InitSettings) {
+	fn(settings)
+}
+
+// WithVersion sets the version reported in the build information metric.
+func WithVersion(version string) autometrics.InitOption {
+	return initOptionFunc(func(settings *autometrics.InitSettings) {
+		settings.BuildInfo.Version = version
+	})
+}
+
+// WithCommit sets the commit reported in the build information metric.
+func WithCommit(commit string) autometrics.InitOption {
+	return initOptionFunc(func(settings *autometrics.InitSettings) {
+		settings.BuildInfo.Commit = commit
+	})
+}
+
+// WithBranch sets the branch reported in the build information metric.
+func WithBranch(branch string) autometrics.InitOption {
+	return initOptionFunc(func(settings *autometrics.InitSettings) {
+		settings.BuildInfo.Branch = branch
+	})
+}
+
+// WithService sets the name of the service in the ServiceNameLabel of all the metrics.
+func WithService(name string) autometrics.InitOption {
+	return initOptionFunc(func(settings *autometrics.InitSettings) {
+		settings.ServiceName = name
+	})
+}
+
+// WithNamingScheme sets the names of the metrics and labels, see [autometrics.NamingScheme].
+func WithNamingScheme(scheme autometrics.NamingScheme) autometrics.InitOption {
+	return initOptionFunc(func(settings *autometrics.InitSettings) {
+		settings.NamingScheme = scheme
+	})
+}
+
+// WithCardinalityLimit sets the maximum number of distinct callers recorded for each function.
+//
+// Once a function has been called from limit distinct callers, the calls from new callers
+// are recorded with the [autometrics.OverflowLabelValue] caller. The default, 0, means no limit.
+func WithCardinalityLimit(limit int) autometrics.InitOption {
+	return initOptionFunc(func(settings *autometrics.InitSettings) {
+		settings.CardinalityLimit = limit
+	})
+}
+
+// WithGlobalProfileLabels applies pprof labels to the goroutines of the calls of all the instrumented
+// functions, like WithProfileLabels does for a single function. The goroutine labels are only set for
+// the functions given their context with WithContext or WithRequest, which the generator only does
+// with -context-caller or --profile-labels, see [autometrics.Context.ApplyProfileLabels].
+func WithGlobalProfileLabels(enabled bool) autometrics.InitOption {
+	return initOptionFunc(func(settings *autometrics.InitSettings) {
+		settings.ProfileLabels = enabled
+	})
+}
+
+// WithGlobalRuntimeTrace opens runtime/trace regions or tasks for the calls of all the instrumented
+// functions, like WithRuntimeTrace does for a single function.
+func WithGlobalRuntimeTrace(enabled bool) autometrics.InitOption {
+	return initOptionFunc(func(settings *autometrics.InitSettings) {
+		settings.RuntimeTrace = enabled
+	})
+}
+
+// WithLabelAllowlist declares a user-defined label of the function call metrics, set for each call
+// with WithLabel. The values that are not in values are recorded as [autometrics.OverflowLabelValue],
+// to bound the cardinality of the metrics.
+func WithLabelAllowlist(name string, values ...string) autometrics.InitOption {
+	return initOptionFunc(func(settings *autometrics.InitSettings) {
+		settings.AllowedLabels = append(settings.AllowedLabels, autometrics.AllowedLabel{Name: name, Values: values})
+	})
+}
+
+// WithLogger sets the logger of the calls that return an error or exceed the latency target
+// of their SLO, see [autometrics.SetLogger]. A *slog.Logger can be used as logger.
+func WithLogger(logger autometrics.Logger) autometrics.InitOption {
+	return initOptionFunc(func(settings *autometrics.InitSettings) {
+		settings.Logger = logger
+	})
+}
+
+// WithLogInterval sets the minimum time between two log records of the same function,
+// [autometrics.DefaultLogInterval] by default.
+func WithLogInterval(interval time.Duration) autometrics.InitOption {
+	return initOptionFunc(func(settings *autometrics.InitSettings) {
+		settings.LogInterval = interval
+	})
+}
+
+// WithClosureSuffix keeps the suffix of closures in the function and caller names, like "handler.func1",
+// instead of attributing the closures to their enclosing function.
+func WithClosureSuffix(enabled bool) autometrics.InitOption {
+	return initOptionFunc(func(settings *autometrics.InitSettings) {
+		settings.ClosureSuffix = enabled
+	})
+}
+
+// WithBatchMode records the FunctionLastSuccessTimestampName and FunctionLastRunDurationName
+// gauges, for the batch jobs and CLI tools that exit before being scraped. Send the metrics
+// to a Pushgateway with Push before the program exits.
+func WithBatchMode(enabled bool) autometrics.InitOption {
+	return initOptionFunc(func(settings *autometrics.InitSettings) {
+		settings.BatchMode = enabled
+	})
+}
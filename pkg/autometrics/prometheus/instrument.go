@@ -14,55 +14,94 @@ import (
 //
 // The first argument SHOULD be a call to PreInstrument so that
 // the "concurrent calls" gauge is correctly setup.
-func  Instrument(ctx *autometrics.Context, err *error) {
+func Instrument(ctx *autometrics.Context, err *error) {
 	result := "ok"
+	var callErr error
 
 	if err != nil && *err != nil {
 		result = "error"
+		callErr = *err
 	}
 
-	var callerLabel, sloName, latencyTarget, latencyObjective, successObjective string
+	duration := time.Since(ctx.StartTime)
+	ctx.EndRuntimeTrace()
+	ctx.RestoreProfileLabels()
+	autometrics.ObserveCall(ctx, callErr, duration)
+	autometrics.LogCall(ctx, callErr, duration)
 
-	if ctx.TrackCallerName {
-		callerLabel = fmt.Sprintf("%s.%s", ctx.CallInfo.ParentModuleName, ctx.CallInfo.ParentFuncName)
+	// Without Init, the calls only go to the call observers.
+	if functionCallsCount == nil {
+		autometrics.WarnUninitialized("prometheus")
+		return
 	}
 
-	if ctx.AlertConf != nil {
-		sloName = ctx.AlertConf.ServiceName
+	callerFunction, callerModule := callerNames(ctx)
+	if callerModule == autometrics.OverflowLabelValue {
+		cardinalityOverflows.With(prometheus.Labels{
+			FunctionLabel:    ctx.CallInfo.FuncName,
+			ModuleLabel:      ctx.CallInfo.ModuleName,
+			LabelNameLabel:   callerLabelName(),
+			ServiceNameLabel: serviceName,
+		}).Inc()
+	}
+
+	custom, overflowed := customLabels.Values(ctx)
+	for _, name := range overflowed {
+		cardinalityOverflows.With(prometheus.Labels{
+			FunctionLabel:    ctx.CallInfo.FuncName,
+			ModuleLabel:      ctx.CallInfo.ModuleName,
+			LabelNameLabel:   name,
+			ServiceNameLabel: serviceName,
+		}).Inc()
+	}
 
-		if ctx.AlertConf.Latency != nil {
-			latencyTarget = strconv.FormatFloat(ctx.AlertConf.Latency.Target.Seconds(), 'f', -1, 64)
-			latencyObjective = strconv.FormatFloat(ctx.AlertConf.Latency.Objective, 'f', -1, 64)
+	sloName, latencyTarget, latencyObjective, successObjective := sloLabels(ctx.AlertConf)
+
+	countLabels := functionLabels(ctx.CallInfo.FuncName, ctx.CallInfo.ModuleName, callerFunction, callerModule, custom)
+	countLabels[ResultLabel] = result
+	countLabels[TargetSuccessRateLabel] = successObjective
+	countLabels[SloNameLabel] = sloName
+	countLabels[ObjectiveCopyLabel] = ""
+	functionCallsCount.With(countLabels).Inc()
+
+	durationLabels := functionLabels(ctx.CallInfo.FuncName, ctx.CallInfo.ModuleName, callerFunction, callerModule, custom)
+	durationLabels[TargetLatencyLabel] = latencyTarget
+	durationLabels[TargetSuccessRateLabel] = latencyObjective
+	durationLabels[SloNameLabel] = sloName
+	durationLabels[ObjectiveCopyLabel] = ""
+	functionCallsDuration.With(durationLabels).Observe(duration.Seconds())
+
+	// The calls are recorded once more for each other objective of the function, in series
+	// marked as copies so that the queries counting the calls can leave them out.
+	for i := range ctx.MoreAlertConfs {
+		alertConf := &ctx.MoreAlertConfs[i]
+		sloName, latencyTarget, latencyObjective, successObjective := sloLabels(alertConf)
+
+		if alertConf.Success != nil {
+			countLabels := functionLabels(ctx.CallInfo.FuncName, ctx.CallInfo.ModuleName, callerFunction, callerModule, custom)
+			countLabels[ResultLabel] = result
+			countLabels[TargetSuccessRateLabel] = successObjective
+			countLabels[SloNameLabel] = sloName
+			countLabels[ObjectiveCopyLabel] = autometrics.ObjectiveCopyValue
+			functionCallsCount.With(countLabels).Inc()
 		}
 
-		if ctx.AlertConf.Success != nil {
-			successObjective = strconv.FormatFloat(ctx.AlertConf.Success.Objective, 'f', -1, 64)
+		if alertConf.Latency != nil {
+			durationLabels := functionLabels(ctx.CallInfo.FuncName, ctx.CallInfo.ModuleName, callerFunction, callerModule, custom)
+			durationLabels[TargetLatencyLabel] = latencyTarget
+			durationLabels[TargetSuccessRateLabel] = latencyObjective
+			durationLabels[SloNameLabel] = sloName
+			durationLabels[ObjectiveCopyLabel] = autometrics.ObjectiveCopyValue
+			functionCallsDuration.With(durationLabels).Observe(duration.Seconds())
 		}
 	}
 
-	functionCallsCount.With(prometheus.Labels{
-		FunctionLabel:          ctx.CallInfo.FuncName,
-		ModuleLabel:            ctx.CallInfo.ModuleName,
-		CallerLabel:            callerLabel,
-		ResultLabel:            result,
-		TargetSuccessRateLabel: successObjective,
-		SloNameLabel:           sloName,
-	}).Inc()
-	functionCallsDuration.With(prometheus.Labels{
-		FunctionLabel:          ctx.CallInfo.FuncName,
-		ModuleLabel:            ctx.CallInfo.ModuleName,
-		CallerLabel:            callerLabel,
-		TargetLatencyLabel:     latencyTarget,
-		TargetSuccessRateLabel: latencyObjective,
-		SloNameLabel:           sloName,
-	}).Observe(time.Since(ctx.StartTime).Seconds())
+	if batchMode {
+		recordLastRun(ctx, result, duration)
+	}
 
 	if ctx.TrackConcurrentCalls {
-		functionCallsConcurrent.With(prometheus.Labels{
-			FunctionLabel: ctx.CallInfo.FuncName,
-			ModuleLabel:   ctx.CallInfo.ModuleName,
-			CallerLabel:   callerLabel,
-		}).Dec()
+		functionCallsConcurrent.With(functionLabels(ctx.CallInfo.FuncName, ctx.CallInfo.ModuleName, callerFunction, callerModule, custom)).Dec()
 	}
 }
 
@@ -70,23 +109,105 @@ func  Instrument(ctx *autometrics.Context, err *error) {
 //
 // It is meant to be called as the first argument to Instrument in a
 // defer call.
-func  PreInstrument(ctx *autometrics.Context) *autometrics.Context {
-	ctx.CallInfo = autometrics.CallerInfo()
-
-	var callerLabel string
-	if ctx.TrackCallerName {
-		callerLabel = fmt.Sprintf("%s.%s", ctx.CallInfo.ParentModuleName, ctx.CallInfo.ParentFuncName)
-	}
+func PreInstrument(ctx *autometrics.Context) *autometrics.Context {
+	return preInstrument(ctx, autometrics.CallerInfo())
+}
 
-	if ctx.TrackConcurrentCalls {
-		functionCallsConcurrent.With(prometheus.Labels{
-			FunctionLabel: ctx.CallInfo.FuncName,
-			ModuleLabel:   ctx.CallInfo.ModuleName,
-			CallerLabel:   callerLabel,
-		}).Inc()
+// preInstrument runs PreInstrument with the call information read from the stack
+// frames, that the callers read themselves to skip the right number of frames.
+func preInstrument(ctx *autometrics.Context, callInfo autometrics.CallInfo) *autometrics.Context {
+	ctx.CallInfo = ctx.CallInfo.Complete(callInfo)
+	ctx.PropagateCaller()
+	ctx.ApplyProfileLabels()
+	ctx.StartRuntimeTrace()
+
+	if ctx.TrackConcurrentCalls && functionCallsConcurrent != nil {
+		callerFunction, callerModule := callerNames(ctx)
+		custom, _ := customLabels.Values(ctx)
+		functionCallsConcurrent.With(functionLabels(ctx.CallInfo.FuncName, ctx.CallInfo.ModuleName, callerFunction, callerModule, custom)).Inc()
 	}
 
 	ctx.StartTime = time.Now()
 
 	return ctx
 }
+
+// sloLabels returns the values of the (SloNameLabel, TargetLatencyLabel, latency TargetSuccessRateLabel,
+// success TargetSuccessRateLabel) labels for an objective of the function, which can be nil.
+func sloLabels(alertConf *autometrics.AlertConfiguration) (sloName, latencyTarget, latencyObjective, successObjective string) {
+	if alertConf != nil {
+		sloName = alertConf.ServiceName
+
+		if alertConf.Latency != nil {
+			latencyTarget = strconv.FormatFloat(alertConf.Latency.Target.Seconds(), 'f', -1, 64)
+			latencyObjective = strconv.FormatFloat(alertConf.Latency.Objective, 'f', -1, 64)
+		}
+
+		if alertConf.Success != nil {
+			successObjective = strconv.FormatFloat(alertConf.Success.Objective, 'f', -1, 64)
+		}
+	}
+
+	return
+}
+
+// callerNames returns the function and module names of the caller, or empty strings
+// if the context does not track the caller name.
+//
+// Both names are [autometrics.OverflowLabelValue] if the function exceeded the cardinality
+// limit for its callers.
+func callerNames(ctx *autometrics.Context) (callerFunction, callerModule string) {
+	if !ctx.TrackCallerName {
+		return "", ""
+	}
+
+	caller := fmt.Sprintf("%s.%s", ctx.CallInfo.ParentModuleName, ctx.CallInfo.ParentFuncName)
+	if !callerLimiter.Allow(ctx.CallInfo.FuncName, ctx.CallInfo.ModuleName, caller) {
+		return autometrics.OverflowLabelValue, autometrics.OverflowLabelValue
+	}
+
+	return ctx.CallInfo.ParentFuncName, ctx.CallInfo.ParentModuleName
+}
+
+// callerLabelName returns the name of the label holding the caller, following
+// the naming scheme given to Init.
+func callerLabelName() string {
+	if namingScheme == autometrics.SpecNaming {
+		return CallerFunctionLabel
+	}
+
+	return CallerLabel
+}
+
+// functionLabels returns the labels that identify a function, its caller and the service,
+// following the naming scheme given to Init, along with the user-defined labels declared in Init.
+//
+// An empty callerFunction and callerModule means the caller is unknown. custom holds the values
+// of the user-defined labels, see [autometrics.LabelAllowlist.Values]; the missing values are empty.
+func functionLabels(funcName, moduleName, callerFunction, callerModule string, custom []string) prometheus.Labels {
+	labels := prometheus.Labels{
+		FunctionLabel:    funcName,
+		ModuleLabel:      moduleName,
+		ServiceNameLabel: serviceName,
+	}
+
+	for i, name := range customLabels.Names() {
+		labels[name] = ""
+		if i < len(custom) {
+			labels[name] = custom[i]
+		}
+	}
+
+	if namingScheme == autometrics.SpecNaming {
+		labels[CallerFunctionLabel] = callerFunction
+		labels[CallerModuleLabel] = callerModule
+	} else if callerModule == autometrics.OverflowLabelValue {
+		labels[CallerLabel] = autometrics.OverflowLabelValue
+	} else if callerFunction != "" || callerModule != "" {
+		labels[CallerLabel] = fmt.Sprintf("%s.%s", callerModule, callerFunction)
+	} else {
+		labels[CallerLabel] = ""
+	}
+
+	return labels
+}
@@ -0,0 +1,60 @@
+package prometheus
+
+import (
+	"testing"
+
+	"github.com/autometrics-dev/autometrics-go/pkg/autometrics"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+)
+
+func tieredHandler(tier string) (err error) {
+	defer Instrument(PreInstrument(NewContext(
+		WithLabel("tier", tier),
+	)), &err)
+
+	return nil
+}
+
+func TestCustomLabels(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	if err := Init(reg, DefBuckets, WithLabelAllowlist("tier", "free", "pro")); err != nil {
+		t.Fatalf("Init failed: %v", err)
+	}
+
+	assert.Nil(t, tieredHandler("pro"))
+	assert.Nil(t, tieredHandler("pro"))
+	assert.Nil(t, tieredHandler("enterprise"))
+
+	families, err := reg.Gather()
+	if err != nil {
+		t.Fatalf("Gather failed: %v", err)
+	}
+
+	counts := make(map[string]float64)
+	overflows := make(map[string]float64)
+	for _, family := range families {
+		for _, metric := range family.GetMetric() {
+			labels := make(map[string]string)
+			for _, label := range metric.GetLabel() {
+				labels[label.GetName()] = label.GetValue()
+			}
+
+			switch family.GetName() {
+			case FunctionCallsCountName:
+				if labels[FunctionLabel] == "tieredHandler" {
+					counts[labels["tier"]] += metric.GetCounter().GetValue()
+				}
+			case CardinalityOverflowName:
+				overflows[labels[LabelNameLabel]] += metric.GetCounter().GetValue()
+			}
+		}
+	}
+
+	assert.Equal(t, map[string]float64{"pro": 2, autometrics.OverflowLabelValue: 1}, counts,
+		"The values missing from the allowlist must be recorded with the overflow value.")
+	assert.Equal(t, map[string]float64{"tier": 1}, overflows, "The overflowed values must be counted.")
+
+	err = Init(prometheus.NewRegistry(), DefBuckets, WithLabelAllowlist(ResultLabel, "ok"))
+	assert.ErrorContains(t, err, ResultLabel, "A label of the base schema must be rejected.")
+}
@@ -0,0 +1,69 @@
+package prometheus
+
+import (
+	"testing"
+	"time"
+
+	"github.com/autometrics-dev/autometrics-go/pkg/autometrics"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+)
+
+func checkoutHandler() (err error) {
+	defer Instrument(PreInstrument(NewContext(
+		WithSloName("API"),
+		WithAlertSuccess(99),
+		WithObjective("Checkout", WithAlertSuccess(99.9), WithAlertLatency(250*time.Millisecond, 99)),
+	)), &err)
+
+	return nil
+}
+
+func TestMultipleObjectives(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	if err := Init(reg, DefBuckets); err != nil {
+		t.Fatalf("Init failed: %v", err)
+	}
+
+	assert.Nil(t, checkoutHandler())
+
+	families, err := reg.Gather()
+	if err != nil {
+		t.Fatalf("Gather failed: %v", err)
+	}
+
+	type series struct {
+		objective string
+		copy      string
+	}
+	counts := make(map[series]float64)
+	durations := make(map[series]uint64)
+	for _, family := range families {
+		for _, metric := range family.GetMetric() {
+			labels := make(map[string]string)
+			for _, label := range metric.GetLabel() {
+				labels[label.GetName()] = label.GetValue()
+			}
+			if labels[FunctionLabel] != "checkoutHandler" {
+				continue
+			}
+
+			key := series{objective: labels[SloNameLabel] + "/" + labels[TargetSuccessRateLabel], copy: labels[ObjectiveCopyLabel]}
+			switch family.GetName() {
+			case FunctionCallsCountName:
+				counts[key] += metric.GetCounter().GetValue()
+			case FunctionCallsDurationName:
+				durations[key] += metric.GetHistogram().GetSampleCount()
+			}
+		}
+	}
+
+	assert.Equal(t, map[series]float64{
+		{objective: "API/99"}: 1,
+		{objective: "Checkout/99.9", copy: autometrics.ObjectiveCopyValue}: 1,
+	}, counts, "The call must be counted once for each success rate objective, and marked as a copy after the first one.")
+	assert.Equal(t, map[series]uint64{
+		{objective: "API/"}: 1,
+		{objective: "Checkout/99", copy: autometrics.ObjectiveCopyValue}: 1,
+	}, durations, "The call duration must be recorded once more for each other latency objective.")
+}
@@ -1,6 +1,8 @@
 package prometheus // import "github.com/autometrics-dev/autometrics-go/pkg/autometrics/prometheus"
 
 import (
+	"fmt"
+
 	"github.com/autometrics-dev/autometrics-go/pkg/autometrics"
 	"github.com/prometheus/client_golang/prometheus"
 )
@@ -9,6 +11,17 @@ var (
 	functionCallsCount      *prometheus.CounterVec
 	functionCallsDuration   *prometheus.HistogramVec
 	functionCallsConcurrent *prometheus.GaugeVec
+	buildInfo               *prometheus.GaugeVec
+	cardinalityOverflows    *prometheus.CounterVec
+	asyncTasksInFlight      *prometheus.GaugeVec
+	functionLastSuccess     *prometheus.GaugeVec
+	functionLastRunDuration *prometheus.GaugeVec
+	batchMode               bool
+	gatherer                prometheus.Gatherer
+	callerLimiter           *autometrics.CardinalityLimiter
+	customLabels            *autometrics.LabelAllowlist
+	serviceName             string
+	namingScheme            autometrics.NamingScheme
 	DefBuckets              = autometrics.DefBuckets
 )
 
@@ -19,6 +32,29 @@ const (
 	FunctionCallsDurationName = "function_calls_duration"
 	// FunctionCallsConcurrentName is the name of the prometheus metric for the number of simulateneously active calls to specific functions.
 	FunctionCallsConcurrentName = "function_calls_concurrent"
+	// BuildInfoName is the name of the prometheus metric for the version, commit and branch of the running program.
+	BuildInfoName = "autometrics_build_info"
+	// FunctionCallsTotalName is the name of the prometheus metric for the counter of calls to specific functions,
+	// when using [autometrics.SpecNaming].
+	FunctionCallsTotalName = "function_calls_total"
+	// FunctionCallsDurationSecondsName is the name of the prometheus metric for the duration histogram of calls
+	// to specific functions, when using [autometrics.SpecNaming].
+	FunctionCallsDurationSecondsName = "function_calls_duration_seconds"
+	// BuildInfoSpecName is the name of the prometheus metric for the version, commit and branch of the running program,
+	// when using [autometrics.SpecNaming].
+	BuildInfoSpecName = "build_info"
+	// CardinalityOverflowName is the name of the prometheus metric for the counter of calls recorded with
+	// the [autometrics.OverflowLabelValue] value because of the cardinality limit.
+	CardinalityOverflowName = "autometrics_cardinality_overflow_total"
+	// AsyncTasksInFlightName is the name of the prometheus metric for the number of running async tasks
+	// started by specific functions with Go or Group.
+	AsyncTasksInFlightName = "function_async_tasks_in_flight"
+	// FunctionLastSuccessTimestampName is the name of the prometheus metric for the Unix time of the last
+	// successful call to specific functions, in batch mode.
+	FunctionLastSuccessTimestampName = "function_last_success_timestamp_seconds"
+	// FunctionLastRunDurationName is the name of the prometheus metric for the duration of the last call
+	// to specific functions, in batch mode.
+	FunctionLastRunDurationName = "function_last_run_duration_seconds"
 
 	// FunctionLabel is the prometheus label that describes the function name.
 	//
@@ -33,6 +69,12 @@ const (
 	// CallerLabel is the prometheus label that describes the name of the function that called
 	// the current function.
 	CallerLabel = "caller"
+	// CallerFunctionLabel is the prometheus label that describes the name of the function that called
+	// the current function, when using [autometrics.SpecNaming].
+	CallerFunctionLabel = "caller_function"
+	// CallerModuleLabel is the prometheus label that describes the module name of the function that called
+	// the current function, when using [autometrics.SpecNaming].
+	CallerModuleLabel = "caller_module"
 	// ResultLabel is the prometheus label that describes whether a function call is successful.
 	ResultLabel = "result"
 	// TargetLatencyLabel is the prometheus label that describes the latency to respect to match
@@ -49,6 +91,23 @@ const (
 	TargetSuccessRateLabel = "objective_percentile"
 	// SloLabelName is the prometheus label that describes the name of the Service Level Objective.
 	SloNameLabel = "objective_name"
+	// ObjectiveCopyLabel is the prometheus label that marks the series recorded for the objectives
+	// of a function after its first one, see [autometrics.Context.MoreAlertConfs]. The queries that
+	// count the calls of the functions must only select the series where it is empty. As a metric
+	// has a single set of labels, it is also exported, empty, on the series of every function.
+	ObjectiveCopyLabel = "objective_copy"
+	// VersionLabel is the prometheus label that describes the version of the running program.
+	VersionLabel = "version"
+	// CommitLabel is the prometheus label that describes the commit of the running program.
+	CommitLabel = "commit"
+	// BranchLabel is the prometheus label that describes the VCS branch of the running program.
+	BranchLabel = "branch"
+	// ServiceNameLabel is the prometheus label that describes the name of the service
+	// the metrics come from.
+	ServiceNameLabel = "service_name"
+	// LabelNameLabel is the prometheus label that describes the name of the label whose values
+	// exceeded the cardinality limit.
+	LabelNameLabel = "label"
 )
 
 // Init sets up the metrics required for autometrics' decorated functions and registers
@@ -60,29 +119,204 @@ const (
 // Make sure that all the latency targets you want to use for SLOs are
 // present in the histogramBuckets array, otherwise the alerts will fail
 // to work (they will never trigger.)
-func Init(reg *prometheus.Registry, histogramBuckets []float64) error {
+//
+// The version, commit and branch of the running program are published in the
+// BuildInfoName gauge, see [autometrics.NewInitSettings] for the way they are
+// detected and the options to override them.
+//
+// All the metrics have a ServiceNameLabel, set with the AUTOMETRICS_SERVICE_NAME
+// environment variable or the WithService option.
+//
+// The WithCardinalityLimit option bounds the number of distinct callers recorded for
+// each function, the calls recorded with the overflow value are counted in the
+// CardinalityOverflowName counter.
+//
+// The async tasks started with Go or Group are counted in the AsyncTasksInFlightName
+// gauge of the function that started them.
+//
+// The WithBatchMode option records the FunctionLastSuccessTimestampName and
+// FunctionLastRunDurationName gauges, to send with Push before the program exits.
+//
+// The WithNamingScheme option switches the names of the metrics and of the caller
+// labels to the ones of the Autometrics specification.
+//
+// The WithLabelAllowlist option adds user-defined labels to the function call metrics,
+// the values missing from the allowlist are counted in the CardinalityOverflowName counter.
+func Init(reg *prometheus.Registry, histogramBuckets []float64, opts ...autometrics.InitOption) error {
+	settings := autometrics.NewInitSettings(opts...)
+
+	allowlist, err := autometrics.NewLabelAllowlist(settings.AllowedLabels,
+		FunctionLabel, ModuleLabel, CallerLabel, CallerFunctionLabel, CallerModuleLabel, ResultLabel,
+		TargetLatencyLabel, TargetSuccessRateLabel, SloNameLabel, ObjectiveCopyLabel, ServiceNameLabel, "le")
+	if err != nil {
+		return fmt.Errorf("error initializing the user-defined labels: %w", err)
+	}
+
+	customLabels = allowlist
+	serviceName = settings.ServiceName
+	namingScheme = settings.NamingScheme
+	callerLimiter = autometrics.NewCardinalityLimiter(settings.CardinalityLimit)
+	batchMode = settings.BatchMode
+	autometrics.SetClosureSuffix(settings.ClosureSuffix)
+	autometrics.SetProfileLabels(settings.ProfileLabels)
+	autometrics.SetRuntimeTrace(settings.RuntimeTrace)
+	autometrics.SetLogger(settings.Logger, settings.LogInterval)
+
+	countName, durationName, buildInfoName := FunctionCallsCountName, FunctionCallsDurationName, BuildInfoName
+	callerLabels := []string{CallerLabel}
+	if namingScheme == autometrics.SpecNaming {
+		countName, durationName, buildInfoName = FunctionCallsTotalName, FunctionCallsDurationSecondsName, BuildInfoSpecName
+		callerLabels = []string{CallerFunctionLabel, CallerModuleLabel}
+	}
+
 	functionCallsCount = prometheus.NewCounterVec(prometheus.CounterOpts{
-		Name: FunctionCallsCountName,
-	}, []string{FunctionLabel, ModuleLabel, CallerLabel, ResultLabel, TargetSuccessRateLabel, SloNameLabel})
+		Name: countName,
+	}, withCallerLabels([]string{FunctionLabel, ModuleLabel, ResultLabel, TargetSuccessRateLabel, SloNameLabel, ObjectiveCopyLabel, ServiceNameLabel}, callerLabels))
 
 	functionCallsDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
-		Name:    FunctionCallsDurationName,
+		Name:    durationName,
 		Buckets: histogramBuckets,
-	}, []string{FunctionLabel, ModuleLabel, CallerLabel, TargetLatencyLabel, TargetSuccessRateLabel, SloNameLabel})
+	}, withCallerLabels([]string{FunctionLabel, ModuleLabel, TargetLatencyLabel, TargetSuccessRateLabel, SloNameLabel, ObjectiveCopyLabel, ServiceNameLabel}, callerLabels))
 
 	functionCallsConcurrent = prometheus.NewGaugeVec(prometheus.GaugeOpts{
 		Name: FunctionCallsConcurrentName,
-	}, []string{FunctionLabel, ModuleLabel, CallerLabel})
+	}, withCallerLabels([]string{FunctionLabel, ModuleLabel, ServiceNameLabel}, callerLabels))
+
+	buildInfo = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: buildInfoName,
+	}, []string{VersionLabel, CommitLabel, BranchLabel, ServiceNameLabel})
+
+	cardinalityOverflows = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: CardinalityOverflowName,
+	}, []string{FunctionLabel, ModuleLabel, LabelNameLabel, ServiceNameLabel})
+
+	asyncTasksInFlight = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: AsyncTasksInFlightName,
+	}, []string{FunctionLabel, ModuleLabel, ServiceNameLabel})
+
+	functionLastSuccess = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: FunctionLastSuccessTimestampName,
+	}, []string{FunctionLabel, ModuleLabel, ServiceNameLabel})
+
+	functionLastRunDuration = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: FunctionLastRunDurationName,
+	}, []string{FunctionLabel, ModuleLabel, ResultLabel, ServiceNameLabel})
 
 	if reg != nil {
 		reg.MustRegister(functionCallsCount)
 		reg.MustRegister(functionCallsDuration)
 		reg.MustRegister(functionCallsConcurrent)
+		reg.MustRegister(buildInfo)
+		reg.MustRegister(cardinalityOverflows)
+		reg.MustRegister(asyncTasksInFlight)
+		if batchMode {
+			reg.MustRegister(functionLastSuccess)
+			reg.MustRegister(functionLastRunDuration)
+		}
+		gatherer = reg
 	} else {
 		prometheus.DefaultRegisterer.MustRegister(functionCallsCount)
 		prometheus.DefaultRegisterer.MustRegister(functionCallsDuration)
 		prometheus.DefaultRegisterer.MustRegister(functionCallsConcurrent)
+		prometheus.DefaultRegisterer.MustRegister(buildInfo)
+		prometheus.DefaultRegisterer.MustRegister(cardinalityOverflows)
+		prometheus.DefaultRegisterer.MustRegister(asyncTasksInFlight)
+		if batchMode {
+			prometheus.DefaultRegisterer.MustRegister(functionLastSuccess)
+			prometheus.DefaultRegisterer.MustRegister(functionLastRunDuration)
+		}
+		gatherer = prometheus.DefaultGatherer
+	}
+
+	buildInfo.With(prometheus.Labels{
+		VersionLabel:     settings.BuildInfo.Version,
+		CommitLabel:      settings.BuildInfo.Commit,
+		BranchLabel:      settings.BuildInfo.Branch,
+		ServiceNameLabel: serviceName,
+	}).Set(1)
+
+	for _, function := range autometrics.RegisteredFunctions() {
+		initializeFunctionMetrics(function)
 	}
 
 	return nil
 }
+
+// RegisterFunction announces an instrumented function, so that its metrics
+// exist with a zero value before the function gets called for the first time.
+//
+// The generator adds calls to RegisterFunction in an init function for all the
+// instrumented functions of a file. The zero-valued series are created in Init,
+// or immediately if Init has already been called.
+func RegisterFunction(funcName, moduleName string, ctx *autometrics.Context) {
+	function := autometrics.RegisteredFunction{
+		FuncName:   funcName,
+		ModuleName: moduleName,
+		Context:    *ctx,
+	}
+
+	autometrics.RegisterFunction(function)
+
+	if functionCallsCount != nil {
+		initializeFunctionMetrics(function)
+	}
+}
+
+// initializeFunctionMetrics creates the zero-valued series of a registered
+// function, for all the possible results.
+//
+// The caller of the function is not known before it is called, so the caller label is empty.
+func initializeFunctionMetrics(function autometrics.RegisteredFunction) {
+	sloName, latencyTarget, latencyObjective, successObjective := sloLabels(function.Context.AlertConf)
+
+	for _, result := range []string{"ok", "error"} {
+		labels := functionLabels(function.FuncName, function.ModuleName, "", "", nil)
+		labels[ResultLabel] = result
+		labels[TargetSuccessRateLabel] = successObjective
+		labels[SloNameLabel] = sloName
+		labels[ObjectiveCopyLabel] = ""
+		functionCallsCount.With(labels).Add(0)
+	}
+
+	durationLabels := functionLabels(function.FuncName, function.ModuleName, "", "", nil)
+	durationLabels[TargetLatencyLabel] = latencyTarget
+	durationLabels[TargetSuccessRateLabel] = latencyObjective
+	durationLabels[SloNameLabel] = sloName
+	durationLabels[ObjectiveCopyLabel] = ""
+	functionCallsDuration.With(durationLabels)
+
+	for i := range function.Context.MoreAlertConfs {
+		alertConf := &function.Context.MoreAlertConfs[i]
+		sloName, latencyTarget, latencyObjective, successObjective := sloLabels(alertConf)
+
+		if alertConf.Success != nil {
+			for _, result := range []string{"ok", "error"} {
+				labels := functionLabels(function.FuncName, function.ModuleName, "", "", nil)
+				labels[ResultLabel] = result
+				labels[TargetSuccessRateLabel] = successObjective
+				labels[SloNameLabel] = sloName
+				labels[ObjectiveCopyLabel] = autometrics.ObjectiveCopyValue
+				functionCallsCount.With(labels).Add(0)
+			}
+		}
+
+		if alertConf.Latency != nil {
+			durationLabels := functionLabels(function.FuncName, function.ModuleName, "", "", nil)
+			durationLabels[TargetLatencyLabel] = latencyTarget
+			durationLabels[TargetSuccessRateLabel] = latencyObjective
+			durationLabels[SloNameLabel] = sloName
+			durationLabels[ObjectiveCopyLabel] = autometrics.ObjectiveCopyValue
+			functionCallsDuration.With(durationLabels)
+		}
+	}
+
+	if function.Context.TrackConcurrentCalls {
+		functionCallsConcurrent.With(functionLabels(function.FuncName, function.ModuleName, "", "", nil)).Add(0)
+	}
+}
+
+// withCallerLabels returns the label names with the caller label names and the names of the
+// user-defined labels appended.
+func withCallerLabels(labels, callerLabels []string) []string {
+	return append(append(labels, callerLabels...), customLabels.Names()...)
+}
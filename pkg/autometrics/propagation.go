@@ -0,0 +1,224 @@
+package autometrics
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+const (
+	// BaggageHeader is the header of the W3C baggage.
+	BaggageHeader = "baggage"
+	// FunctionBaggageKey is the baggage key holding the name of the instrumented
+	// function that made a request.
+	FunctionBaggageKey = "autometrics.function"
+	// ModuleBaggageKey is the baggage key holding the module of the instrumented
+	// function that made a request.
+	ModuleBaggageKey = "autometrics.module"
+
+	functionHeaderKey = "function"
+	moduleHeaderKey   = "module"
+)
+
+// Carrier is the metadata of a request that carries the caller across services.
+type Carrier interface {
+	// Get returns the value of the key, or an empty string.
+	Get(key string) string
+	// Values returns all the values of the key, like the several baggage headers
+	// of a request.
+	Values(key string) []string
+	// Set replaces all the values of the key.
+	Set(key, value string)
+}
+
+// HeaderCarrier adapts the headers of an HTTP request to the Carrier interface.
+type HeaderCarrier http.Header
+
+func (c HeaderCarrier) Get(key string) string {
+	return http.Header(c).Get(key)
+}
+
+func (c HeaderCarrier) Values(key string) []string {
+	return http.Header(c).Values(key)
+}
+
+func (c HeaderCarrier) Set(key, value string) {
+	http.Header(c).Set(key, value)
+}
+
+// MetadataCarrier adapts the metadata of a gRPC call (metadata.MD) to the Carrier interface.
+type MetadataCarrier map[string][]string
+
+func (c MetadataCarrier) Get(key string) string {
+	values := c[strings.ToLower(key)]
+	if len(values) == 0 {
+		return ""
+	}
+
+	return values[0]
+}
+
+func (c MetadataCarrier) Values(key string) []string {
+	return c[strings.ToLower(key)]
+}
+
+func (c MetadataCarrier) Set(key, value string) {
+	c[strings.ToLower(key)] = []string{value}
+}
+
+// Propagator sends the instrumented function that makes a request to the service
+// that receives it, so that the metrics of the downstream service can use it as
+// the caller.
+//
+// The caller is read from and written to the context.Context, see [ContextWithCaller]
+// and the -context-caller mode of the generator.
+//
+// Only the function and module names cross the services: the downstream service records
+// the upstream function as a caller like any function of its own process, without the
+// name of the upstream service. Give the services distinct module names, or filter on the
+// caller values of the upstream functions, to tell the calls across services apart.
+type Propagator struct {
+	header string
+}
+
+// NewBaggagePropagator builds a Propagator that uses the W3C baggage header, keeping
+// the other members of the baggage.
+func NewBaggagePropagator() Propagator {
+	return Propagator{}
+}
+
+// NewHeaderPropagator builds a Propagator that uses a dedicated header, holding the
+// function and module as a URL encoded query string.
+func NewHeaderPropagator(header string) Propagator {
+	return Propagator{header: header}
+}
+
+// Inject writes the nearest instrumented function recorded in ctx to the carrier.
+//
+// Nothing is written if there is no instrumented function in ctx.
+func (p Propagator) Inject(ctx context.Context, carrier Carrier) {
+	funcName, moduleName, ok := CallerFromContext(ctx)
+	if !ok {
+		return
+	}
+
+	if p.header != "" {
+		carrier.Set(p.header, url.Values{
+			functionHeaderKey: {funcName},
+			moduleHeaderKey:   {moduleName},
+		}.Encode())
+		return
+	}
+
+	members := []string{
+		FunctionBaggageKey + "=" + url.PathEscape(funcName),
+		ModuleBaggageKey + "=" + url.PathEscape(moduleName),
+	}
+
+	for _, member := range baggageMembers(carrier) {
+		key, _, _ := strings.Cut(member, "=")
+		if key = strings.TrimSpace(key); key != "" && key != FunctionBaggageKey && key != ModuleBaggageKey {
+			members = append(members, strings.TrimSpace(member))
+		}
+	}
+
+	carrier.Set(BaggageHeader, strings.Join(members, ","))
+}
+
+// Extract returns a copy of ctx that records the instrumented function read from the
+// carrier as the nearest instrumented ancestor, so that the instrumented functions
+// called with this context use it as their caller.
+//
+// ctx is returned unchanged if the carrier has no instrumented function.
+func (p Propagator) Extract(ctx context.Context, carrier Carrier) context.Context {
+	var funcName, moduleName string
+
+	if p.header != "" {
+		values, err := url.ParseQuery(carrier.Get(p.header))
+		if err != nil {
+			return ctx
+		}
+
+		funcName, moduleName = values.Get(functionHeaderKey), values.Get(moduleHeaderKey)
+	} else {
+		for _, member := range baggageMembers(carrier) {
+			key, value, ok := parseBaggageMember(member)
+			if !ok {
+				continue
+			}
+
+			switch key {
+			case FunctionBaggageKey:
+				funcName = value
+			case ModuleBaggageKey:
+				moduleName = value
+			}
+		}
+	}
+
+	if funcName == "" {
+		return ctx
+	}
+
+	return ContextWithCaller(ctx, funcName, moduleName)
+}
+
+// baggageMembers returns the list members of all the baggage headers of the carrier,
+// as the W3C baggage can be split across several headers.
+func baggageMembers(carrier Carrier) []string {
+	var members []string
+	for _, header := range carrier.Values(BaggageHeader) {
+		members = append(members, strings.Split(header, ",")...)
+	}
+
+	return members
+}
+
+// parseBaggageMember returns the key and the decoded value of a W3C baggage list member,
+// ignoring its properties.
+func parseBaggageMember(member string) (key, value string, ok bool) {
+	keyValue, _, _ := strings.Cut(member, ";")
+
+	key, encodedValue, found := strings.Cut(keyValue, "=")
+	if !found {
+		return "", "", false
+	}
+
+	value, err := url.PathUnescape(strings.TrimSpace(encodedValue))
+	if err != nil {
+		return "", "", false
+	}
+
+	return strings.TrimSpace(key), value, true
+}
+
+// Handler wraps an HTTP handler to extract the caller from the incoming requests.
+func (p Propagator) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(w, r.WithContext(p.Extract(r.Context(), HeaderCarrier(r.Header))))
+	})
+}
+
+// Transport wraps an HTTP round tripper to inject the caller in the outgoing requests.
+//
+// A nil base uses http.DefaultTransport.
+func (p Propagator) Transport(base http.RoundTripper) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+
+	return roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		// A RoundTripper must not modify the request.
+		r = r.Clone(r.Context())
+		p.Inject(r.Context(), HeaderCarrier(r.Header))
+
+		return base.RoundTrip(r)
+	})
+}
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (fn roundTripperFunc) RoundTrip(r *http.Request) (*http.Response, error) {
+	return fn(r)
+}
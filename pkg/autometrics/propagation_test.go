@@ -0,0 +1,175 @@
+package autometrics
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPropagatorInject(t *testing.T) {
+	testCases := []struct {
+		name       string
+		propagator Propagator
+		funcName   string
+		moduleName string
+		headers    http.Header
+		want       http.Header
+	}{
+		{
+			name:       "baggage",
+			propagator: NewBaggagePropagator(),
+			funcName:   "handler",
+			moduleName: "main",
+			headers:    http.Header{},
+			want:       http.Header{"Baggage": {"autometrics.function=handler,autometrics.module=main"}},
+		},
+		{
+			name:       "percent-encoding",
+			propagator: NewBaggagePropagator(),
+			funcName:   "Server.Serve func,1;x",
+			moduleName: "my module",
+			headers:    http.Header{},
+			want:       http.Header{"Baggage": {"autometrics.function=Server.Serve%20func%2C1%3Bx,autometrics.module=my%20module"}},
+		},
+		{
+			name:       "foreign members of several headers",
+			propagator: NewBaggagePropagator(),
+			funcName:   "handler",
+			moduleName: "main",
+			headers: http.Header{"Baggage": {
+				"userId=alice",
+				"tenant=acme;ttl=5, autometrics.function=previous",
+			}},
+			want: http.Header{"Baggage": {"autometrics.function=handler,autometrics.module=main,userId=alice,tenant=acme;ttl=5"}},
+		},
+		{
+			name:       "dedicated header",
+			propagator: NewHeaderPropagator("X-Autometrics-Caller"),
+			funcName:   "handler",
+			moduleName: "main",
+			headers:    http.Header{"Baggage": {"userId=alice"}},
+			want: http.Header{
+				"Baggage":              {"userId=alice"},
+				"X-Autometrics-Caller": {"function=handler&module=main"},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := ContextWithCaller(context.Background(), tc.funcName, tc.moduleName)
+			tc.propagator.Inject(ctx, HeaderCarrier(tc.headers))
+
+			assert.Equal(t, tc.want, tc.headers, "The injected headers are not as expected.")
+
+			funcName, moduleName, ok := CallerFromContext(tc.propagator.Extract(context.Background(), HeaderCarrier(tc.headers)))
+			assert.True(t, ok, "The injected caller must be extracted.")
+			assert.Equal(t, tc.funcName, funcName)
+			assert.Equal(t, tc.moduleName, moduleName)
+		})
+	}
+
+	headers := http.Header{"Baggage": {"userId=alice"}}
+	NewBaggagePropagator().Inject(context.Background(), HeaderCarrier(headers))
+	assert.Equal(t, http.Header{"Baggage": {"userId=alice"}}, headers, "Nothing must be injected without a caller.")
+}
+
+func TestPropagatorExtract(t *testing.T) {
+	testCases := []struct {
+		name         string
+		propagator   Propagator
+		carrier      Carrier
+		wantOk       bool
+		wantFunction string
+		wantModule   string
+	}{
+		{
+			name:         "properties",
+			propagator:   NewBaggagePropagator(),
+			carrier:      HeaderCarrier{"Baggage": {"autometrics.function=handler;origin=web, autometrics.module=main;ttl=5"}},
+			wantOk:       true,
+			wantFunction: "handler",
+			wantModule:   "main",
+		},
+		{
+			name:       "several headers",
+			propagator: NewBaggagePropagator(),
+			carrier: HeaderCarrier{"Baggage": {
+				"userId=alice,autometrics.function=handler",
+				"autometrics.module=main",
+			}},
+			wantOk:       true,
+			wantFunction: "handler",
+			wantModule:   "main",
+		},
+		{
+			name:         "gRPC metadata",
+			propagator:   NewBaggagePropagator(),
+			carrier:      MetadataCarrier{"baggage": {"autometrics.function=Server.Serve", "autometrics.module=api"}},
+			wantOk:       true,
+			wantFunction: "Server.Serve",
+			wantModule:   "api",
+		},
+		{
+			name:       "invalid encoding",
+			propagator: NewBaggagePropagator(),
+			carrier:    HeaderCarrier{"Baggage": {"autometrics.function=%zz"}},
+			wantOk:     false,
+		},
+		{
+			name:       "no caller",
+			propagator: NewBaggagePropagator(),
+			carrier:    HeaderCarrier{"Baggage": {"userId=alice"}},
+			wantOk:     false,
+		},
+		{
+			name:       "dedicated header without caller",
+			propagator: NewHeaderPropagator("X-Autometrics-Caller"),
+			carrier:    HeaderCarrier{"Baggage": {"autometrics.function=handler"}},
+			wantOk:     false,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			funcName, moduleName, ok := CallerFromContext(tc.propagator.Extract(context.Background(), tc.carrier))
+			assert.Equal(t, tc.wantOk, ok, "The presence of the caller is not as expected.")
+			assert.Equal(t, tc.wantFunction, funcName)
+			assert.Equal(t, tc.wantModule, moduleName)
+		})
+	}
+}
+
+func TestPropagatorHTTP(t *testing.T) {
+	propagator := NewBaggagePropagator()
+
+	var funcName, moduleName, baggage string
+	server := httptest.NewServer(propagator.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		funcName, moduleName, _ = CallerFromContext(r.Context())
+		baggage = r.Header.Get(BaggageHeader)
+	})))
+	defer server.Close()
+
+	ctx := ContextWithCaller(context.Background(), "checkout", "shop")
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, server.URL, nil)
+	if err != nil {
+		t.Fatalf("error creating the request: %s", err)
+	}
+	request.Header.Set(BaggageHeader, "userId=alice")
+
+	client := http.Client{Transport: propagator.Transport(nil)}
+	response, err := client.Do(request)
+	if err != nil {
+		t.Fatalf("error sending the request: %s", err)
+	}
+	response.Body.Close()
+
+	assert.Equal(t, "checkout", funcName, "The handler must see the upstream function as the caller.")
+	assert.Equal(t, "shop", moduleName)
+	assert.Equal(t, "autometrics.function=checkout,autometrics.module=shop,userId=alice", baggage,
+		"The foreign baggage members must be kept.")
+	assert.Equal(t, "userId=alice", request.Header.Get(BaggageHeader), "The transport must not modify the request.")
+}
@@ -0,0 +1,44 @@
+package autometrics
+
+import (
+	"sync"
+)
+
+// RegisteredFunction is an instrumented function that has been announced
+// before its first call, so that its metrics can exist before it is ever called.
+type RegisteredFunction struct {
+	// FuncName is the name of the instrumented function.
+	FuncName string
+	// ModuleName is the name of the module of the instrumented function.
+	ModuleName string
+	// Context is the instrumentation context the function is using.
+	Context Context
+}
+
+var (
+	registeredFunctionsMutex sync.Mutex
+	registeredFunctions      []RegisteredFunction
+)
+
+// RegisterFunction adds a function to the list of instrumented functions.
+//
+// The implementations call this function from their own RegisterFunction, which
+// is called by the init function the generator adds to every file with
+// instrumented functions.
+func RegisterFunction(function RegisteredFunction) {
+	registeredFunctionsMutex.Lock()
+	defer registeredFunctionsMutex.Unlock()
+
+	registeredFunctions = append(registeredFunctions, function)
+}
+
+// RegisteredFunctions returns a copy of the list of all the functions registered so far.
+func RegisteredFunctions() []RegisteredFunction {
+	registeredFunctionsMutex.Lock()
+	defer registeredFunctionsMutex.Unlock()
+
+	ret := make([]RegisteredFunction, len(registeredFunctions))
+	copy(ret, registeredFunctions)
+
+	return ret
+}
@@ -0,0 +1,73 @@
+package autometrics
+
+import (
+	"context"
+	"runtime/trace"
+	"sync/atomic"
+)
+
+// runtimeTrace is 1 when all the functions open runtime/trace regions, see SetRuntimeTrace.
+var runtimeTrace int32
+
+// SetRuntimeTrace sets whether all the instrumented functions open a runtime/trace region
+// or task for their calls, instead of the functions with [Context.TrackRuntimeTrace] only.
+//
+// The Init function of the implementations calls it with the value of the WithGlobalRuntimeTrace option.
+func SetRuntimeTrace(enabled bool) {
+	var value int32
+	if enabled {
+		value = 1
+	}
+
+	atomic.StoreInt32(&runtimeTrace, value)
+}
+
+// StartRuntimeTrace opens a runtime/trace region named after the function, if the function
+// tracks them, so that the calls show up in the execution traces of `go tool trace`.
+//
+// When the function receives a context, given with ContextPointer or RequestPointer, a task is
+// created instead, and stored in the context.Context or *http.Request variable of the function
+// so that the regions and tasks of the nested calls belong to it.
+// PreInstrument calls this method after PropagateCaller.
+func (c *Context) StartRuntimeTrace() {
+	if !c.TrackRuntimeTrace && atomic.LoadInt32(&runtimeTrace) != 1 {
+		return
+	}
+
+	if c.Context == nil {
+		c.Context = context.Background()
+	}
+
+	switch {
+	case c.ContextPointer != nil:
+		c.Context, c.runtimeTraceTask = trace.NewTask(c.Context, c.CallInfo.FuncName)
+		*c.ContextPointer = c.Context
+	case c.RequestPointer != nil && *c.RequestPointer != nil:
+		c.Context, c.runtimeTraceTask = trace.NewTask(c.Context, c.CallInfo.FuncName)
+		*c.RequestPointer = (*c.RequestPointer).WithContext(c.Context)
+	default:
+		c.runtimeTraceRegion = trace.StartRegion(c.Context, c.CallInfo.FuncName)
+	}
+}
+
+// EndRuntimeTrace closes the region or ends the task opened by StartRuntimeTrace.
+// Instrument calls this method when the call ends.
+func (c *Context) EndRuntimeTrace() {
+	if c.runtimeTraceRegion != nil {
+		c.runtimeTraceRegion.End()
+		c.runtimeTraceRegion = nil
+	}
+
+	if c.runtimeTraceTask != nil {
+		c.runtimeTraceTask.End()
+		c.runtimeTraceTask = nil
+	}
+}
+
+// Discard ends the runtime/trace region or task and restores the pprof labels of a call
+// that PreInstrument started but that is not recorded, like an operation a driver skips.
+// Instrument must not be called for this call.
+func (c *Context) Discard() {
+	c.EndRuntimeTrace()
+	c.RestoreProfileLabels()
+}
@@ -0,0 +1,71 @@
+package autometrics
+
+import (
+	"bytes"
+	"context"
+	"runtime/pprof"
+	"runtime/trace"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRuntimeTrace(t *testing.T) {
+	var buffer bytes.Buffer
+	if err := trace.Start(&buffer); err != nil {
+		t.Skipf("the execution tracer is not available: %s", err)
+	}
+
+	ctx := NewContext()
+	ctx.TrackRuntimeTrace = true
+	ctx.CallInfo = CallInfo{FuncName: "regionFunction", ModuleName: "api"}
+	ctx.StartRuntimeTrace()
+	assert.NotNil(t, ctx.runtimeTraceRegion, "A function without a context must open a region.")
+	assert.Nil(t, ctx.runtimeTraceTask)
+	ctx.EndRuntimeTrace()
+	assert.Nil(t, ctx.runtimeTraceRegion)
+
+	goCtx := context.Background()
+	ctx = NewContext()
+	ctx.TrackRuntimeTrace = true
+	ctx.ContextPointer = &goCtx
+	ctx.CallInfo = CallInfo{FuncName: "taskFunction", ModuleName: "api"}
+	ctx.StartRuntimeTrace()
+	assert.NotNil(t, ctx.runtimeTraceTask, "A function with a context must create a task.")
+	assert.Nil(t, ctx.runtimeTraceRegion)
+	assert.NotEqual(t, context.Background(), goCtx, "The task must be stored in the context of the function.")
+	ctx.EndRuntimeTrace()
+	assert.Nil(t, ctx.runtimeTraceTask)
+
+	trace.Stop()
+
+	assert.True(t, bytes.Contains(buffer.Bytes(), []byte("regionFunction")), "The region must be named after the function.")
+	assert.True(t, bytes.Contains(buffer.Bytes(), []byte("taskFunction")), "The task must be named after the function.")
+}
+
+func TestDiscard(t *testing.T) {
+	var buffer bytes.Buffer
+	if err := trace.Start(&buffer); err != nil {
+		t.Skipf("the execution tracer is not available: %s", err)
+	}
+	defer trace.Stop()
+
+	goCtx := pprof.WithLabels(context.Background(), pprof.Labels("team", "checkout"))
+	pprof.SetGoroutineLabels(goCtx)
+	defer pprof.SetGoroutineLabels(context.Background())
+	callerLabels := goroutineLabels(t)
+
+	ctx := NewContext()
+	ctx.Context = goCtx
+	ctx.ContextPointer = &goCtx
+	ctx.TrackRuntimeTrace = true
+	ctx.TrackProfileLabels = true
+	ctx.CallInfo = CallInfo{FuncName: "skipped", ModuleName: "sql"}
+	ctx.ApplyProfileLabels()
+	ctx.StartRuntimeTrace()
+
+	ctx.Discard()
+	assert.Nil(t, ctx.runtimeTraceTask, "The task must be ended.")
+	assert.False(t, ctx.profileLabelsApplied)
+	assert.Equal(t, callerLabels, goroutineLabels(t), "The labels of the caller must be restored.")
+}
@@ -0,0 +1,417 @@
+// Package sql provides a database/sql driver wrapper that collects the autometrics
+// metrics of the database operations, without the code generator.
+//
+// The function label of the metrics is the operation ("Exec", "Query", "Begin",
+// "Commit" or "Rollback"), or the query name given with WithQueryName.
+package sql // import "github.com/autometrics-dev/autometrics-go/pkg/autometrics/sql"
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+
+	"github.com/autometrics-dev/autometrics-go/pkg/autometrics"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	// DefaultModuleName is the module label of the metrics collected by the wrapper.
+	DefaultModuleName = "sql"
+
+	// ExecOperation is the function label of the statements that do not return rows.
+	ExecOperation = "Exec"
+	// QueryOperation is the function label of the queries that return rows.
+	QueryOperation = "Query"
+	// BeginOperation is the function label of the start of a transaction.
+	BeginOperation = "Begin"
+	// CommitOperation is the function label of the commit of a transaction.
+	CommitOperation = "Commit"
+	// RollbackOperation is the function label of the rollback of a transaction.
+	RollbackOperation = "Rollback"
+)
+
+var errDriverPanicked = errors.New("the driver panicked")
+
+// Option is an option for the wrapper.
+type Option func(*settings)
+
+type settings struct {
+	backend     autometrics.Backend
+	moduleName  string
+	contextOpts []autometrics.Option
+}
+
+// WithModuleName sets the module label of the metrics, DefaultModuleName by default.
+func WithModuleName(name string) Option {
+	return func(s *settings) {
+		s.moduleName = name
+	}
+}
+
+// WithContextOptions adds options of the implementation to the instrumentation context
+// of all the operations, like the SLO options.
+func WithContextOptions(opts ...autometrics.Option) Option {
+	return func(s *settings) {
+		s.contextOpts = append(s.contextOpts, opts...)
+	}
+}
+
+type queryNameContextKey struct{}
+
+// WithQueryName returns a copy of ctx that names the queries made with it.
+//
+// The statements and queries made with this context use the name as function label,
+// and the transactions started with it use the name followed by the operation, like
+// "transfer.Commit".
+func WithQueryName(ctx context.Context, name string) context.Context {
+	return context.WithValue(ctx, queryNameContextKey{}, name)
+}
+
+// Wrap returns a driver that records the operations of d with the backend of an
+// implementation, like prometheus.Backend.
+//
+// Register the returned driver with sql.Register, and open the databases with
+// the name it is registered with.
+//
+// The caller label is the instrumented function that issued the operation, when
+// the context of the operation holds it (see the -context-caller mode of the generator).
+// The concurrent calls are not tracked, and the duration of a query does not include
+// the iteration over its rows.
+func Wrap(d driver.Driver, backend autometrics.Backend, opts ...Option) driver.Driver {
+	s := &settings{
+		backend:    backend,
+		moduleName: DefaultModuleName,
+	}
+
+	for _, o := range opts {
+		o(s)
+	}
+
+	return &wrappedDriver{driver: d, settings: s}
+}
+
+// start starts the instrumentation of an operation.
+//
+// The concurrent calls are not tracked, so that an operation the driver skips with
+// driver.ErrSkip can be dropped without calling Instrument, see discard.
+func (s *settings) start(ctx context.Context, operation string, isTransaction bool) *autometrics.Context {
+	funcName := operation
+	if name, ok := ctx.Value(queryNameContextKey{}).(string); ok && name != "" {
+		funcName = name
+		if isTransaction {
+			funcName = name + "." + operation
+		}
+	}
+
+	_, _, hasAncestor := autometrics.CallerFromContext(ctx)
+
+	// The instrumentation uses a copy of the context, the operation must not become
+	// the ancestor of the next operations made with ctx.
+	instrumentedCtx := ctx
+	opts := append(append([]autometrics.Option{}, s.contextOpts...), optionFunc(func(amCtx *autometrics.Context) {
+		amCtx.CallInfo.FuncName = funcName
+		amCtx.CallInfo.ModuleName = s.moduleName
+		amCtx.TrackCallerName = hasAncestor
+		amCtx.TrackConcurrentCalls = false
+		amCtx.ContextPointer = &instrumentedCtx
+	}))
+
+	return s.backend.PreInstrument(s.backend.NewContext(opts...))
+}
+
+// record runs the operation and records it, unless the driver skipped it.
+func (s *settings) record(ctx context.Context, operation string, isTransaction bool, call func() error) (err error) {
+	amCtx := s.start(ctx, operation, isTransaction)
+
+	// The error stays set if the driver panics.
+	err = errDriverPanicked
+	defer func() {
+		if err == driver.ErrSkip {
+			discard(amCtx)
+			return
+		}
+		reported := err
+		s.backend.Instrument(amCtx, &reported)
+	}()
+
+	err = call()
+
+	return err
+}
+
+// discard ends the runtime trace, the span and the pprof labels of an operation
+// that the driver skipped, without recording it.
+func discard(amCtx *autometrics.Context) {
+	amCtx.Discard()
+	if amCtx.TrackSpan {
+		trace.SpanFromContext(amCtx.Context).End()
+	}
+}
+
+type optionFunc func(*autometrics.Context)
+
+func (fn optionFunc) Apply(ctx *autometrics.Context) {
+	fn(ctx)
+}
+
+type wrappedDriver struct {
+	driver   driver.Driver
+	settings *settings
+}
+
+func (d *wrappedDriver) Open(name string) (driver.Conn, error) {
+	conn, err := d.driver.Open(name)
+	if err != nil {
+		return nil, err
+	}
+
+	return &wrappedConn{conn: conn, settings: d.settings}, nil
+}
+
+// wrappedConn implements the optional interfaces of driver.Conn, falling back to
+// the behaviour of database/sql when the wrapped connection does not implement them.
+type wrappedConn struct {
+	conn     driver.Conn
+	settings *settings
+}
+
+func (c *wrappedConn) Prepare(query string) (driver.Stmt, error) {
+	return c.PrepareContext(context.Background(), query)
+}
+
+func (c *wrappedConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	var stmt driver.Stmt
+	var err error
+
+	if preparer, ok := c.conn.(driver.ConnPrepareContext); ok {
+		stmt, err = preparer.PrepareContext(ctx, query)
+	} else {
+		stmt, err = c.conn.Prepare(query)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &wrappedStmt{stmt: stmt, settings: c.settings}, nil
+}
+
+func (c *wrappedConn) Close() error {
+	return c.conn.Close()
+}
+
+func (c *wrappedConn) Begin() (driver.Tx, error) {
+	return c.BeginTx(context.Background(), driver.TxOptions{})
+}
+
+func (c *wrappedConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	var tx driver.Tx
+
+	err := c.settings.record(ctx, BeginOperation, true, func() (err error) {
+		if beginner, ok := c.conn.(driver.ConnBeginTx); ok {
+			tx, err = beginner.BeginTx(ctx, opts)
+			return err
+		}
+
+		if opts.Isolation != driver.IsolationLevel(0) || opts.ReadOnly {
+			return errors.New("sql: driver does not support non-default isolation level or read-only transactions")
+		}
+
+		//nolint:staticcheck // Begin is the fallback for the drivers without BeginTx.
+		tx, err = c.conn.Begin()
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &wrappedTx{tx: tx, ctx: ctx, settings: c.settings}, nil
+}
+
+func (c *wrappedConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	var result driver.Result
+
+	err := c.settings.record(ctx, ExecOperation, false, func() (err error) {
+		if execer, ok := c.conn.(driver.ExecerContext); ok {
+			result, err = execer.ExecContext(ctx, query, args)
+			return err
+		}
+
+		//nolint:staticcheck // Execer is the fallback for the drivers without ExecerContext.
+		if execer, ok := c.conn.(driver.Execer); ok {
+			values, err := namedValuesToValues(args)
+			if err != nil {
+				return err
+			}
+			result, err = execer.Exec(query, values)
+			return err
+		}
+
+		return driver.ErrSkip
+	})
+
+	return result, err
+}
+
+func (c *wrappedConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	var rows driver.Rows
+
+	err := c.settings.record(ctx, QueryOperation, false, func() (err error) {
+		if queryer, ok := c.conn.(driver.QueryerContext); ok {
+			rows, err = queryer.QueryContext(ctx, query, args)
+			return err
+		}
+
+		//nolint:staticcheck // Queryer is the fallback for the drivers without QueryerContext.
+		if queryer, ok := c.conn.(driver.Queryer); ok {
+			values, err := namedValuesToValues(args)
+			if err != nil {
+				return err
+			}
+			rows, err = queryer.Query(query, values)
+			return err
+		}
+
+		return driver.ErrSkip
+	})
+
+	return rows, err
+}
+
+func (c *wrappedConn) Ping(ctx context.Context) error {
+	if pinger, ok := c.conn.(driver.Pinger); ok {
+		return pinger.Ping(ctx)
+	}
+
+	return nil
+}
+
+func (c *wrappedConn) ResetSession(ctx context.Context) error {
+	if resetter, ok := c.conn.(driver.SessionResetter); ok {
+		return resetter.ResetSession(ctx)
+	}
+
+	return nil
+}
+
+func (c *wrappedConn) IsValid() bool {
+	if validator, ok := c.conn.(driver.Validator); ok {
+		return validator.IsValid()
+	}
+
+	return true
+}
+
+func (c *wrappedConn) CheckNamedValue(value *driver.NamedValue) error {
+	if checker, ok := c.conn.(driver.NamedValueChecker); ok {
+		return checker.CheckNamedValue(value)
+	}
+
+	return driver.ErrSkip
+}
+
+type wrappedStmt struct {
+	stmt     driver.Stmt
+	settings *settings
+}
+
+func (s *wrappedStmt) Close() error {
+	return s.stmt.Close()
+}
+
+func (s *wrappedStmt) NumInput() int {
+	return s.stmt.NumInput()
+}
+
+func (s *wrappedStmt) Exec(args []driver.Value) (driver.Result, error) {
+	return s.ExecContext(context.Background(), valuesToNamedValues(args))
+}
+
+func (s *wrappedStmt) Query(args []driver.Value) (driver.Rows, error) {
+	return s.QueryContext(context.Background(), valuesToNamedValues(args))
+}
+
+func (s *wrappedStmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+	var result driver.Result
+
+	err := s.settings.record(ctx, ExecOperation, false, func() (err error) {
+		if execer, ok := s.stmt.(driver.StmtExecContext); ok {
+			result, err = execer.ExecContext(ctx, args)
+			return err
+		}
+
+		values, err := namedValuesToValues(args)
+		if err != nil {
+			return err
+		}
+		//nolint:staticcheck // Exec is the fallback for the drivers without StmtExecContext.
+		result, err = s.stmt.Exec(values)
+		return err
+	})
+
+	return result, err
+}
+
+func (s *wrappedStmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	var rows driver.Rows
+
+	err := s.settings.record(ctx, QueryOperation, false, func() (err error) {
+		if queryer, ok := s.stmt.(driver.StmtQueryContext); ok {
+			rows, err = queryer.QueryContext(ctx, args)
+			return err
+		}
+
+		values, err := namedValuesToValues(args)
+		if err != nil {
+			return err
+		}
+		//nolint:staticcheck // Query is the fallback for the drivers without StmtQueryContext.
+		rows, err = s.stmt.Query(values)
+		return err
+	})
+
+	return rows, err
+}
+
+func (s *wrappedStmt) CheckNamedValue(value *driver.NamedValue) error {
+	if checker, ok := s.stmt.(driver.NamedValueChecker); ok {
+		return checker.CheckNamedValue(value)
+	}
+
+	return driver.ErrSkip
+}
+
+// wrappedTx records the end of a transaction, with the context it was started with.
+type wrappedTx struct {
+	tx       driver.Tx
+	ctx      context.Context
+	settings *settings
+}
+
+func (t *wrappedTx) Commit() error {
+	return t.settings.record(t.ctx, CommitOperation, true, t.tx.Commit)
+}
+
+func (t *wrappedTx) Rollback() error {
+	return t.settings.record(t.ctx, RollbackOperation, true, t.tx.Rollback)
+}
+
+func namedValuesToValues(args []driver.NamedValue) ([]driver.Value, error) {
+	values := make([]driver.Value, len(args))
+	for i, arg := range args {
+		if arg.Name != "" {
+			return nil, errors.New("sql: driver does not support the use of Named Parameters")
+		}
+		values[i] = arg.Value
+	}
+
+	return values, nil
+}
+
+func valuesToNamedValues(args []driver.Value) []driver.NamedValue {
+	namedValues := make([]driver.NamedValue, len(args))
+	for i, arg := range args {
+		namedValues[i] = driver.NamedValue{Ordinal: i + 1, Value: arg}
+	}
+
+	return namedValues
+}
@@ -0,0 +1,219 @@
+package sql
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"runtime/pprof"
+	"runtime/trace"
+	"strings"
+	"testing"
+
+	"github.com/autometrics-dev/autometrics-go/pkg/autometrics"
+	"github.com/autometrics-dev/autometrics-go/pkg/autometrics/autometricstest"
+	"github.com/stretchr/testify/assert"
+)
+
+var errFakeQuery = errors.New("fake query failed")
+
+// fakeDriver is an in-memory driver whose queries return a single row,
+// except the "FAIL" query that returns an error and the "SKIP" statement
+// that the driver skips.
+type fakeDriver struct{}
+
+func (fakeDriver) Open(string) (driver.Conn, error) {
+	return fakeConn{}, nil
+}
+
+type fakeConn struct{}
+
+func (fakeConn) Prepare(string) (driver.Stmt, error) {
+	return nil, errors.New("fake driver does not prepare statements")
+}
+
+func (fakeConn) Close() error {
+	return nil
+}
+
+func (fakeConn) Begin() (driver.Tx, error) {
+	return fakeTx{}, nil
+}
+
+func (fakeConn) ExecContext(_ context.Context, query string, _ []driver.NamedValue) (driver.Result, error) {
+	if query == "FAIL" {
+		return nil, errFakeQuery
+	}
+	if query == "SKIP" {
+		// database/sql falls back to a prepared statement, that the fake driver fails.
+		return nil, driver.ErrSkip
+	}
+
+	return driver.RowsAffected(1), nil
+}
+
+func (fakeConn) QueryContext(_ context.Context, query string, _ []driver.NamedValue) (driver.Rows, error) {
+	if query == "FAIL" {
+		return nil, errFakeQuery
+	}
+
+	return &fakeRows{}, nil
+}
+
+type fakeTx struct{}
+
+func (fakeTx) Commit() error {
+	return nil
+}
+
+func (fakeTx) Rollback() error {
+	return nil
+}
+
+type fakeRows struct {
+	done bool
+}
+
+func (*fakeRows) Columns() []string {
+	return []string{"value"}
+}
+
+func (*fakeRows) Close() error {
+	return nil
+}
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if r.done {
+		return io.EOF
+	}
+	r.done = true
+	dest[0] = int64(42)
+
+	return nil
+}
+
+type fakeConnector struct {
+	driver driver.Driver
+}
+
+func (c fakeConnector) Connect(context.Context) (driver.Conn, error) {
+	return c.driver.Open("")
+}
+
+func (c fakeConnector) Driver() driver.Driver {
+	return c.driver
+}
+
+func TestWrap(t *testing.T) {
+	recorder := autometricstest.NewRecorder()
+	defer recorder.Stop()
+
+	db := sql.OpenDB(fakeConnector{driver: Wrap(fakeDriver{}, autometricstest.Backend)})
+	defer db.Close()
+
+	ctx := autometrics.ContextWithCaller(context.Background(), "handler", "api")
+
+	_, err := db.ExecContext(ctx, "INSERT")
+	assert.Nil(t, err)
+
+	var value int
+	err = db.QueryRowContext(WithQueryName(ctx, "getValue"), "SELECT").Scan(&value)
+	assert.Nil(t, err)
+	assert.Equal(t, 42, value)
+
+	_, err = db.ExecContext(context.Background(), "FAIL")
+	assert.ErrorIs(t, err, errFakeQuery)
+
+	tx, err := db.BeginTx(WithQueryName(ctx, "transfer"), nil)
+	if assert.Nil(t, err) {
+		assert.Nil(t, tx.Commit())
+	}
+
+	want := []struct {
+		function       string
+		parentFunction string
+		isError        bool
+	}{
+		{function: ExecOperation, parentFunction: "handler"},
+		{function: "getValue", parentFunction: "handler"},
+		{function: ExecOperation, isError: true},
+		{function: "transfer." + BeginOperation, parentFunction: "handler"},
+		{function: "transfer." + CommitOperation, parentFunction: "handler"},
+	}
+
+	calls := recorder.Calls()
+	if !assert.Len(t, calls, len(want), "Every operation must be instrumented exactly once.") {
+		return
+	}
+	for i, w := range want {
+		assert.Equal(t, w.function, calls[i].Function, "The function label is not as expected.")
+		assert.Equal(t, DefaultModuleName, calls[i].Module, "The module label is not as expected.")
+		assert.Equal(t, w.parentFunction, calls[i].Caller, "The caller label is not as expected.")
+		assert.Equal(t, w.isError, calls[i].Err != nil, "The result is not as expected.")
+	}
+}
+
+type tracingOption struct{}
+
+func (tracingOption) Apply(ctx *autometrics.Context) {
+	ctx.TrackProfileLabels = true
+	ctx.TrackRuntimeTrace = true
+}
+
+// goroutineLabels returns the pprof labels of the current goroutine, as written in
+// the goroutine profile, like `{"team":"checkout"}`.
+func goroutineLabels(t *testing.T) string {
+	var profile bytes.Buffer
+	if err := pprof.Lookup("goroutine").WriteTo(&profile, 1); err != nil {
+		t.Fatalf("error writing the goroutine profile: %s", err)
+	}
+
+	// The current goroutine is the one writing the profile.
+	for _, entry := range strings.Split(profile.String(), "\n\n") {
+		if !strings.Contains(entry, "runtime/pprof.writeGoroutine") {
+			continue
+		}
+		for _, line := range strings.Split(entry, "\n") {
+			if labels := strings.TrimPrefix(line, "# labels: "); labels != line {
+				return labels
+			}
+		}
+		return ""
+	}
+
+	t.Fatal("the current goroutine is not in the goroutine profile")
+	return ""
+}
+
+func TestWrapSkippedOperation(t *testing.T) {
+	var buffer bytes.Buffer
+	if err := trace.Start(&buffer); err != nil {
+		t.Skipf("the execution tracer is not available: %s", err)
+	}
+	defer trace.Stop()
+
+	recorder := autometricstest.NewRecorder()
+	defer recorder.Stop()
+
+	db := sql.OpenDB(fakeConnector{driver: Wrap(fakeDriver{}, autometricstest.Backend, WithContextOptions(tracingOption{}))})
+	defer db.Close()
+
+	// The labels of the caller, like the ones set by pprof.Do.
+	ctx := pprof.WithLabels(context.Background(), pprof.Labels("team", "checkout"))
+	pprof.SetGoroutineLabels(ctx)
+	defer pprof.SetGoroutineLabels(context.Background())
+	callerLabels := goroutineLabels(t)
+
+	_, err := db.ExecContext(ctx, "SKIP")
+	assert.Error(t, err, "The fallback to a prepared statement must fail.")
+
+	assert.Empty(t, recorder.Calls(), "The skipped operation must not be recorded.")
+	assert.Equal(t, callerLabels, goroutineLabels(t), "The labels of the caller must be restored after a skipped operation.")
+
+	_, err = db.ExecContext(ctx, "INSERT")
+	assert.Nil(t, err)
+	assert.Len(t, recorder.Calls(), 1, "The next operation must be recorded.")
+	assert.Equal(t, callerLabels, goroutineLabels(t), "The labels of the caller must be restored after the operation.")
+}
@@ -0,0 +1,60 @@
+package statsd // import "github.com/autometrics-dev/autometrics-go/pkg/autometrics/statsd"
+
+import (
+	"context"
+
+	"github.com/autometrics-dev/autometrics-go/pkg/autometrics"
+)
+
+// async runs the tasks started with Go and Group, counted in the AsyncTasksInFlightName gauge.
+var async = autometrics.Async{
+	Backend: Backend,
+	InFlight: func(_ context.Context, spawner autometrics.CallInfo, delta int64) {
+		if client != nil {
+			client.send(asyncTasks.add(AsyncTasksInFlightName, asyncTags(spawner), delta))
+		}
+	},
+}
+
+// Go runs fn in a new goroutine, instrumented as the function name in the module
+// of the function calling Go, with that function as caller.
+//
+// The context given to fn records the task as the ancestor of the calls made with it.
+// The task is counted in the AsyncTasksInFlightName gauge of the calling function until it returns.
+func Go(ctx context.Context, name string, fn func(ctx context.Context) error, opts ...autometrics.Option) {
+	async.Go(ctx, autometrics.CallerInfo(), name, fn, opts...)
+}
+
+// Group runs instrumented async tasks and waits for them, like errgroup.Group.
+//
+// The zero value is a valid Group that does not cancel anything on error.
+type Group struct {
+	group autometrics.Group
+}
+
+// NewGroup returns a new Group and a context derived from ctx, that is canceled
+// when a task of the group returns an error, or when Wait returns.
+func NewGroup(ctx context.Context) (*Group, context.Context) {
+	g := &Group{}
+
+	return g, g.group.WithContext(ctx)
+}
+
+// Go runs fn in a new goroutine, instrumented like the Go function with the context of the group.
+func (g *Group) Go(name string, fn func(ctx context.Context) error, opts ...autometrics.Option) {
+	g.group.Go(async, autometrics.CallerInfo(), name, fn, opts...)
+}
+
+// Wait blocks until all the tasks of the group returned, and returns the first error.
+func (g *Group) Wait() error {
+	return g.group.Wait()
+}
+
+// asyncTags returns the tags of the AsyncTasksInFlightName gauge of the spawning function.
+func asyncTags(spawner autometrics.CallInfo) []string {
+	return []string{
+		tag(FunctionLabel, spawner.FuncName),
+		tag(ModuleLabel, spawner.ModuleName),
+		tag(ServiceNameLabel, serviceName),
+	}
+}
@@ -0,0 +1,29 @@
+package statsd // import "github.com/autometrics-dev/autometrics-go/pkg/autometrics/statsd"
+
+import (
+	"github.com/autometrics-dev/autometrics-go/pkg/autometrics"
+)
+
+// Backend is the [autometrics.Backend] of this implementation, for the packages
+// that instrument calls without the generator.
+var Backend autometrics.Backend = backend{}
+
+type backend struct{}
+
+func (backend) NewContext(opts ...autometrics.Option) *autometrics.Context {
+	return NewContext(opts...)
+}
+
+// PreInstrument reads the call information itself, so that the first frame is
+// the caller of the method and not the PreInstrument function.
+func (backend) PreInstrument(ctx *autometrics.Context) *autometrics.Context {
+	return preInstrument(ctx, autometrics.CallerInfo())
+}
+
+func (backend) Instrument(ctx *autometrics.Context, err *error) {
+	Instrument(ctx, err)
+}
+
+func (backend) RegisterFunction(funcName, moduleName string, ctx *autometrics.Context) {
+	RegisterFunction(funcName, moduleName, ctx)
+}
@@ -0,0 +1,70 @@
+package statsd
+
+import (
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func limitedCallee() (err error) {
+	defer Instrument(PreInstrument(NewContext(
+		WithConcurrentCalls(false),
+	)), &err)
+
+	return nil
+}
+
+func firstCaller() error {
+	return limitedCallee()
+}
+
+func secondCaller() error {
+	return limitedCallee()
+}
+
+func TestCardinalityLimit(t *testing.T) {
+	agent, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("error listening for the StatsD datagrams: %s", err)
+	}
+	defer agent.Close()
+
+	if err := Init(agent.LocalAddr().String(), WithService("checkout"), WithCardinalityLimit(1)); err != nil {
+		t.Fatalf("Init failed: %s", err)
+	}
+
+	assert.Nil(t, firstCaller())
+	assert.Nil(t, secondCaller())
+	assert.Nil(t, firstCaller())
+
+	// The build information, a counter and a timer for each call, and an overflow
+	// counter for the call from secondCaller.
+	var lines []string
+	buffer := make([]byte, 65536)
+	for len(lines) < 8 {
+		_ = agent.SetReadDeadline(time.Now().Add(time.Second))
+		n, _, err := agent.ReadFrom(buffer)
+		if err != nil {
+			t.Fatalf("error reading the StatsD datagrams after %v: %s", lines, err)
+		}
+		lines = append(lines, strings.Split(string(buffer[:n]), "\n")...)
+	}
+
+	var counts []string
+	for _, line := range lines {
+		if strings.HasPrefix(line, "function.calls.count:") {
+			counts = append(counts, line)
+		}
+	}
+
+	assert.Equal(t, []string{
+		"function.calls.count:1|c|#function:limitedCallee,module:statsd,service_name:checkout,caller:statsd.firstCaller,result:ok,objective_percentile:,objective_name:",
+		"function.calls.count:1|c|#function:limitedCallee,module:statsd,service_name:checkout,caller:__overflow__,result:ok,objective_percentile:,objective_name:",
+		"function.calls.count:1|c|#function:limitedCallee,module:statsd,service_name:checkout,caller:statsd.firstCaller,result:ok,objective_percentile:,objective_name:",
+	}, counts, "The callers over the limit must be recorded with the overflow value.")
+	assert.Contains(t, lines, "autometrics.cardinality.overflow.total:1|c|#function:limitedCallee,module:statsd,label:caller,service_name:checkout",
+		"The overflowed callers must be counted.")
+}
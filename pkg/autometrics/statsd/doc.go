@@ -0,0 +1,10 @@
+// Package statsd implements the automatic metric collection for autometrics using the [StatsD] protocol,
+// with the tags of the [DogStatsD] extension.
+//
+// The package contains the function implementations for the generated calls, see
+// the main project's [Readme] for more detail.
+//
+// [Readme]: https://github.com/autometrics-dev/autometrics-go
+// [StatsD]: https://github.com/statsd/statsd
+// [DogStatsD]: https://docs.datadoghq.com/developers/dogstatsd/datagram_shell/
+package statsd
@@ -0,0 +1,221 @@
+package statsd // import "github.com/autometrics-dev/autometrics-go/pkg/autometrics/statsd"
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/autometrics-dev/autometrics-go/pkg/autometrics"
+)
+
+// Instrument called in a defer statement wraps the body of a function
+// with automatic instrumentation.
+//
+// The first argument SHOULD be a call to PreInstrument so that
+// the "concurrent calls" gauge is correctly setup.
+func Instrument(ctx *autometrics.Context, err *error) {
+	result := "ok"
+	var callErr error
+
+	if err != nil && *err != nil {
+		result = "error"
+		callErr = *err
+	}
+
+	duration := time.Since(ctx.StartTime)
+	ctx.EndRuntimeTrace()
+	ctx.RestoreProfileLabels()
+	autometrics.ObserveCall(ctx, callErr, duration)
+	autometrics.LogCall(ctx, callErr, duration)
+
+	// Without Init, the calls only go to the call observers.
+	if client == nil {
+		autometrics.WarnUninitialized("statsd")
+		return
+	}
+
+	var lines []string
+
+	callerFunction, callerModule := callerNames(ctx)
+	if callerModule == autometrics.OverflowLabelValue {
+		lines = append(lines, metricLine(CardinalityOverflowName, "1", "c", []string{
+			tag(FunctionLabel, ctx.CallInfo.FuncName),
+			tag(ModuleLabel, ctx.CallInfo.ModuleName),
+			tag(LabelNameLabel, callerLabelName()),
+			tag(ServiceNameLabel, serviceName),
+		}))
+	}
+
+	custom, overflowed := customLabels.Values(ctx)
+	for _, name := range overflowed {
+		lines = append(lines, metricLine(CardinalityOverflowName, "1", "c", []string{
+			tag(FunctionLabel, ctx.CallInfo.FuncName),
+			tag(ModuleLabel, ctx.CallInfo.ModuleName),
+			tag(LabelNameLabel, name),
+			tag(ServiceNameLabel, serviceName),
+		}))
+	}
+
+	sloName, latencyTarget, latencyObjective, successObjective := sloLabels(ctx.AlertConf)
+
+	lines = append(lines, metricLine(countName, "1", "c",
+		append(functionTags(ctx.CallInfo.FuncName, ctx.CallInfo.ModuleName, callerFunction, callerModule, custom),
+			tag(ResultLabel, result),
+			tag(TargetSuccessRateLabel, successObjective),
+			tag(SloNameLabel, sloName),
+		)))
+
+	milliseconds := float64(duration) / float64(time.Millisecond)
+	lines = append(lines, metricLine(durationName, strconv.FormatFloat(milliseconds, 'f', -1, 64), durationType,
+		append(functionTags(ctx.CallInfo.FuncName, ctx.CallInfo.ModuleName, callerFunction, callerModule, custom),
+			tag(TargetLatencyLabel, latencyTarget),
+			tag(TargetSuccessRateLabel, latencyObjective),
+			tag(SloNameLabel, sloName),
+		)))
+
+	// The calls are recorded once more for each other objective of the function, with
+	// a tag marking them as copies so that the queries counting the calls can leave them out.
+	for i := range ctx.MoreAlertConfs {
+		alertConf := &ctx.MoreAlertConfs[i]
+		sloName, latencyTarget, latencyObjective, successObjective := sloLabels(alertConf)
+
+		if alertConf.Success != nil {
+			lines = append(lines, metricLine(countName, "1", "c",
+				append(functionTags(ctx.CallInfo.FuncName, ctx.CallInfo.ModuleName, callerFunction, callerModule, custom),
+					tag(ResultLabel, result),
+					tag(TargetSuccessRateLabel, successObjective),
+					tag(SloNameLabel, sloName),
+					tag(ObjectiveCopyLabel, autometrics.ObjectiveCopyValue),
+				)))
+		}
+
+		if alertConf.Latency != nil {
+			lines = append(lines, metricLine(durationName, strconv.FormatFloat(milliseconds, 'f', -1, 64), durationType,
+				append(functionTags(ctx.CallInfo.FuncName, ctx.CallInfo.ModuleName, callerFunction, callerModule, custom),
+					tag(TargetLatencyLabel, latencyTarget),
+					tag(TargetSuccessRateLabel, latencyObjective),
+					tag(SloNameLabel, sloName),
+					tag(ObjectiveCopyLabel, autometrics.ObjectiveCopyValue),
+				)))
+		}
+	}
+
+	if ctx.TrackConcurrentCalls {
+		lines = append(lines, concurrentCalls.add(FunctionCallsConcurrentName,
+			functionTags(ctx.CallInfo.FuncName, ctx.CallInfo.ModuleName, callerFunction, callerModule, custom), -1))
+	}
+
+	client.send(lines...)
+}
+
+// PreInstrument runs the "before wrappee" part of instrumentation.
+//
+// It is meant to be called as the first argument to Instrument in a
+// defer call.
+func PreInstrument(ctx *autometrics.Context) *autometrics.Context {
+	return preInstrument(ctx, autometrics.CallerInfo())
+}
+
+// preInstrument runs PreInstrument with the call information read from the stack
+// frames, that the callers read themselves to skip the right number of frames.
+func preInstrument(ctx *autometrics.Context, callInfo autometrics.CallInfo) *autometrics.Context {
+	ctx.CallInfo = ctx.CallInfo.Complete(callInfo)
+	ctx.PropagateCaller()
+	ctx.ApplyProfileLabels()
+	ctx.StartRuntimeTrace()
+
+	if ctx.TrackConcurrentCalls && client != nil {
+		callerFunction, callerModule := callerNames(ctx)
+		custom, _ := customLabels.Values(ctx)
+		client.send(concurrentCalls.add(FunctionCallsConcurrentName,
+			functionTags(ctx.CallInfo.FuncName, ctx.CallInfo.ModuleName, callerFunction, callerModule, custom), 1))
+	}
+
+	ctx.StartTime = time.Now()
+
+	return ctx
+}
+
+// sloLabels returns the values of the (SloNameLabel, TargetLatencyLabel, latency TargetSuccessRateLabel,
+// success TargetSuccessRateLabel) tags for an objective of the function, which can be nil.
+func sloLabels(alertConf *autometrics.AlertConfiguration) (sloName, latencyTarget, latencyObjective, successObjective string) {
+	if alertConf != nil {
+		sloName = alertConf.ServiceName
+
+		if alertConf.Latency != nil {
+			latencyTarget = strconv.FormatFloat(alertConf.Latency.Target.Seconds(), 'f', -1, 64)
+			latencyObjective = strconv.FormatFloat(alertConf.Latency.Objective, 'f', -1, 64)
+		}
+
+		if alertConf.Success != nil {
+			successObjective = strconv.FormatFloat(alertConf.Success.Objective, 'f', -1, 64)
+		}
+	}
+
+	return
+}
+
+// callerNames returns the function and module names of the caller, or empty strings
+// if the context does not track the caller name.
+//
+// Both names are [autometrics.OverflowLabelValue] if the function exceeded the cardinality
+// limit for its callers.
+func callerNames(ctx *autometrics.Context) (callerFunction, callerModule string) {
+	if !ctx.TrackCallerName {
+		return "", ""
+	}
+
+	caller := fmt.Sprintf("%s.%s", ctx.CallInfo.ParentModuleName, ctx.CallInfo.ParentFuncName)
+	if !callerLimiter.Allow(ctx.CallInfo.FuncName, ctx.CallInfo.ModuleName, caller) {
+		return autometrics.OverflowLabelValue, autometrics.OverflowLabelValue
+	}
+
+	return ctx.CallInfo.ParentFuncName, ctx.CallInfo.ParentModuleName
+}
+
+// callerLabelName returns the name of the tag holding the caller, following
+// the naming scheme given to Init.
+func callerLabelName() string {
+	if namingScheme == autometrics.SpecNaming {
+		return CallerFunctionLabel
+	}
+
+	return CallerLabel
+}
+
+// functionTags returns the tags that identify a function, its caller and the service,
+// following the naming scheme given to Init, along with the user-defined labels declared in Init.
+//
+// An empty callerFunction and callerModule means the caller is unknown. custom holds the values
+// of the user-defined labels, see [autometrics.LabelAllowlist.Values]; the missing values are empty.
+func functionTags(funcName, moduleName, callerFunction, callerModule string, custom []string) []string {
+	tags := []string{
+		tag(FunctionLabel, funcName),
+		tag(ModuleLabel, moduleName),
+		tag(ServiceNameLabel, serviceName),
+	}
+
+	for i, name := range customLabels.Names() {
+		value := ""
+		if i < len(custom) {
+			value = custom[i]
+		}
+		tags = append(tags, tag(name, value))
+	}
+
+	if namingScheme == autometrics.SpecNaming {
+		return append(tags,
+			tag(CallerFunctionLabel, callerFunction),
+			tag(CallerModuleLabel, callerModule),
+		)
+	}
+
+	var callerLabel string
+	if callerModule == autometrics.OverflowLabelValue {
+		callerLabel = autometrics.OverflowLabelValue
+	} else if callerFunction != "" || callerModule != "" {
+		callerLabel = fmt.Sprintf("%s.%s", callerModule, callerFunction)
+	}
+
+	return append(tags, tag(CallerLabel, callerLabel))
+}
@@ -0,0 +1,342 @@
+package statsd // import "github.com/autometrics-dev/autometrics-go/pkg/autometrics/statsd"
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/autometrics-dev/autometrics-go/pkg/autometrics"
+)
+
+var (
+	client           *statsdClient
+	concurrentCalls  *gaugeSet
+	asyncTasks       *gaugeSet
+	callerLimiter    *autometrics.CardinalityLimiter
+	customLabels     *autometrics.LabelAllowlist
+	serviceName      string
+	namingScheme     autometrics.NamingScheme
+	countName        string
+	durationName     string
+	durationType     string
+	tagValueReplacer = strings.NewReplacer(",", "_", "|", "_", "#", "_", "\n", "_")
+)
+
+const (
+	// DefaultAddress is the address of a StatsD agent running on the same host.
+	DefaultAddress = "localhost:8125"
+	// MaxDatagramSize is the maximum size of the datagrams sent to the StatsD agent, in bytes,
+	// so that they fit in an Ethernet frame without fragmentation. A single metric line longer
+	// than this is sent in its own datagram.
+	MaxDatagramSize = 1432
+
+	// FunctionCallsCountName is the name of the StatsD counter of calls to specific functions.
+	FunctionCallsCountName = "function.calls.count"
+	// FunctionCallsDurationName is the name of the StatsD timer of the duration of calls to specific functions.
+	FunctionCallsDurationName = "function.calls.duration"
+	// FunctionCallsConcurrentName is the name of the StatsD gauge for the number of simulateneously active calls to specific functions.
+	FunctionCallsConcurrentName = "function.calls.concurrent"
+	// BuildInfoName is the name of the StatsD gauge for the version, commit and branch of the running program.
+	BuildInfoName = "autometrics.build_info"
+	// FunctionCallsTotalName is the name of the StatsD counter of calls to specific functions,
+	// when using [autometrics.SpecNaming].
+	FunctionCallsTotalName = "function.calls.total"
+	// FunctionCallsDurationSecondsName is the name of the StatsD timer of the duration of calls
+	// to specific functions, when using [autometrics.SpecNaming].
+	FunctionCallsDurationSecondsName = "function.calls.duration.seconds"
+	// BuildInfoSpecName is the name of the StatsD gauge for the version, commit and branch of the running program,
+	// when using [autometrics.SpecNaming].
+	BuildInfoSpecName = "build_info"
+	// CardinalityOverflowName is the name of the StatsD counter of calls recorded with
+	// the [autometrics.OverflowLabelValue] value because of the cardinality limit.
+	CardinalityOverflowName = "autometrics.cardinality.overflow.total"
+	// AsyncTasksInFlightName is the name of the StatsD gauge for the number of running async tasks
+	// started by specific functions with Go or Group.
+	AsyncTasksInFlightName = "function.async_tasks.in_flight"
+
+	// FunctionLabel is the StatsD tag that describes the function name.
+	//
+	// It is guaranteed that a (FunctionLabel, ModuleLabel) value pair is unique
+	// and matches at most one function in the source code
+	FunctionLabel = "function"
+	// ModuleLabel is the StatsD tag that describes the module name that contains the function.
+	//
+	// It is guaranteed that a (FunctionLabel, ModuleLabel) value pair is unique
+	// and matches at most one function in the source code
+	ModuleLabel = "module"
+	// CallerLabel is the StatsD tag that describes the name of the function that called
+	// the current function.
+	CallerLabel = "caller"
+	// CallerFunctionLabel is the StatsD tag that describes the name of the function that called
+	// the current function, when using [autometrics.SpecNaming].
+	CallerFunctionLabel = "caller_function"
+	// CallerModuleLabel is the StatsD tag that describes the module name of the function that called
+	// the current function, when using [autometrics.SpecNaming].
+	CallerModuleLabel = "caller_module"
+	// ResultLabel is the StatsD tag that describes whether a function call is successful.
+	ResultLabel = "result"
+	// TargetLatencyLabel is the StatsD tag that describes the latency to respect to match
+	// the Service Level Objective.
+	TargetLatencyLabel = "objective_latency_threshold"
+	// TargetSuccessRateLabel is the StatsD tag that describes the percentage of calls that
+	// must succeed to match the Service Level Objective.
+	//
+	// In the case of latency objectives, it describes the percentage of
+	// calls that must last less than the value in [TargetLatencyLabel].
+	//
+	// In the case of success objectives, it describes the percentage of calls
+	// that must be successful (i.e. have their [ResultLabel] be 'ok').
+	TargetSuccessRateLabel = "objective_percentile"
+	// SloLabelName is the StatsD tag that describes the name of the Service Level Objective.
+	SloNameLabel = "objective_name"
+	// ObjectiveCopyLabel is the StatsD tag that marks the metrics recorded for the objectives of
+	// a function after its first one, see [autometrics.Context.MoreAlertConfs]. The queries that
+	// count the calls of the functions must leave out the metrics that have it.
+	ObjectiveCopyLabel = "objective_copy"
+	// VersionLabel is the StatsD tag that describes the version of the running program.
+	VersionLabel = "version"
+	// CommitLabel is the StatsD tag that describes the commit of the running program.
+	CommitLabel = "commit"
+	// BranchLabel is the StatsD tag that describes the VCS branch of the running program.
+	BranchLabel = "branch"
+	// ServiceNameLabel is the StatsD tag that describes the name of the service
+	// the metrics come from.
+	ServiceNameLabel = "service_name"
+	// LabelNameLabel is the StatsD tag that describes the name of the tag whose values
+	// exceeded the cardinality limit.
+	LabelNameLabel = "label"
+)
+
+// Init sets up the client sending the metrics of autometrics' decorated functions
+// to the StatsD agent listening on the UDP address, like DefaultAddress.
+//
+// The metrics use the tags of the DogStatsD extension, that the Datadog agent and
+// the Prometheus statsd_exporter understand. The metric names are chosen so that
+// the statsd_exporter exports the names that the generated links and the
+// autometrics.rules.yml file expect, when it observes the timers as histograms
+// with the buckets of [autometrics.DefBuckets].
+//
+// The durations are sent as timers in milliseconds, or as distributions with the
+// WithDistributions option. The concurrent calls are sent as gauges.
+//
+// The version, commit and branch of the running program are sent once in the
+// BuildInfoName gauge, see [autometrics.NewInitSettings] for the way they are
+// detected and the options to override them.
+//
+// All the metrics have a ServiceNameLabel tag, set with the AUTOMETRICS_SERVICE_NAME
+// environment variable or the WithService option.
+//
+// The WithCardinalityLimit option bounds the number of distinct callers recorded for
+// each function, the calls recorded with the overflow value are counted in the
+// CardinalityOverflowName counter.
+//
+// The WithNamingScheme option switches the names of the metrics and of the caller
+// tags to the ones of the Autometrics specification.
+//
+// The WithLabelAllowlist option adds user-defined tags to the function call metrics,
+// the values missing from the allowlist are counted in the CardinalityOverflowName counter.
+//
+// The async tasks started with Go or Group are counted in the AsyncTasksInFlightName
+// gauge of the function that started them.
+//
+// The batch mode of the prometheus implementation is not supported, Init returns an
+// error if it is enabled. Calling Init again closes the connection of the previous call.
+func Init(address string, opts ...autometrics.InitOption) error {
+	settings := autometrics.NewInitSettings(opts...)
+	if settings.BatchMode {
+		return errors.New("the batch mode is not supported by the statsd implementation")
+	}
+
+	allowlist, err := autometrics.NewLabelAllowlist(settings.AllowedLabels,
+		FunctionLabel, ModuleLabel, CallerLabel, CallerFunctionLabel, CallerModuleLabel, ResultLabel,
+		TargetLatencyLabel, TargetSuccessRateLabel, SloNameLabel, ObjectiveCopyLabel, ServiceNameLabel)
+	if err != nil {
+		return fmt.Errorf("error initializing the user-defined labels: %w", err)
+	}
+
+	customLabels = allowlist
+	serviceName = settings.ServiceName
+	namingScheme = settings.NamingScheme
+	callerLimiter = autometrics.NewCardinalityLimiter(settings.CardinalityLimit)
+	autometrics.SetClosureSuffix(settings.ClosureSuffix)
+	autometrics.SetProfileLabels(settings.ProfileLabels)
+	autometrics.SetRuntimeTrace(settings.RuntimeTrace)
+	autometrics.SetLogger(settings.Logger, settings.LogInterval)
+
+	buildInfoName := BuildInfoName
+	countName, durationName = FunctionCallsCountName, FunctionCallsDurationName
+	if namingScheme == autometrics.SpecNaming {
+		countName, durationName, buildInfoName = FunctionCallsTotalName, FunctionCallsDurationSecondsName, BuildInfoSpecName
+	}
+
+	durationType = "ms"
+	if settings.DurationDistributions {
+		durationType = "d"
+	}
+
+	conn, err := net.Dial("udp", address)
+	if err != nil {
+		return fmt.Errorf("error connecting to the StatsD agent at %v: %w", address, err)
+	}
+	if client != nil {
+		client.close()
+	}
+	client = &statsdClient{conn: conn}
+	concurrentCalls = newGaugeSet()
+	asyncTasks = newGaugeSet()
+
+	client.send(metricLine(buildInfoName, "1", "g", []string{
+		tag(VersionLabel, settings.BuildInfo.Version),
+		tag(CommitLabel, settings.BuildInfo.Commit),
+		tag(BranchLabel, settings.BuildInfo.Branch),
+		tag(ServiceNameLabel, serviceName),
+	}))
+
+	for _, function := range autometrics.RegisteredFunctions() {
+		initializeFunctionMetrics(function)
+	}
+
+	return nil
+}
+
+// RegisterFunction announces an instrumented function, so that its metrics
+// exist with a zero value before the function gets called for the first time.
+//
+// The generator adds calls to RegisterFunction in an init function for all the
+// instrumented functions of a file. The zero-valued series are sent in Init,
+// or immediately if Init has already been called.
+func RegisterFunction(funcName, moduleName string, ctx *autometrics.Context) {
+	function := autometrics.RegisteredFunction{
+		FuncName:   funcName,
+		ModuleName: moduleName,
+		Context:    *ctx,
+	}
+
+	autometrics.RegisterFunction(function)
+
+	if client != nil {
+		initializeFunctionMetrics(function)
+	}
+}
+
+// initializeFunctionMetrics sends the zero-valued series of a registered
+// function, for all the possible results.
+//
+// The caller of the function is not known before it is called, so the caller tag is empty.
+//
+// StatsD timers cannot have a data point without recording a value, so only the
+// counters and the gauge are initialized.
+func initializeFunctionMetrics(function autometrics.RegisteredFunction) {
+	sloName, _, _, successObjective := sloLabels(function.Context.AlertConf)
+
+	var lines []string
+	for _, result := range []string{"ok", "error"} {
+		lines = append(lines, metricLine(countName, "0", "c",
+			append(functionTags(function.FuncName, function.ModuleName, "", "", nil),
+				tag(ResultLabel, result),
+				tag(TargetSuccessRateLabel, successObjective),
+				tag(SloNameLabel, sloName),
+			)))
+	}
+
+	for i := range function.Context.MoreAlertConfs {
+		alertConf := &function.Context.MoreAlertConfs[i]
+		if alertConf.Success == nil {
+			continue
+		}
+
+		sloName, _, _, successObjective := sloLabels(alertConf)
+		for _, result := range []string{"ok", "error"} {
+			lines = append(lines, metricLine(countName, "0", "c",
+				append(functionTags(function.FuncName, function.ModuleName, "", "", nil),
+					tag(ResultLabel, result),
+					tag(TargetSuccessRateLabel, successObjective),
+					tag(SloNameLabel, sloName),
+					tag(ObjectiveCopyLabel, autometrics.ObjectiveCopyValue),
+				)))
+		}
+	}
+
+	if function.Context.TrackConcurrentCalls {
+		lines = append(lines, concurrentCalls.add(FunctionCallsConcurrentName, functionTags(function.FuncName, function.ModuleName, "", "", nil), 0))
+	}
+
+	client.send(lines...)
+}
+
+// statsdClient sends the metric lines to the StatsD agent.
+type statsdClient struct {
+	mutex sync.Mutex
+	conn  net.Conn
+}
+
+// send writes the lines in as few datagrams of at most MaxDatagramSize bytes as possible.
+// The errors are ignored, like the losses of datagrams: the metrics never fail the
+// instrumented function.
+func (c *statsdClient) send(lines ...string) {
+	if c == nil || len(lines) == 0 {
+		return
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	var datagram []byte
+	for _, line := range lines {
+		if len(datagram) > 0 && len(datagram)+1+len(line) > MaxDatagramSize {
+			_, _ = c.conn.Write(datagram)
+			datagram = datagram[:0]
+		}
+		if len(datagram) > 0 {
+			datagram = append(datagram, '\n')
+		}
+		datagram = append(datagram, line...)
+	}
+
+	_, _ = c.conn.Write(datagram)
+}
+
+// close closes the connection to the StatsD agent.
+func (c *statsdClient) close() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	_ = c.conn.Close()
+}
+
+// gaugeSet keeps the current values of the gauges, since DogStatsD does not
+// support relative changes of gauges.
+type gaugeSet struct {
+	mutex  sync.Mutex
+	values map[string]int64
+}
+
+func newGaugeSet() *gaugeSet {
+	return &gaugeSet{values: make(map[string]int64)}
+}
+
+// add changes the value of the gauge with the name and tags, and returns the line
+// setting the new value.
+func (s *gaugeSet) add(name string, tags []string, delta int64) string {
+	key := name + "|" + strings.Join(tags, ",")
+
+	s.mutex.Lock()
+	s.values[key] += delta
+	value := s.values[key]
+	s.mutex.Unlock()
+
+	return metricLine(name, strconv.FormatInt(value, 10), "g", tags)
+}
+
+// metricLine returns the DogStatsD line of a metric, like "name:1|c|#tag:value".
+func metricLine(name, value, metricType string, tags []string) string {
+	return fmt.Sprintf("%s:%s|%s|#%s", name, value, metricType, strings.Join(tags, ","))
+}
+
+// tag returns a DogStatsD tag, with the characters of the protocol replaced in the value.
+func tag(name, value string) string {
+	return name + ":" + tagValueReplacer.Replace(value)
+}
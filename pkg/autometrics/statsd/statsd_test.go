@@ -0,0 +1,165 @@
+package statsd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/autometrics-dev/autometrics-go/pkg/autometrics"
+	"github.com/stretchr/testify/assert"
+)
+
+func failingFunction() (err error) {
+	defer Instrument(PreInstrument(NewContext(
+		WithSloName("API"),
+		WithAlertSuccess(99.9),
+	)), &err)
+
+	return errors.New("the call failed")
+}
+
+func TestInstrument(t *testing.T) {
+	agent, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("error listening for the StatsD datagrams: %s", err)
+	}
+	defer agent.Close()
+
+	if err := Init(agent.LocalAddr().String(), WithService("checkout"), WithVersion("1.2.3"), WithCommit("abc123"), WithBranch("main")); err != nil {
+		t.Fatalf("Init failed: %s", err)
+	}
+
+	assert.NotNil(t, failingFunction())
+
+	var lines []string
+	buffer := make([]byte, 65536)
+	for len(lines) < 5 {
+		_ = agent.SetReadDeadline(time.Now().Add(time.Second))
+		n, _, err := agent.ReadFrom(buffer)
+		if err != nil {
+			t.Fatalf("error reading the StatsD datagrams after %v: %s", lines, err)
+		}
+		lines = append(lines, strings.Split(string(buffer[:n]), "\n")...)
+	}
+
+	assert.Equal(t, "autometrics.build_info:1|g|#version:1.2.3,commit:abc123,branch:main,service_name:checkout", lines[0],
+		"The build information is not as expected.")
+	assert.Equal(t, "function.calls.concurrent:1|g|#function:failingFunction,module:statsd,service_name:checkout,caller:statsd.TestInstrument", lines[1],
+		"The concurrent calls gauge must be set when the call starts.")
+	assert.Equal(t, "function.calls.count:1|c|#function:failingFunction,module:statsd,service_name:checkout,caller:statsd.TestInstrument,result:error,objective_percentile:99.9,objective_name:API", lines[2],
+		"The counter is not as expected.")
+	assert.True(t, strings.HasPrefix(lines[3], "function.calls.duration:"), "The duration must be sent after the counter.")
+	assert.True(t, strings.HasSuffix(lines[3], "|ms|#function:failingFunction,module:statsd,service_name:checkout,caller:statsd.TestInstrument,objective_latency_threshold:,objective_percentile:,objective_name:API"),
+		"The duration timer is not as expected.")
+	assert.Equal(t, "function.calls.concurrent:0|g|#function:failingFunction,module:statsd,service_name:checkout,caller:statsd.TestInstrument", lines[4],
+		"The concurrent calls gauge must be reset when the call ends.")
+}
+
+// listen returns a StatsD agent listening on a local UDP port.
+func listen(t *testing.T) net.PacketConn {
+	agent, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("error listening for the StatsD datagrams: %s", err)
+	}
+	t.Cleanup(func() { _ = agent.Close() })
+
+	return agent
+}
+
+// readDatagram returns the next datagram received by the agent.
+func readDatagram(t *testing.T, agent net.PacketConn) string {
+	buffer := make([]byte, 65536)
+	_ = agent.SetReadDeadline(time.Now().Add(time.Second))
+	n, _, err := agent.ReadFrom(buffer)
+	if err != nil {
+		t.Fatalf("error reading the StatsD datagrams: %s", err)
+	}
+
+	return string(buffer[:n])
+}
+
+func TestInitRejectsBatchMode(t *testing.T) {
+	agent := listen(t)
+
+	err := Init(agent.LocalAddr().String(), initOptionFunc(func(settings *autometrics.InitSettings) {
+		settings.BatchMode = true
+	}))
+	assert.Error(t, err, "The batch mode must be rejected instead of being ignored.")
+}
+
+func TestInitClosesPreviousConnection(t *testing.T) {
+	agent := listen(t)
+
+	if err := Init(agent.LocalAddr().String()); err != nil {
+		t.Fatalf("Init failed: %s", err)
+	}
+	previous := client
+
+	if err := Init(agent.LocalAddr().String()); err != nil {
+		t.Fatalf("Init failed: %s", err)
+	}
+
+	_, err := previous.conn.Write([]byte("test:1|c"))
+	assert.ErrorIs(t, err, net.ErrClosed, "The connection of the previous Init must be closed.")
+}
+
+func TestSendSplitsDatagrams(t *testing.T) {
+	agent := listen(t)
+
+	conn, err := net.Dial("udp", agent.LocalAddr().String())
+	if err != nil {
+		t.Fatalf("error connecting to the agent: %s", err)
+	}
+	c := &statsdClient{conn: conn}
+	defer c.close()
+
+	var lines []string
+	for i := 0; i < 100; i++ {
+		lines = append(lines, fmt.Sprintf("function.calls.count:1|c|#function:handler%d,module:main,service_name:checkout", i))
+	}
+	c.send(lines...)
+
+	var received []string
+	for len(received) < len(lines) {
+		datagram := readDatagram(t, agent)
+		assert.LessOrEqual(t, len(datagram), MaxDatagramSize, "The datagrams must not exceed the maximum size.")
+		received = append(received, strings.Split(datagram, "\n")...)
+	}
+	assert.Equal(t, lines, received, "All the lines must be sent in order.")
+}
+
+func spawnTask(done chan struct{}) {
+	Go(context.Background(), "sendReceipt", func(ctx context.Context) error {
+		defer close(done)
+		return nil
+	}, WithConcurrentCalls(false))
+}
+
+func TestAsyncTasks(t *testing.T) {
+	agent := listen(t)
+
+	if err := Init(agent.LocalAddr().String(), WithService("checkout")); err != nil {
+		t.Fatalf("Init failed: %s", err)
+	}
+	readDatagram(t, agent) // The build information.
+
+	done := make(chan struct{})
+	spawnTask(done)
+	<-done
+
+	var lines []string
+	for len(lines) < 4 {
+		lines = append(lines, strings.Split(readDatagram(t, agent), "\n")...)
+	}
+
+	assert.Equal(t, "function.async_tasks.in_flight:1|g|#function:spawnTask,module:statsd,service_name:checkout", lines[0],
+		"The task must be counted in the gauge of the spawning function when it starts.")
+	assert.Contains(t, lines, "function.calls.count:1|c|#function:sendReceipt,module:statsd,service_name:checkout,caller:statsd.spawnTask,result:ok,objective_percentile:,objective_name:",
+		"The task must be recorded with the spawning function as caller.")
+	assert.Equal(t, "function.async_tasks.in_flight:0|g|#function:spawnTask,module:statsd,service_name:checkout", lines[3],
+		"The gauge must go back to 0 once the task returned.")
+}